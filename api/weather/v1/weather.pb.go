@@ -0,0 +1,891 @@
+// gRPC API of service-b, for the internal consumers that speak gRPC. It
+// answers the same lookups as the HTTP routes, with temperatures rounded
+// like their default responses.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.1
+// 	protoc        v4.25.3
+// source: api/weather/v1/weather.proto
+
+package weatherv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Weather struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cep   string  `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	City  string  `protobuf:"bytes,2,opt,name=city,proto3" json:"city,omitempty"`
+	TempC float64 `protobuf:"fixed64,3,opt,name=temp_c,json=tempC,proto3" json:"temp_c,omitempty"`
+	TempF float64 `protobuf:"fixed64,4,opt,name=temp_f,json=tempF,proto3" json:"temp_f,omitempty"`
+	TempK float64 `protobuf:"fixed64,5,opt,name=temp_k,json=tempK,proto3" json:"temp_k,omitempty"`
+	// Coordinates of the CEP, when it could be geocoded.
+	Lat   *float64 `protobuf:"fixed64,6,opt,name=lat,proto3,oneof" json:"lat,omitempty"`
+	Lon   *float64 `protobuf:"fixed64,7,opt,name=lon,proto3,oneof" json:"lon,omitempty"`
+	TempR float64  `protobuf:"fixed64,8,opt,name=temp_r,json=tempR,proto3" json:"temp_r,omitempty"`
+}
+
+func (x *Weather) Reset() {
+	*x = Weather{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Weather) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Weather) ProtoMessage() {}
+
+func (x *Weather) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Weather.ProtoReflect.Descriptor instead.
+func (*Weather) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Weather) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *Weather) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *Weather) GetTempC() float64 {
+	if x != nil {
+		return x.TempC
+	}
+	return 0
+}
+
+func (x *Weather) GetTempF() float64 {
+	if x != nil {
+		return x.TempF
+	}
+	return 0
+}
+
+func (x *Weather) GetTempK() float64 {
+	if x != nil {
+		return x.TempK
+	}
+	return 0
+}
+
+func (x *Weather) GetLat() float64 {
+	if x != nil && x.Lat != nil {
+		return *x.Lat
+	}
+	return 0
+}
+
+func (x *Weather) GetLon() float64 {
+	if x != nil && x.Lon != nil {
+		return *x.Lon
+	}
+	return 0
+}
+
+func (x *Weather) GetTempR() float64 {
+	if x != nil {
+		return x.TempR
+	}
+	return 0
+}
+
+type GetWeatherByCEPRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cep string `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+}
+
+func (x *GetWeatherByCEPRequest) Reset() {
+	*x = GetWeatherByCEPRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetWeatherByCEPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWeatherByCEPRequest) ProtoMessage() {}
+
+func (x *GetWeatherByCEPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWeatherByCEPRequest.ProtoReflect.Descriptor instead.
+func (*GetWeatherByCEPRequest) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetWeatherByCEPRequest) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+type GetWeatherByCEPResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Weather *Weather `protobuf:"bytes,1,opt,name=weather,proto3" json:"weather,omitempty"`
+}
+
+func (x *GetWeatherByCEPResponse) Reset() {
+	*x = GetWeatherByCEPResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetWeatherByCEPResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWeatherByCEPResponse) ProtoMessage() {}
+
+func (x *GetWeatherByCEPResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWeatherByCEPResponse.ProtoReflect.Descriptor instead.
+func (*GetWeatherByCEPResponse) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetWeatherByCEPResponse) GetWeather() *Weather {
+	if x != nil {
+		return x.Weather
+	}
+	return nil
+}
+
+type BatchGetWeatherRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ceps []string `protobuf:"bytes,1,rep,name=ceps,proto3" json:"ceps,omitempty"`
+}
+
+func (x *BatchGetWeatherRequest) Reset() {
+	*x = BatchGetWeatherRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetWeatherRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetWeatherRequest) ProtoMessage() {}
+
+func (x *BatchGetWeatherRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetWeatherRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetWeatherRequest) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchGetWeatherRequest) GetCeps() []string {
+	if x != nil {
+		return x.Ceps
+	}
+	return nil
+}
+
+type BatchGetWeatherResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Results in the order of the requested CEPs.
+	Results []*BatchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *BatchGetWeatherResponse) Reset() {
+	*x = BatchGetWeatherResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetWeatherResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetWeatherResponse) ProtoMessage() {}
+
+func (x *BatchGetWeatherResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetWeatherResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetWeatherResponse) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchGetWeatherResponse) GetResults() []*BatchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BatchResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cep string `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	// HTTP status of the lookup, 200 when weather is set.
+	Status  int32    `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	Weather *Weather `protobuf:"bytes,3,opt,name=weather,proto3" json:"weather,omitempty"`
+	// Localized message and error code of a failed lookup.
+	Message string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Code    string `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`
+}
+
+func (x *BatchResult) Reset() {
+	*x = BatchResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchResult) ProtoMessage() {}
+
+func (x *BatchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchResult.ProtoReflect.Descriptor instead.
+func (*BatchResult) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchResult) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *BatchResult) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *BatchResult) GetWeather() *Weather {
+	if x != nil {
+		return x.Weather
+	}
+	return nil
+}
+
+func (x *BatchResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *BatchResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type StreamForecastRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cep string `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	// Number of days, from 1 to 14. Zero selects the default of 3.
+	Days int32 `protobuf:"varint,2,opt,name=days,proto3" json:"days,omitempty"`
+}
+
+func (x *StreamForecastRequest) Reset() {
+	*x = StreamForecastRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamForecastRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamForecastRequest) ProtoMessage() {}
+
+func (x *StreamForecastRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamForecastRequest.ProtoReflect.Descriptor instead.
+func (*StreamForecastRequest) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamForecastRequest) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *StreamForecastRequest) GetDays() int32 {
+	if x != nil {
+		return x.Days
+	}
+	return 0
+}
+
+type ForecastDay struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cep          string  `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	City         string  `protobuf:"bytes,2,opt,name=city,proto3" json:"city,omitempty"`
+	Date         string  `protobuf:"bytes,3,opt,name=date,proto3" json:"date,omitempty"`
+	MinC         float64 `protobuf:"fixed64,4,opt,name=min_c,json=minC,proto3" json:"min_c,omitempty"`
+	MinF         float64 `protobuf:"fixed64,5,opt,name=min_f,json=minF,proto3" json:"min_f,omitempty"`
+	MinK         float64 `protobuf:"fixed64,6,opt,name=min_k,json=minK,proto3" json:"min_k,omitempty"`
+	MaxC         float64 `protobuf:"fixed64,7,opt,name=max_c,json=maxC,proto3" json:"max_c,omitempty"`
+	MaxF         float64 `protobuf:"fixed64,8,opt,name=max_f,json=maxF,proto3" json:"max_f,omitempty"`
+	MaxK         float64 `protobuf:"fixed64,9,opt,name=max_k,json=maxK,proto3" json:"max_k,omitempty"`
+	AvgC         float64 `protobuf:"fixed64,10,opt,name=avg_c,json=avgC,proto3" json:"avg_c,omitempty"`
+	AvgF         float64 `protobuf:"fixed64,11,opt,name=avg_f,json=avgF,proto3" json:"avg_f,omitempty"`
+	AvgK         float64 `protobuf:"fixed64,12,opt,name=avg_k,json=avgK,proto3" json:"avg_k,omitempty"`
+	ChanceOfRain int32   `protobuf:"varint,13,opt,name=chance_of_rain,json=chanceOfRain,proto3" json:"chance_of_rain,omitempty"`
+	Condition    string  `protobuf:"bytes,14,opt,name=condition,proto3" json:"condition,omitempty"`
+	MinR         float64 `protobuf:"fixed64,15,opt,name=min_r,json=minR,proto3" json:"min_r,omitempty"`
+	MaxR         float64 `protobuf:"fixed64,16,opt,name=max_r,json=maxR,proto3" json:"max_r,omitempty"`
+	AvgR         float64 `protobuf:"fixed64,17,opt,name=avg_r,json=avgR,proto3" json:"avg_r,omitempty"`
+}
+
+func (x *ForecastDay) Reset() {
+	*x = ForecastDay{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_weather_v1_weather_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ForecastDay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastDay) ProtoMessage() {}
+
+func (x *ForecastDay) ProtoReflect() protoreflect.Message {
+	mi := &file_api_weather_v1_weather_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastDay.ProtoReflect.Descriptor instead.
+func (*ForecastDay) Descriptor() ([]byte, []int) {
+	return file_api_weather_v1_weather_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ForecastDay) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *ForecastDay) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *ForecastDay) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *ForecastDay) GetMinC() float64 {
+	if x != nil {
+		return x.MinC
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMinF() float64 {
+	if x != nil {
+		return x.MinF
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMinK() float64 {
+	if x != nil {
+		return x.MinK
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMaxC() float64 {
+	if x != nil {
+		return x.MaxC
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMaxF() float64 {
+	if x != nil {
+		return x.MaxF
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMaxK() float64 {
+	if x != nil {
+		return x.MaxK
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetAvgC() float64 {
+	if x != nil {
+		return x.AvgC
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetAvgF() float64 {
+	if x != nil {
+		return x.AvgF
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetAvgK() float64 {
+	if x != nil {
+		return x.AvgK
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetChanceOfRain() int32 {
+	if x != nil {
+		return x.ChanceOfRain
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetCondition() string {
+	if x != nil {
+		return x.Condition
+	}
+	return ""
+}
+
+func (x *ForecastDay) GetMinR() float64 {
+	if x != nil {
+		return x.MinR
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetMaxR() float64 {
+	if x != nil {
+		return x.MaxR
+	}
+	return 0
+}
+
+func (x *ForecastDay) GetAvgR() float64 {
+	if x != nil {
+		return x.AvgR
+	}
+	return 0
+}
+
+var File_api_weather_v1_weather_proto protoreflect.FileDescriptor
+
+var file_api_weather_v1_weather_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x61, 0x70, 0x69, 0x2f, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2f, 0x76, 0x31,
+	0x2f, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a,
+	0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x22, 0xc9, 0x01, 0x0a, 0x07, 0x57,
+	0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x65, 0x70, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x65, 0x70, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x69, 0x74, 0x79,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x69, 0x74, 0x79, 0x12, 0x15, 0x0a, 0x06,
+	0x74, 0x65, 0x6d, 0x70, 0x5f, 0x63, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x74, 0x65,
+	0x6d, 0x70, 0x43, 0x12, 0x15, 0x0a, 0x06, 0x74, 0x65, 0x6d, 0x70, 0x5f, 0x66, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x05, 0x74, 0x65, 0x6d, 0x70, 0x46, 0x12, 0x15, 0x0a, 0x06, 0x74, 0x65,
+	0x6d, 0x70, 0x5f, 0x6b, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x74, 0x65, 0x6d, 0x70,
+	0x4b, 0x12, 0x15, 0x0a, 0x03, 0x6c, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00,
+	0x52, 0x03, 0x6c, 0x61, 0x74, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x6c, 0x6f, 0x6e, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01, 0x52, 0x03, 0x6c, 0x6f, 0x6e, 0x88, 0x01, 0x01, 0x12,
+	0x15, 0x0a, 0x06, 0x74, 0x65, 0x6d, 0x70, 0x5f, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x05, 0x74, 0x65, 0x6d, 0x70, 0x52, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6c, 0x61, 0x74, 0x42, 0x06,
+	0x0a, 0x04, 0x5f, 0x6c, 0x6f, 0x6e, 0x22, 0x2a, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x57, 0x65, 0x61,
+	0x74, 0x68, 0x65, 0x72, 0x42, 0x79, 0x43, 0x45, 0x50, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x10, 0x0a, 0x03, 0x63, 0x65, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63,
+	0x65, 0x70, 0x22, 0x48, 0x0a, 0x17, 0x47, 0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72,
+	0x42, 0x79, 0x43, 0x45, 0x50, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2d, 0x0a,
+	0x07, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
+	0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x65, 0x61, 0x74,
+	0x68, 0x65, 0x72, 0x52, 0x07, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x22, 0x2c, 0x0a, 0x16,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x65, 0x70, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x63, 0x65, 0x70, 0x73, 0x22, 0x4c, 0x0a, 0x17, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x31, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72,
+	0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52,
+	0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x22, 0x94, 0x01, 0x0a, 0x0b, 0x42, 0x61, 0x74,
+	0x63, 0x68, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x65, 0x70, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x65, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x12, 0x2d, 0x0a, 0x07, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31,
+	0x2e, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x52, 0x07, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65,
+	0x72, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x63,
+	0x6f, 0x64, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x22,
+	0x3d, 0x0a, 0x15, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x46, 0x6f, 0x72, 0x65, 0x63, 0x61, 0x73,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x65, 0x70, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x65, 0x70, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61,
+	0x79, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x64, 0x61, 0x79, 0x73, 0x22, 0x87,
+	0x03, 0x0a, 0x0b, 0x46, 0x6f, 0x72, 0x65, 0x63, 0x61, 0x73, 0x74, 0x44, 0x61, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x63, 0x65, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x65, 0x70,
+	0x12, 0x12, 0x0a, 0x04, 0x63, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x63, 0x69, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x64, 0x61, 0x74, 0x65, 0x12, 0x13, 0x0a, 0x05, 0x6d, 0x69, 0x6e, 0x5f,
+	0x63, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d, 0x69, 0x6e, 0x43, 0x12, 0x13, 0x0a,
+	0x05, 0x6d, 0x69, 0x6e, 0x5f, 0x66, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d, 0x69,
+	0x6e, 0x46, 0x12, 0x13, 0x0a, 0x05, 0x6d, 0x69, 0x6e, 0x5f, 0x6b, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x04, 0x6d, 0x69, 0x6e, 0x4b, 0x12, 0x13, 0x0a, 0x05, 0x6d, 0x61, 0x78, 0x5f, 0x63,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d, 0x61, 0x78, 0x43, 0x12, 0x13, 0x0a, 0x05,
+	0x6d, 0x61, 0x78, 0x5f, 0x66, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d, 0x61, 0x78,
+	0x46, 0x12, 0x13, 0x0a, 0x05, 0x6d, 0x61, 0x78, 0x5f, 0x6b, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x04, 0x6d, 0x61, 0x78, 0x4b, 0x12, 0x13, 0x0a, 0x05, 0x61, 0x76, 0x67, 0x5f, 0x63, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x61, 0x76, 0x67, 0x43, 0x12, 0x13, 0x0a, 0x05, 0x61,
+	0x76, 0x67, 0x5f, 0x66, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x61, 0x76, 0x67, 0x46,
+	0x12, 0x13, 0x0a, 0x05, 0x61, 0x76, 0x67, 0x5f, 0x6b, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x04, 0x61, 0x76, 0x67, 0x4b, 0x12, 0x24, 0x0a, 0x0e, 0x63, 0x68, 0x61, 0x6e, 0x63, 0x65, 0x5f,
+	0x6f, 0x66, 0x5f, 0x72, 0x61, 0x69, 0x6e, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x63,
+	0x68, 0x61, 0x6e, 0x63, 0x65, 0x4f, 0x66, 0x52, 0x61, 0x69, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x63,
+	0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x63, 0x6f, 0x6e, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x13, 0x0a, 0x05, 0x6d, 0x69, 0x6e,
+	0x5f, 0x72, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d, 0x69, 0x6e, 0x52, 0x12, 0x13,
+	0x0a, 0x05, 0x6d, 0x61, 0x78, 0x5f, 0x72, 0x18, 0x10, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x6d,
+	0x61, 0x78, 0x52, 0x12, 0x13, 0x0a, 0x05, 0x61, 0x76, 0x67, 0x5f, 0x72, 0x18, 0x11, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x04, 0x61, 0x76, 0x67, 0x52, 0x32, 0x98, 0x02, 0x0a, 0x0e, 0x57, 0x65, 0x61,
+	0x74, 0x68, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5a, 0x0a, 0x0f, 0x47,
+	0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x42, 0x79, 0x43, 0x45, 0x50, 0x12, 0x22,
+	0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x57,
+	0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x42, 0x79, 0x43, 0x45, 0x50, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x23, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x42, 0x79, 0x43, 0x45, 0x50, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5a, 0x0a, 0x0f, 0x42, 0x61, 0x74, 0x63, 0x68,
+	0x47, 0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x12, 0x22, 0x2e, 0x77, 0x65, 0x61,
+	0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74,
+	0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23,
+	0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63,
+	0x68, 0x47, 0x65, 0x74, 0x57, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x4e, 0x0a, 0x0e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x46, 0x6f, 0x72,
+	0x65, 0x63, 0x61, 0x73, 0x74, 0x12, 0x21, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x46, 0x6f, 0x72, 0x65, 0x63, 0x61, 0x73,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x77, 0x65, 0x61, 0x74, 0x68,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x6f, 0x72, 0x65, 0x63, 0x61, 0x73, 0x74, 0x44, 0x61,
+	0x79, 0x30, 0x01, 0x42, 0x52, 0x5a, 0x50, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2f, 0x74, 0x6f, 0x6e, 0x6e, 0x79, 0x74, 0x67, 0x2f, 0x64, 0x65, 0x73, 0x61, 0x66, 0x69,
+	0x6f, 0x2d, 0x66, 0x63, 0x2d, 0x63, 0x65, 0x70, 0x2d, 0x61, 0x6e, 0x64, 0x2d, 0x63, 0x6c, 0x69,
+	0x6d, 0x61, 0x74, 0x65, 0x2d, 0x77, 0x69, 0x74, 0x68, 0x2d, 0x6f, 0x74, 0x65, 0x6c, 0x2f, 0x61,
+	0x70, 0x69, 0x2f, 0x77, 0x65, 0x61, 0x74, 0x68, 0x65, 0x72, 0x2f, 0x76, 0x31, 0x3b, 0x77, 0x65,
+	0x61, 0x74, 0x68, 0x65, 0x72, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_api_weather_v1_weather_proto_rawDescOnce sync.Once
+	file_api_weather_v1_weather_proto_rawDescData = file_api_weather_v1_weather_proto_rawDesc
+)
+
+func file_api_weather_v1_weather_proto_rawDescGZIP() []byte {
+	file_api_weather_v1_weather_proto_rawDescOnce.Do(func() {
+		file_api_weather_v1_weather_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_weather_v1_weather_proto_rawDescData)
+	})
+	return file_api_weather_v1_weather_proto_rawDescData
+}
+
+var file_api_weather_v1_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_api_weather_v1_weather_proto_goTypes = []interface{}{
+	(*Weather)(nil),                 // 0: weather.v1.Weather
+	(*GetWeatherByCEPRequest)(nil),  // 1: weather.v1.GetWeatherByCEPRequest
+	(*GetWeatherByCEPResponse)(nil), // 2: weather.v1.GetWeatherByCEPResponse
+	(*BatchGetWeatherRequest)(nil),  // 3: weather.v1.BatchGetWeatherRequest
+	(*BatchGetWeatherResponse)(nil), // 4: weather.v1.BatchGetWeatherResponse
+	(*BatchResult)(nil),             // 5: weather.v1.BatchResult
+	(*StreamForecastRequest)(nil),   // 6: weather.v1.StreamForecastRequest
+	(*ForecastDay)(nil),             // 7: weather.v1.ForecastDay
+}
+var file_api_weather_v1_weather_proto_depIdxs = []int32{
+	0, // 0: weather.v1.GetWeatherByCEPResponse.weather:type_name -> weather.v1.Weather
+	5, // 1: weather.v1.BatchGetWeatherResponse.results:type_name -> weather.v1.BatchResult
+	0, // 2: weather.v1.BatchResult.weather:type_name -> weather.v1.Weather
+	1, // 3: weather.v1.WeatherService.GetWeatherByCEP:input_type -> weather.v1.GetWeatherByCEPRequest
+	3, // 4: weather.v1.WeatherService.BatchGetWeather:input_type -> weather.v1.BatchGetWeatherRequest
+	6, // 5: weather.v1.WeatherService.StreamForecast:input_type -> weather.v1.StreamForecastRequest
+	2, // 6: weather.v1.WeatherService.GetWeatherByCEP:output_type -> weather.v1.GetWeatherByCEPResponse
+	4, // 7: weather.v1.WeatherService.BatchGetWeather:output_type -> weather.v1.BatchGetWeatherResponse
+	7, // 8: weather.v1.WeatherService.StreamForecast:output_type -> weather.v1.ForecastDay
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_api_weather_v1_weather_proto_init() }
+func file_api_weather_v1_weather_proto_init() {
+	if File_api_weather_v1_weather_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_api_weather_v1_weather_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Weather); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetWeatherByCEPRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetWeatherByCEPResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchGetWeatherRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchGetWeatherResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StreamForecastRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_weather_v1_weather_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ForecastDay); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_api_weather_v1_weather_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_api_weather_v1_weather_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_weather_v1_weather_proto_goTypes,
+		DependencyIndexes: file_api_weather_v1_weather_proto_depIdxs,
+		MessageInfos:      file_api_weather_v1_weather_proto_msgTypes,
+	}.Build()
+	File_api_weather_v1_weather_proto = out.File
+	file_api_weather_v1_weather_proto_rawDesc = nil
+	file_api_weather_v1_weather_proto_goTypes = nil
+	file_api_weather_v1_weather_proto_depIdxs = nil
+}
@@ -0,0 +1,227 @@
+// gRPC API of service-b, for the internal consumers that speak gRPC. It
+// answers the same lookups as the HTTP routes, with temperatures rounded
+// like their default responses.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: api/weather/v1/weather.proto
+
+package weatherv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	WeatherService_GetWeatherByCEP_FullMethodName = "/weather.v1.WeatherService/GetWeatherByCEP"
+	WeatherService_BatchGetWeather_FullMethodName = "/weather.v1.WeatherService/BatchGetWeather"
+	WeatherService_StreamForecast_FullMethodName  = "/weather.v1.WeatherService/StreamForecast"
+)
+
+// WeatherServiceClient is the client API for WeatherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WeatherServiceClient interface {
+	// GetWeatherByCEP returns the current temperatures of the city of a CEP,
+	// like GET /v1/weather/{cep}.
+	GetWeatherByCEP(ctx context.Context, in *GetWeatherByCEPRequest, opts ...grpc.CallOption) (*GetWeatherByCEPResponse, error)
+	// BatchGetWeather resolves up to BATCH_MAX_SIZE CEPs, like
+	// POST /v1/weather:batch. Each CEP fails on its own.
+	BatchGetWeather(ctx context.Context, in *BatchGetWeatherRequest, opts ...grpc.CallOption) (*BatchGetWeatherResponse, error)
+	// StreamForecast sends the daily forecast of the city of a CEP one day
+	// at a time, like GET /v1/forecast/{cep}.
+	StreamForecast(ctx context.Context, in *StreamForecastRequest, opts ...grpc.CallOption) (WeatherService_StreamForecastClient, error)
+}
+
+type weatherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWeatherServiceClient(cc grpc.ClientConnInterface) WeatherServiceClient {
+	return &weatherServiceClient{cc}
+}
+
+func (c *weatherServiceClient) GetWeatherByCEP(ctx context.Context, in *GetWeatherByCEPRequest, opts ...grpc.CallOption) (*GetWeatherByCEPResponse, error) {
+	out := new(GetWeatherByCEPResponse)
+	err := c.cc.Invoke(ctx, WeatherService_GetWeatherByCEP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) BatchGetWeather(ctx context.Context, in *BatchGetWeatherRequest, opts ...grpc.CallOption) (*BatchGetWeatherResponse, error) {
+	out := new(BatchGetWeatherResponse)
+	err := c.cc.Invoke(ctx, WeatherService_BatchGetWeather_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) StreamForecast(ctx context.Context, in *StreamForecastRequest, opts ...grpc.CallOption) (WeatherService_StreamForecastClient, error) {
+	stream, err := c.cc.NewStream(ctx, &WeatherService_ServiceDesc.Streams[0], WeatherService_StreamForecast_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &weatherServiceStreamForecastClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WeatherService_StreamForecastClient interface {
+	Recv() (*ForecastDay, error)
+	grpc.ClientStream
+}
+
+type weatherServiceStreamForecastClient struct {
+	grpc.ClientStream
+}
+
+func (x *weatherServiceStreamForecastClient) Recv() (*ForecastDay, error) {
+	m := new(ForecastDay)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WeatherServiceServer is the server API for WeatherService service.
+// All implementations must embed UnimplementedWeatherServiceServer
+// for forward compatibility
+type WeatherServiceServer interface {
+	// GetWeatherByCEP returns the current temperatures of the city of a CEP,
+	// like GET /v1/weather/{cep}.
+	GetWeatherByCEP(context.Context, *GetWeatherByCEPRequest) (*GetWeatherByCEPResponse, error)
+	// BatchGetWeather resolves up to BATCH_MAX_SIZE CEPs, like
+	// POST /v1/weather:batch. Each CEP fails on its own.
+	BatchGetWeather(context.Context, *BatchGetWeatherRequest) (*BatchGetWeatherResponse, error)
+	// StreamForecast sends the daily forecast of the city of a CEP one day
+	// at a time, like GET /v1/forecast/{cep}.
+	StreamForecast(*StreamForecastRequest, WeatherService_StreamForecastServer) error
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+// UnimplementedWeatherServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedWeatherServiceServer struct {
+}
+
+func (UnimplementedWeatherServiceServer) GetWeatherByCEP(context.Context, *GetWeatherByCEPRequest) (*GetWeatherByCEPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWeatherByCEP not implemented")
+}
+func (UnimplementedWeatherServiceServer) BatchGetWeather(context.Context, *BatchGetWeatherRequest) (*BatchGetWeatherResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetWeather not implemented")
+}
+func (UnimplementedWeatherServiceServer) StreamForecast(*StreamForecastRequest, WeatherService_StreamForecastServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamForecast not implemented")
+}
+func (UnimplementedWeatherServiceServer) mustEmbedUnimplementedWeatherServiceServer() {}
+
+// UnsafeWeatherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WeatherServiceServer will
+// result in compilation errors.
+type UnsafeWeatherServiceServer interface {
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+func RegisterWeatherServiceServer(s grpc.ServiceRegistrar, srv WeatherServiceServer) {
+	s.RegisterService(&WeatherService_ServiceDesc, srv)
+}
+
+func _WeatherService_GetWeatherByCEP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWeatherByCEPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetWeatherByCEP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetWeatherByCEP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetWeatherByCEP(ctx, req.(*GetWeatherByCEPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_BatchGetWeather_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetWeatherRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).BatchGetWeather(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_BatchGetWeather_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).BatchGetWeather(ctx, req.(*BatchGetWeatherRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_StreamForecast_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamForecastRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WeatherServiceServer).StreamForecast(m, &weatherServiceStreamForecastServer{stream})
+}
+
+type WeatherService_StreamForecastServer interface {
+	Send(*ForecastDay) error
+	grpc.ServerStream
+}
+
+type weatherServiceStreamForecastServer struct {
+	grpc.ServerStream
+}
+
+func (x *weatherServiceStreamForecastServer) Send(m *ForecastDay) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// WeatherService_ServiceDesc is the grpc.ServiceDesc for WeatherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WeatherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.WeatherService",
+	HandlerType: (*WeatherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetWeatherByCEP",
+			Handler:    _WeatherService_GetWeatherByCEP_Handler,
+		},
+		{
+			MethodName: "BatchGetWeather",
+			Handler:    _WeatherService_BatchGetWeather_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamForecast",
+			Handler:       _WeatherService_StreamForecast_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/weather/v1/weather.proto",
+}
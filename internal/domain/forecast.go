@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+)
+
+// Forecast lengths accepted by GET /v1/forecast/{cep}?days=N. WeatherAPI
+// serves at most 14 days.
+const (
+	DefaultForecastDays = 3
+	MaxForecastDays     = 14
+)
+
+// DefaultForecastCacheTTL is how long a forecast is served from memory,
+// overridable with the FORECAST_CACHE_TTL environment variable.
+const DefaultForecastCacheTTL = 30 * time.Minute
+
+// Forecast is the daily forecast of a CEP, starting today.
+type Forecast struct {
+	CEP  string
+	City string
+	Days []ForecastDay
+}
+
+// ForecastDay summarises one local day, dated "2006-01-02".
+type ForecastDay struct {
+	Date         string
+	MinC         float64
+	MaxC         float64
+	AvgC         float64
+	ChanceOfRain int
+	Condition    string
+}
+
+// ParseForecastDays parses the ?days= query option. An empty string selects
+// DefaultForecastDays.
+func ParseForecastDays(s string) (int, error) {
+
+	if strings.TrimSpace(s) == "" {
+		return DefaultForecastDays, nil
+	}
+
+	days, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || days < 1 || days > MaxForecastDays {
+		return 0, fmt.Errorf("invalid days %q - must be between 1 and %d", s, MaxForecastDays)
+	}
+
+	return days, nil
+}
+
+// ForecastView is the JSON rendering of a Forecast restricted to the
+// requested units.
+type ForecastView struct {
+	CEP  string            `json:"cep"`
+	City string            `json:"city"`
+	Days []ForecastDayView `json:"days"`
+}
+
+type ForecastDayView struct {
+	Date         string   `json:"date"`
+	MinC         *float64 `json:"min_c,omitempty"`
+	MinF         *float64 `json:"min_f,omitempty"`
+	MinK         *float64 `json:"min_k,omitempty"`
+	MinR         *float64 `json:"min_r,omitempty"`
+	MaxC         *float64 `json:"max_c,omitempty"`
+	MaxF         *float64 `json:"max_f,omitempty"`
+	MaxK         *float64 `json:"max_k,omitempty"`
+	MaxR         *float64 `json:"max_r,omitempty"`
+	AvgC         *float64 `json:"avg_c,omitempty"`
+	AvgF         *float64 `json:"avg_f,omitempty"`
+	AvgK         *float64 `json:"avg_k,omitempty"`
+	AvgR         *float64 `json:"avg_r,omitempty"`
+	ChanceOfRain int      `json:"chance_of_rain"`
+	Condition    string   `json:"condition"`
+}
+
+func (f *Forecast) View(opts ViewOptions) ForecastView {
+
+	v := ForecastView{CEP: f.CEP, City: f.City, Days: make([]ForecastDayView, len(f.Days))}
+
+	for i, d := range f.Days {
+		day := ForecastDayView{Date: d.Date, ChanceOfRain: d.ChanceOfRain, Condition: d.Condition}
+		tMin := Temperature(d.MinC).view(opts)
+		day.MinC, day.MinF, day.MinK, day.MinR = tMin.C, tMin.F, tMin.K, tMin.R
+		tMax := Temperature(d.MaxC).view(opts)
+		day.MaxC, day.MaxF, day.MaxK, day.MaxR = tMax.C, tMax.F, tMax.K, tMax.R
+		tAvg := Temperature(d.AvgC).view(opts)
+		day.AvgC, day.AvgF, day.AvgK, day.AvgR = tAvg.C, tAvg.F, tAvg.K, tAvg.R
+		v.Days[i] = day
+	}
+
+	return v
+}
+
+// forecastCache keeps forecasts in memory for ttl, keyed by forecastKey. A
+// zero ttl disables it.
+type forecastCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]forecastEntry
+}
+
+type forecastEntry struct {
+	forecast  *Forecast
+	expiresAt time.Time
+}
+
+func newForecastCache(ttl time.Duration) *forecastCache {
+	return &forecastCache{ttl: ttl, entries: make(map[string]forecastEntry)}
+}
+
+// forecastKey identifies a forecast by CEP, number of days and language of
+// its condition texts.
+func forecastKey(cep string, days int, lang i18n.Lang) string {
+	return cep + "/" + strconv.Itoa(days) + "/" + string(lang)
+}
+
+func (c *forecastCache) get(key string) (*Forecast, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.forecast, true
+}
+
+func (c *forecastCache) set(key string, f *Forecast) {
+
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.entries[key] = forecastEntry{forecast: f, expiresAt: now.Add(c.ttl)}
+}
@@ -0,0 +1,77 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+)
+
+func TestParseForecastDays(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "", want: domain.DefaultForecastDays},
+		{in: "1", want: 1},
+		{in: " 14 ", want: 14},
+		{in: "0", wantErr: true},
+		{in: "15", wantErr: true},
+		{in: "two", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.ParseForecastDays(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseForecastDays(%q): expected error %v but got %v", tt.in, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseForecastDays(%q): expected %d but got %d", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestForecastCache(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		ttl      string
+		wantHits int
+	}{
+		{name: "cached", ttl: "", wantHits: 1},
+		{name: "cache disabled", ttl: "0", wantHits: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			cepSrv, weatherSrv := setupFakes(t)
+			cepSrv.AddCity("29902555", "Linhares")
+			weatherSrv.AddCity("Linhares", 25)
+			t.Setenv("FORECAST_CACHE_TTL", tt.ttl)
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+
+			for i := 0; i < 2; i++ {
+				l, _ := domain.NewLocation("29902555")
+
+				f, err := s.Forecast(context.Background(), l, 3)
+				if err != nil {
+					t.Fatalf("expected error to be nil and got %v", err)
+				}
+				if f.City != "Linhares" || len(f.Days) != 3 {
+					t.Fatalf("unexpected forecast %+v", f)
+				}
+			}
+
+			if hits := weatherSrv.Hits("Linhares, Brazil"); hits != tt.wantHits {
+				t.Errorf("expected %d provider hits but got %d", tt.wantHits, hits)
+			}
+			if hits := cepSrv.Hits("29902555"); hits != tt.wantHits {
+				t.Errorf("expected %d cep provider hits but got %d", tt.wantHits, hits)
+			}
+		})
+	}
+}
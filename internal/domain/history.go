@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+)
+
+// History is the observed weather of a CEP on a past local date, dated
+// "2006-01-02". Condition is written in Lang.
+type History struct {
+	CEP           string
+	City          string
+	Date          string
+	Lang          i18n.Lang
+	MinC          float64
+	MaxC          float64
+	AvgC          float64
+	TotalPrecipMm float64
+	Condition     string
+}
+
+// ParseHistoryDate parses the ?date= query option, a date in the format
+// "2006-01-02" that is not after today.
+func ParseHistoryDate(s string) (time.Time, error) {
+
+	date, err := time.Parse(time.DateOnly, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q - example: 2024-06-12", s)
+	}
+
+	if date.After(time.Now().UTC()) {
+		return time.Time{}, fmt.Errorf("invalid date %q - must not be in the future", s)
+	}
+
+	return date, nil
+}
+
+// isPast reports whether the date of h is over in every Brazilian time zone
+// (down to UTC-5), so its readings will not change anymore.
+func (h *History) isPast() bool {
+
+	date, err := time.Parse(time.DateOnly, h.Date)
+	if err != nil {
+		return false
+	}
+
+	return date.AddDate(0, 0, 1).Add(5 * time.Hour).Before(time.Now().UTC())
+}
+
+// HistoryView is the JSON rendering of a History restricted to the requested
+// units.
+type HistoryView struct {
+	CEP           string   `json:"cep"`
+	City          string   `json:"city"`
+	Date          string   `json:"date"`
+	MinC          *float64 `json:"min_c,omitempty"`
+	MinF          *float64 `json:"min_f,omitempty"`
+	MinK          *float64 `json:"min_k,omitempty"`
+	MinR          *float64 `json:"min_r,omitempty"`
+	MaxC          *float64 `json:"max_c,omitempty"`
+	MaxF          *float64 `json:"max_f,omitempty"`
+	MaxK          *float64 `json:"max_k,omitempty"`
+	MaxR          *float64 `json:"max_r,omitempty"`
+	AvgC          *float64 `json:"avg_c,omitempty"`
+	AvgF          *float64 `json:"avg_f,omitempty"`
+	AvgK          *float64 `json:"avg_k,omitempty"`
+	AvgR          *float64 `json:"avg_r,omitempty"`
+	TotalPrecipMm float64  `json:"total_precip_mm"`
+	Condition     string   `json:"condition"`
+}
+
+func (h *History) View(opts ViewOptions) HistoryView {
+
+	v := HistoryView{
+		CEP:           h.CEP,
+		City:          h.City,
+		Date:          h.Date,
+		TotalPrecipMm: h.TotalPrecipMm,
+		Condition:     h.Condition,
+	}
+	tMin := Temperature(h.MinC).view(opts)
+	v.MinC, v.MinF, v.MinK, v.MinR = tMin.C, tMin.F, tMin.K, tMin.R
+	tMax := Temperature(h.MaxC).view(opts)
+	v.MaxC, v.MaxF, v.MaxK, v.MaxR = tMax.C, tMax.F, tMax.K, tMax.R
+	tAvg := Temperature(h.AvgC).view(opts)
+	v.AvgC, v.AvgF, v.AvgK, v.AvgR = tAvg.C, tAvg.F, tAvg.K, tAvg.R
+
+	return v
+}
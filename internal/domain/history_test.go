@@ -0,0 +1,79 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+)
+
+func TestParseHistoryDate(t *testing.T) {
+
+	future := time.Now().UTC().AddDate(0, 0, 2).Format(time.DateOnly)
+
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "2024-06-12"},
+		{in: time.Now().UTC().Format(time.DateOnly)},
+		{in: "", wantErr: true},
+		{in: "12/06/2024", wantErr: true},
+		{in: "2024-02-30", wantErr: true},
+		{in: future, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := domain.ParseHistoryDate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHistoryDate(%q): expected error %v but got %v", tt.in, tt.wantErr, err)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		wantHits int
+	}{
+		{name: "past dates are served from the repository", date: time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC), wantHits: 1},
+		{name: "today is not stored", date: today, wantHits: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			cepSrv, weatherSrv := setupFakes(t)
+			cepSrv.AddCity("29902555", "Linhares")
+			weatherSrv.AddCity("Linhares", 25)
+			weatherSrv.AddHistory("Linhares", weather.ForecastDay{
+				Date: "2024-06-12",
+				Day:  weather.DayStats{MinTempC: 19, MaxTempC: 31, AvgTempC: 24, TotalPrecipMm: 12.5, Condition: weather.Condition{Text: "Moderate rain"}},
+			})
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+
+			for i := 0; i < 2; i++ {
+				l, _ := domain.NewLocation("29902555")
+
+				h, err := s.History(context.Background(), l, tt.date)
+				if err != nil {
+					t.Fatalf("expected error to be nil and got %v", err)
+				}
+				if h.City != "Linhares" || h.Date != tt.date.Format(time.DateOnly) {
+					t.Fatalf("unexpected history %+v", h)
+				}
+			}
+
+			if hits := weatherSrv.Hits("Linhares, Brazil"); hits != tt.wantHits {
+				t.Errorf("expected %d provider hits but got %d", tt.wantHits, hits)
+			}
+		})
+	}
+}
@@ -3,14 +3,82 @@ package domain
 import (
 	"fmt"
 	"regexp"
+	"time"
 )
 
+// Location is a CEP resolved to its city and weather.
+//
+// TempC, TempF and TempK hold the feels-like (apparent) temperature, which is
+// what the service has always answered as "temperature"; the actual air
+// temperature and the other readings are in Current.
 type Location struct {
-	CEP   string  `json:"cep"`
-	City  string  `json:"city"`
-	TempC float64 `json:"temp_c"`
-	TempF float64 `json:"temp_f"`
-	TempK float64 `json:"temp_k"`
+	CEP     string      `json:"cep"`
+	City    string      `json:"city"`
+	TempC   float64     `json:"temp_c"`
+	TempF   float64     `json:"temp_f"`
+	TempK   float64     `json:"temp_k"`
+	Lat     float64     `json:"lat,omitempty"`
+	Lon     float64     `json:"lon,omitempty"`
+	Address *Address    `json:"address,omitempty"`
+	Current *Conditions `json:"current,omitempty"`
+
+	AirQuality *AirQuality `json:"air_quality,omitempty"`
+	Alerts     []Alert     `json:"alerts,omitempty"`
+}
+
+// Conditions are the current weather readings for a Location.
+type Conditions struct {
+	TempC         float64   `json:"temp_c"`
+	FeelsLikeC    float64   `json:"feelslike_c"`
+	Humidity      int       `json:"humidity"`
+	WindKph       float64   `json:"wind_kph"`
+	WindDegree    int       `json:"wind_degree"`
+	WindDir       string    `json:"wind_dir"`
+	PressureMb    float64   `json:"pressure_mb"`
+	UV            float64   `json:"uv"`
+	Condition     string    `json:"condition"`
+	ConditionCode int       `json:"condition_code"`
+	ObservedAt    time.Time `json:"observed_at"`
+}
+
+// AirQuality holds pollutant concentrations in μg/m3 and the US EPA index,
+// from 1 (Good) to 6 (Hazardous).
+type AirQuality struct {
+	PM25       float64 `json:"pm2_5"`
+	PM10       float64 `json:"pm10"`
+	O3         float64 `json:"o3"`
+	USEPAIndex int     `json:"us_epa_index"`
+}
+
+var usEPACategories = []string{"", "Good", "Moderate", "Unhealthy for Sensitive Groups", "Unhealthy", "Very Unhealthy", "Hazardous"}
+
+// Category names the US EPA index, e.g. "Moderate".
+func (a *AirQuality) Category() string {
+	return usEPACategories[a.USEPAIndex]
+}
+
+// Alert is an active severe weather alert for a Location.
+type Alert struct {
+	Event       string     `json:"event"`
+	Headline    string     `json:"headline"`
+	Severity    string     `json:"severity"`
+	Urgency     string     `json:"urgency"`
+	Areas       string     `json:"areas,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Instruction string     `json:"instruction,omitempty"`
+	Effective   *time.Time `json:"effective,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+}
+
+// Address is the postal data the CEP provider knows about a Location.
+type Address struct {
+	Street       string `json:"street"`
+	Complement   string `json:"complement,omitempty"`
+	Neighborhood string `json:"neighborhood"`
+	City         string `json:"city"`
+	State        string `json:"state"`
+	IBGE         string `json:"ibge"`
+	DDD          string `json:"ddd"`
 }
 
 func NewLocation(cep string) (*Location, error) {
@@ -47,6 +115,35 @@ func (l *Location) GetCity() string {
 	return l.City
 }
 
+func (l *Location) GetAddress() *Address {
+	return l.Address
+}
+
+func (l *Location) GetCurrent() *Conditions {
+	return l.Current
+}
+
+func (l *Location) GetAirQuality() *AirQuality {
+	return l.AirQuality
+}
+
+func (l *Location) GetAlerts() []Alert {
+	return l.Alerts
+}
+
+func (l *Location) GetLat() float64 {
+	return l.Lat
+}
+
+func (l *Location) GetLon() float64 {
+	return l.Lon
+}
+
+// HasCoordinates reports whether the location was geocoded.
+func (l *Location) HasCoordinates() bool {
+	return l.Lat != 0 && l.Lon != 0
+}
+
 func (l *Location) GetTempC() float64 {
 	return l.TempC
 }
@@ -79,6 +176,59 @@ func (l *Location) SetCity(city string) error {
 	return nil
 }
 
+func (l *Location) SetAddress(address Address) error {
+
+	if len(address.City) < 1 {
+		return fmt.Errorf(" invalid address")
+	}
+
+	l.Address = &address
+	return nil
+}
+
+func (l *Location) SetCurrent(current Conditions) error {
+
+	if current.Humidity < 0 || current.Humidity > 100 {
+		return fmt.Errorf(" invalid humidity")
+	}
+
+	l.Current = &current
+	return nil
+}
+
+func (l *Location) SetAirQuality(aq AirQuality) error {
+
+	if aq.USEPAIndex < 1 || aq.USEPAIndex >= len(usEPACategories) {
+		return fmt.Errorf(" invalid air quality index")
+	}
+
+	l.AirQuality = &aq
+	return nil
+}
+
+func (l *Location) SetAlerts(alerts []Alert) error {
+
+	for _, a := range alerts {
+		if len(a.Event) < 1 && len(a.Headline) < 1 {
+			return fmt.Errorf(" invalid alert")
+		}
+	}
+
+	l.Alerts = alerts
+	return nil
+}
+
+func (l *Location) SetCoordinates(lat, lon float64) error {
+
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return fmt.Errorf(" invalid coordinates")
+	}
+
+	l.Lat = lat
+	l.Lon = lon
+	return nil
+}
+
 func (l *Location) SetTempC(celsius float64) error {
 
 	l.TempC = celsius
@@ -87,12 +237,12 @@ func (l *Location) SetTempC(celsius float64) error {
 }
 
 func (l *Location) setTempF() error {
-	l.TempF = (l.TempC * 1.8) + 32
+	l.TempF = Temperature(l.TempC).Fahrenheit()
 	return nil
 }
 
 func (l *Location) setTempK() error {
-	l.TempK = l.TempC + 273
+	l.TempK = Temperature(l.TempC).Kelvin()
 	return nil
 }
 
@@ -0,0 +1,119 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/recovery"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BatchResult is the outcome of one CEP of a batch. Err carries the same
+// status codes returned by Execute ("404", "422", "500").
+type BatchResult struct {
+	CEP      string
+	Location *Location
+	Err      error
+}
+
+// ExecuteBatch resolves every CEP in ceps running at most workers lookups at
+// a time. Repeated CEPs are resolved once and share the same result. Results
+// are returned in the order of ceps. f selects the optional data retrieved
+// for each CEP, as in ExecuteWith.
+func (s *LocationService) ExecuteBatch(ctx context.Context, ceps []string, workers int, f Fields) []BatchResult {
+
+	tracer := otel.Tracer("service-b")
+
+	ctx, span := tracer.Start(ctx, "service_b-handler-execute-batch")
+	defer span.End()
+
+	unique := make(map[string]*BatchResult)
+	var pending []*BatchResult
+	for _, c := range ceps {
+		if _, ok := unique[c]; ok {
+			continue
+		}
+		unique[c] = &BatchResult{CEP: c}
+		pending = append(pending, unique[c])
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	span.SetAttributes(
+		attribute.Int("batch.size", len(ceps)),
+		attribute.Int("batch.unique", len(pending)),
+		attribute.Int("batch.workers", workers),
+	)
+
+	jobs := make(chan *BatchResult)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				s.executeBatchItem(ctx, r, f)
+			}
+		}()
+	}
+
+	for _, r := range pending {
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+
+	results := make([]BatchResult, len(ceps))
+	for i, c := range ceps {
+		results[i] = *unique[c]
+	}
+
+	return results
+}
+
+func (s *LocationService) executeBatchItem(ctx context.Context, r *BatchResult, f Fields) {
+
+	tracer := otel.Tracer("service-b")
+
+	ctx, span := tracer.Start(ctx, "service_b-handler-execute-batch-item")
+	defer span.End()
+
+	defer recovery.Worker(ctx, func() {
+		span.SetAttributes(attribute.String("service.status", "failed"))
+		r.Location, r.Err = nil, fmt.Errorf("500")
+	})
+
+	span.SetAttributes(attribute.String("cep", r.CEP))
+
+	l, err := NewLocation(r.CEP)
+	if err != nil {
+		span.SetAttributes(attribute.String("service.status", "failed"))
+		r.Err = fmt.Errorf("422")
+		return
+	}
+
+	if ctx.Err() != nil {
+		span.SetAttributes(attribute.String("service.status", "canceled"))
+		r.Err = fmt.Errorf("500")
+		return
+	}
+
+	err = s.ExecuteWith(ctx, l, f)
+	if err != nil {
+		span.SetAttributes(attribute.String("service.status", "failed"))
+		r.Err = err
+		return
+	}
+
+	span.SetAttributes(attribute.String("service.status", "success"))
+	r.Location = l
+}
@@ -0,0 +1,67 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+)
+
+func TestExecuteBatch(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddCity("29902555", "Linhares")
+	cepSrv.AddCity("01308080", "São Paulo")
+	weatherSrv.AddCity("Linhares", 25)
+	weatherSrv.AddCity("São Paulo", 20)
+	cepSrv.SetLatency(50 * time.Millisecond)
+
+	s := domain.NewLocationService(domain.NewLocationRepository())
+
+	ceps := []string{"29902555", "01308080", "29902555", "123", "12345678", "29902555"}
+
+	start := time.Now()
+	results := s.ExecuteBatch(context.Background(), ceps, 4, domain.Fields{})
+	elapsed := time.Since(start)
+
+	tests := []struct {
+		cep      string
+		wantErr  string
+		wantCity string
+	}{
+		{cep: "29902555", wantCity: "Linhares"},
+		{cep: "01308080", wantCity: "São Paulo"},
+		{cep: "29902555", wantCity: "Linhares"},
+		{cep: "123", wantErr: "422"},
+		{cep: "12345678", wantErr: "404"},
+		{cep: "29902555", wantCity: "Linhares"},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("expected %d results but got %d", len(tests), len(results))
+	}
+
+	for i, tt := range tests {
+		r := results[i]
+		if r.CEP != tt.cep {
+			t.Errorf("result %d: expected cep %s but got %s", i, tt.cep, r.CEP)
+		}
+		if got := errString(r.Err); got != tt.wantErr {
+			t.Errorf("result %d: expected error %q but got %q", i, tt.wantErr, got)
+		}
+		if tt.wantCity != "" && (r.Location == nil || r.Location.GetCity() != tt.wantCity) {
+			t.Errorf("result %d: expected city %q but got %+v", i, tt.wantCity, r.Location)
+		}
+	}
+
+	if hits := cepSrv.Hits("29902555"); hits != 1 {
+		t.Errorf("expected repeated cep to be resolved once but got %d calls", hits)
+	}
+
+	// Three distinct valid CEPs with 50ms each must not run sequentially.
+	if elapsed > 120*time.Millisecond {
+		t.Errorf("expected lookups to run concurrently but took %v", elapsed)
+	}
+}
@@ -1,16 +1,33 @@
 package domain
 
-import "log"
+import (
+	"log"
+	"sync"
+	"time"
 
-type LocationRepository struct{}
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+)
+
+// LocationRepository keeps the weather history looked up so far and the
+// calls made to the upstream providers in memory.
+type LocationRepository struct {
+	mu        sync.RWMutex
+	histories map[string]History
+	calls     map[string]int
+}
 
 type LocationRepositoryInterface interface {
 	Get(cep string) *Location
 	Save(*Location) error
+	GetHistory(cep, date string, lang i18n.Lang) (*History, bool)
+	SaveHistory(*History) error
+	RecordUpstreamCall(provider string, at time.Time) error
+	ReserveUpstreamCall(provider string, at time.Time, limit int) (bool, error)
+	UpstreamCalls(provider string, at time.Time) (daily, monthly int)
 }
 
 func NewLocationRepository() *LocationRepository {
-	return &LocationRepository{}
+	return &LocationRepository{histories: make(map[string]History), calls: make(map[string]int)}
 }
 
 func (lr *LocationRepository) Get(cep string) *Location {
@@ -32,3 +49,80 @@ func (lr *LocationRepository) Save(location *Location) error {
 
 	return nil
 }
+
+// GetHistory returns the stored weather of cep on date ("2006-01-02"),
+// described in lang.
+func (lr *LocationRepository) GetHistory(cep, date string, lang i18n.Lang) (*History, bool) {
+
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	h, ok := lr.histories[historyKey(cep, date, lang)]
+	if !ok {
+		return nil, false
+	}
+
+	return &h, true
+}
+
+func (lr *LocationRepository) SaveHistory(h *History) error {
+
+	log.Println("repository save history:", h.CEP, h.Date)
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	lr.histories[historyKey(h.CEP, h.Date, h.Lang)] = *h
+
+	return nil
+}
+
+// RecordUpstreamCall counts a call made to provider at the given time in its
+// day and month, in UTC.
+func (lr *LocationRepository) RecordUpstreamCall(provider string, at time.Time) error {
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	at = at.UTC()
+	lr.calls[provider+"/"+at.Format(time.DateOnly)]++
+	lr.calls[provider+"/"+at.Format("2006-01")]++
+
+	return nil
+}
+
+// ReserveUpstreamCall counts a call made to provider at the given time like
+// RecordUpstreamCall, unless limit calls were already made in its month. It
+// reports whether the call was counted. A limit of zero means no limit.
+func (lr *LocationRepository) ReserveUpstreamCall(provider string, at time.Time, limit int) (bool, error) {
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	at = at.UTC()
+	month := provider + "/" + at.Format("2006-01")
+	if limit > 0 && lr.calls[month] >= limit {
+		return false, nil
+	}
+
+	lr.calls[provider+"/"+at.Format(time.DateOnly)]++
+	lr.calls[month]++
+
+	return true, nil
+}
+
+// UpstreamCalls returns the calls made to provider on the day and in the
+// month of at, in UTC.
+func (lr *LocationRepository) UpstreamCalls(provider string, at time.Time) (daily, monthly int) {
+
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	at = at.UTC()
+
+	return lr.calls[provider+"/"+at.Format(time.DateOnly)], lr.calls[provider+"/"+at.Format("2006-01")]
+}
+
+func historyKey(cep, date string, lang i18n.Lang) string {
+	return cep + "/" + date + "/" + string(lang)
+}
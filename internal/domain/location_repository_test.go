@@ -2,7 +2,11 @@ package domain_test
 
 import (
 	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestLocationRepositoryGet(t *testing.T) {
@@ -28,3 +32,107 @@ func TestLocationRepositorySave(t *testing.T) {
 	}
 
 }
+
+func TestLocationRepositoryHistory(t *testing.T) {
+
+	repo := domain.NewLocationRepository()
+
+	if _, ok := repo.GetHistory("29902555", "2024-06-12", i18n.English); ok {
+		t.Fatalf("expected empty repository")
+	}
+
+	err := repo.SaveHistory(&domain.History{CEP: "29902555", City: "Linhares", Date: "2024-06-12", Lang: i18n.English, MaxC: 30})
+	if err != nil {
+		t.Fatalf("error, method save history in repository return %v\n", err)
+	}
+
+	h, ok := repo.GetHistory("29902555", "2024-06-12", i18n.English)
+	if !ok || h.City != "Linhares" || h.MaxC != 30 {
+		t.Errorf("expected stored history but got %+v", h)
+	}
+
+	if _, ok := repo.GetHistory("29902555", "2024-06-13", i18n.English); ok {
+		t.Errorf("expected no history for another date")
+	}
+
+	if _, ok := repo.GetHistory("29902555", "2024-06-12", i18n.Portuguese); ok {
+		t.Errorf("expected no history for another language")
+	}
+}
+
+func TestLocationRepositoryUpstreamCalls(t *testing.T) {
+
+	repo := domain.NewLocationRepository()
+
+	june12 := time.Date(2024, 6, 12, 23, 30, 0, 0, time.UTC)
+	june13 := june12.Add(time.Hour)
+	july1 := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{june12, june12, june13, july1} {
+		_ = repo.RecordUpstreamCall(domain.ProviderWeatherAPI, at)
+	}
+	_ = repo.RecordUpstreamCall(domain.ProviderViaCEP, june12)
+
+	tests := []struct {
+		name        string
+		provider    string
+		at          time.Time
+		wantDaily   int
+		wantMonthly int
+	}{
+		{name: "same day", provider: domain.ProviderWeatherAPI, at: june12, wantDaily: 2, wantMonthly: 3},
+		{name: "next day", provider: domain.ProviderWeatherAPI, at: june13, wantDaily: 1, wantMonthly: 3},
+		{name: "next month", provider: domain.ProviderWeatherAPI, at: july1, wantDaily: 1, wantMonthly: 1},
+		{name: "other provider", provider: domain.ProviderViaCEP, at: june12, wantDaily: 1, wantMonthly: 1},
+		{name: "no calls", provider: domain.ProviderOpenMeteo, at: june12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			daily, monthly := repo.UpstreamCalls(tt.provider, tt.at)
+			if daily != tt.wantDaily || monthly != tt.wantMonthly {
+				t.Errorf("expected %d/%d calls but got %d/%d", tt.wantDaily, tt.wantMonthly, daily, monthly)
+			}
+		})
+	}
+}
+
+func TestLocationRepositoryReserveUpstreamCall(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		limit     int
+		callers   int
+		wantCalls int
+	}{
+		{name: "within limit", limit: 10, callers: 5, wantCalls: 5},
+		{name: "over limit", limit: 10, callers: 50, wantCalls: 10},
+		{name: "no limit", limit: 0, callers: 50, wantCalls: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			repo := domain.NewLocationRepository()
+			at := time.Date(2024, 6, 12, 12, 0, 0, 0, time.UTC)
+
+			var wg sync.WaitGroup
+			var reserved atomic.Int64
+			for i := 0; i < tt.callers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if ok, _ := repo.ReserveUpstreamCall(domain.ProviderWeatherAPI, at, tt.limit); ok {
+						reserved.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			_, monthly := repo.UpstreamCalls(domain.ProviderWeatherAPI, at)
+			if int(reserved.Load()) != tt.wantCalls || monthly != tt.wantCalls {
+				t.Errorf("expected %d calls but reserved %d and counted %d", tt.wantCalls, reserved.Load(), monthly)
+			}
+		})
+	}
+}
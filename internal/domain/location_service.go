@@ -4,25 +4,71 @@ import (
 	"context"
 	"fmt"
 	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode"
 	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"log"
+	"os"
+	"time"
 )
 
+// WeatherProvider is the source of current conditions, forecasts and
+// history. *weather.Client implements it against WeatherAPI.
+type WeatherProvider interface {
+	GetConditions(ctx context.Context, q weather.Query, inc weather.Include) (*weather.WeatherResponse, error)
+	GetForecast(ctx context.Context, q weather.Query, days int) (*weather.ForecastResponse, error)
+	GetHistory(ctx context.Context, q weather.Query, day time.Time) (*weather.ForecastResponse, error)
+}
+
 type LocationService struct {
-	repo LocationRepositoryInterface
+	repo          LocationRepositoryInterface
+	cepClient     *cep.Client
+	geocodeClient *geocode.Client
+	weatherClient WeatherProvider
+	forecasts     *forecastCache
 }
 
 type LocationServiceInterface interface{}
 
 func NewLocationService(repo LocationRepositoryInterface) *LocationService {
 	return &LocationService{
-		repo: repo,
+		repo:          repo,
+		cepClient:     cep.NewClient(""),
+		geocodeClient: geocode.NewClient(""),
+		weatherClient: newBudgetedWeather(weather.NewClient("", ""), repo, budgetFromEnv()),
+		forecasts:     newForecastCache(forecastCacheTTL()),
+	}
+}
+
+// forecastCacheTTL reads FORECAST_CACHE_TTL, a duration such as "10m"; "0"
+// disables the forecast cache.
+func forecastCacheTTL() time.Duration {
+
+	v := os.Getenv("FORECAST_CACHE_TTL")
+	if v == "" {
+		return DefaultForecastCacheTTL
 	}
+
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl < 0 {
+		log.Println("invalid FORECAST_CACHE_TTL, using default:", v)
+		return DefaultForecastCacheTTL
+	}
+
+	return ttl
 }
 
 func (s *LocationService) Execute(ctx context.Context, l *Location) error {
+	return s.ExecuteWith(ctx, l, Fields{})
+}
+
+// ExecuteWith is Execute also retrieving the air quality and alerts of l
+// when f asks for them, as the weather provider only sends them on demand.
+func (s *LocationService) ExecuteWith(ctx context.Context, l *Location, f Fields) error {
 
 	tracer := otel.Tracer("service-b")
 
@@ -37,7 +83,8 @@ func (s *LocationService) Execute(ctx context.Context, l *Location) error {
 	ctxCity, spanCity := tracer.Start(ctx, "service_b-handler-execute-city")
 
 	spanCity.SetAttributes(attribute.String("service.action", "get city"))
-	city, err := cep.GetCity(l.GetCEP())
+	recordUpstreamCall(ctxCity, s.repo, ProviderViaCEP)
+	address, err := s.cepClient.GetAddress(ctxCity, l.GetCEP())
 	if err != nil {
 		log.Println("error to get cep:", l.GetCEP())
 		spanCity.SetAttributes(attribute.String("service.status", "failed"))
@@ -45,6 +92,7 @@ func (s *LocationService) Execute(ctx context.Context, l *Location) error {
 		return fmt.Errorf("404")
 	}
 
+	city := address.Localidade
 	if city == "" {
 		log.Println("error to get cep:", l.GetCEP())
 		spanCity.SetAttributes(attribute.String("service.status", "failed"))
@@ -52,56 +100,162 @@ func (s *LocationService) Execute(ctx context.Context, l *Location) error {
 		return fmt.Errorf("404")
 	}
 
-	err = l.SetCity(city)
+	err = setAddress(l, address)
 	if err != nil {
+		spanCity.End()
 		return fmt.Errorf("500")
 	}
+	spanCity.SetAttributes(
+		attribute.String("address.state", address.Uf),
+		attribute.String("address.ibge", address.Ibge),
+	)
 	spanCity.SetAttributes(attribute.String("service.status", "success"))
 	spanCity.End()
 
-	_, spanWeather := tracer.Start(ctxCity, "service_b-handler-execute-weather")
+	ctxGeocode, spanGeocode := tracer.Start(ctxCity, "service_b-handler-execute-geocode")
+
+	spanGeocode.SetAttributes(attribute.String("service.action", "geocode"))
+	source := s.geocode(ctxGeocode, l)
+	spanGeocode.SetAttributes(attribute.String("geocode.source", source))
+	if l.HasCoordinates() {
+		spanGeocode.SetAttributes(
+			attribute.Float64("geocode.lat", l.GetLat()),
+			attribute.Float64("geocode.lon", l.GetLon()),
+		)
+	}
+	spanGeocode.End()
+
+	ctxWeather, spanWeather := tracer.Start(ctxCity, "service_b-handler-execute-weather")
 	defer spanWeather.End()
 
-	spanWeather.SetAttributes(attribute.String("service.action", "get weather"))
-	wc, err := weather.GetWeather(l.GetCity())
+	q := weatherQuery(ctxWeather, l)
+
+	spanWeather.SetAttributes(
+		attribute.String("service.action", "get weather"),
+		attribute.String("weather.query", q.String()),
+	)
+	current, err := s.weatherClient.GetConditions(ctxWeather, q, weather.Include{AirQuality: f.AirQuality, Alerts: f.Alerts})
 	if err != nil {
-		log.Println("error to execute and get weather for city:", city)
+		log.Println("error to execute and get weather for city:", city, err)
 		spanWeather.SetAttributes(attribute.String("service.status", "failed"))
+		return weatherError(err)
+	}
+
+	err = checkWeatherLocation(l, current.Location)
+	if err != nil {
+		log.Println("error to match weather location:", err)
+		recordLocationMismatch(spanWeather, l, current.Location, err)
 		return fmt.Errorf("500")
 	}
-	err = l.SetTemperatures(wc)
+
+	err = setConditions(l, current.Current)
 	if err != nil {
 		log.Println("error to set temperatures")
 		spanWeather.SetAttributes(attribute.String("service.status", "failed"))
 		return fmt.Errorf("500")
 	}
+	spanWeather.SetAttributes(
+		attribute.Float64("weather.temp_c", current.Current.TempC),
+		attribute.Float64("weather.feelslike_c", current.Current.FeelsLikeC),
+		attribute.String("weather.condition", current.Current.Condition.Text),
+	)
+
+	if f.AirQuality {
+		setAirQuality(l, current.Current.AirQuality)
+		if aq := l.GetAirQuality(); aq != nil {
+			spanWeather.SetAttributes(
+				attribute.Float64("air_quality.pm2_5", aq.PM25),
+				attribute.Float64("air_quality.pm10", aq.PM10),
+				attribute.Float64("air_quality.o3", aq.O3),
+				attribute.Int("air_quality.us_epa_index", aq.USEPAIndex),
+			)
+		}
+	}
+
+	if f.Alerts {
+		setAlerts(l, current.Alerts.Alert)
+		spanWeather.SetAttributes(attribute.Int("weather.alerts", len(l.GetAlerts())))
+		for _, a := range l.GetAlerts() {
+			spanWeather.AddEvent("weather.alert", trace.WithAttributes(
+				attribute.String("alert.event", a.Event),
+				attribute.String("alert.severity", a.Severity),
+				attribute.String("alert.headline", a.Headline),
+			))
+		}
+	}
 
 	log.Println("execute finish with success:", l)
 	spanWeather.SetAttributes(attribute.String("service.status", "success"))
 	return nil
 }
 
-func (s *LocationService) GetCEP(l *Location) error {
+func (s *LocationService) GetCEP(ctx context.Context, l *Location) error {
 
-	city, err := cep.GetCity(l.GetCEP())
+	recordUpstreamCall(ctx, s.repo, ProviderViaCEP)
+	address, err := s.cepClient.GetAddress(ctx, l.GetCEP())
 	if err != nil {
 		log.Println("error to get cep:", l.GetCEP())
 		return fmt.Errorf("404")
 	}
 
-	if city == "" {
+	if address.Localidade == "" {
 		log.Println("error to get cep:", l.GetCEP())
 		return fmt.Errorf("404")
 	}
 
-	err = l.SetCity(city)
+	err = setAddress(l, address)
 	if err != nil {
 		return fmt.Errorf("500")
 	}
 
+	s.geocode(ctx, l)
+
 	return nil
 }
 
+// geocode sets the coordinates of l, asking the geocoding provider first and
+// falling back to the centroid of the CEP's municipality. A location that
+// cannot be geocoded is left without coordinates, which is not an error.
+// It returns the source of the coordinates: "provider", "ibge-centroid" or
+// "none".
+func (s *LocationService) geocode(ctx context.Context, l *Location) string {
+
+	recordUpstreamCall(ctx, s.repo, ProviderGeocode)
+	coordinates, err := s.geocodeClient.GetCoordinates(ctx, l.GetCEP())
+	if err == nil && l.SetCoordinates(coordinates.Lat, coordinates.Lon) == nil {
+		return "provider"
+	}
+	log.Println("error to geocode cep, trying municipality centroid:", l.GetCEP(), err)
+
+	if a := l.GetAddress(); a != nil {
+		if centroid, ok := geocode.Centroid(a.IBGE); ok {
+			_ = l.SetCoordinates(centroid.Lat, centroid.Lon)
+			return "ibge-centroid"
+		}
+	}
+
+	return "none"
+}
+
+// setAddress copies the city and address returned by the CEP provider into l.
+func setAddress(l *Location, address *cep.ViaCepResponse) error {
+
+	err := l.SetCity(address.Localidade)
+	if err != nil {
+		return err
+	}
+
+	return l.SetAddress(Address{
+		Street:       address.Logradouro,
+		Complement:   address.Complemento,
+		Neighborhood: address.Bairro,
+		City:         address.Localidade,
+		State:        address.Uf,
+		IBGE:         address.Ibge,
+		DDD:          address.Ddd,
+	})
+}
+
 func (s *LocationService) GetWeather(l *Location) error {
 
 	if l.GetCity() == "" {
@@ -110,13 +264,20 @@ func (s *LocationService) GetWeather(l *Location) error {
 	}
 
 	city := l.GetCity()
+	ctx := context.Background()
 
-	wc, err := weather.GetWeather(l.GetCity())
+	current, err := s.weatherClient.GetConditions(ctx, weatherQuery(ctx, l), weather.Include{})
 	if err != nil {
-		log.Println("error to execute and get weather for city:", city)
+		log.Println("error to execute and get weather for city:", city, err)
+		return weatherError(err)
+	}
+
+	err = checkWeatherLocation(l, current.Location)
+	if err != nil {
+		log.Println("error to match weather location:", err)
 		return fmt.Errorf("500")
 	}
-	_ = l.SetTemperatures(wc)
+	_ = setConditions(l, current.Current)
 
 	err = s.repo.Save(l)
 	if err != nil {
@@ -125,3 +286,327 @@ func (s *LocationService) GetWeather(l *Location) error {
 
 	return nil
 }
+
+// RefreshConditions asks the weather provider for the current conditions of
+// l, already resolved by GetCEP, without querying the CEP providers again.
+func (s *LocationService) RefreshConditions(ctx context.Context, l *Location) error {
+
+	current, err := s.weatherClient.GetConditions(ctx, weatherQuery(ctx, l), weather.Include{})
+	if err != nil {
+		log.Println("error to refresh weather for city:", l.GetCity(), err)
+		return weatherError(err)
+	}
+
+	err = checkWeatherLocation(l, current.Location)
+	if err != nil {
+		log.Println("error to match weather location:", err)
+		return fmt.Errorf("500")
+	}
+
+	err = setConditions(l, current.Current)
+	if err != nil {
+		log.Println("error to set temperatures")
+		return fmt.Errorf("500")
+	}
+
+	return nil
+}
+
+// setConditions copies the readings returned by the weather provider into l.
+// The legacy temperatures keep carrying the feels-like temperature.
+func setConditions(l *Location, current weather.CurrentWeather) error {
+
+	err := l.SetTemperatures(current.FeelsLikeC)
+	if err != nil {
+		return err
+	}
+
+	conditions := Conditions{
+		TempC:         current.TempC,
+		FeelsLikeC:    current.FeelsLikeC,
+		Humidity:      current.Humidity,
+		WindKph:       current.WindKph,
+		WindDegree:    current.WindDegree,
+		WindDir:       current.WindDir,
+		PressureMb:    current.PressureMb,
+		UV:            current.UV,
+		Condition:     current.Condition.Text,
+		ConditionCode: current.Condition.Code,
+	}
+	if current.LastUpdatedEpoch > 0 {
+		conditions.ObservedAt = time.Unix(current.LastUpdatedEpoch, 0).UTC()
+	}
+
+	return l.SetCurrent(conditions)
+}
+
+// Forecast resolves the CEP of l and returns its daily forecast for days
+// days, starting today. Forecasts are served from memory for the cache TTL,
+// without querying the CEP or weather providers.
+func (s *LocationService) Forecast(ctx context.Context, l *Location, days int) (*Forecast, error) {
+
+	tracer := otel.Tracer("service-b")
+
+	ctx, span := tracer.Start(ctx, "service_b-handler-forecast")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("service.name", "service-b"),
+		attribute.Int("forecast.days", days),
+	)
+
+	key := forecastKey(l.GetCEP(), days, i18n.FromContext(ctx))
+
+	if f, ok := s.forecasts.get(key); ok {
+		span.SetAttributes(attribute.String("forecast.cache", "hit"))
+		return f, nil
+	}
+	span.SetAttributes(attribute.String("forecast.cache", "miss"))
+
+	ctxCity, spanCity := tracer.Start(ctx, "service_b-handler-forecast-city")
+
+	spanCity.SetAttributes(attribute.String("service.action", "get city"))
+	err := s.GetCEP(ctxCity, l)
+	if err != nil {
+		spanCity.SetAttributes(attribute.String("service.status", "failed"))
+		spanCity.End()
+		return nil, err
+	}
+	spanCity.SetAttributes(attribute.String("service.status", "success"))
+	spanCity.End()
+
+	ctxWeather, spanWeather := tracer.Start(ctxCity, "service_b-handler-forecast-weather")
+	defer spanWeather.End()
+
+	q := weatherQuery(ctxWeather, l)
+
+	spanWeather.SetAttributes(
+		attribute.String("service.action", "get forecast"),
+		attribute.String("weather.query", q.String()),
+	)
+	response, err := s.weatherClient.GetForecast(ctxWeather, q, days)
+	if err != nil {
+		log.Println("error to get forecast for city:", l.GetCity(), err)
+		spanWeather.SetAttributes(attribute.String("service.status", "failed"))
+		return nil, weatherError(err)
+	}
+
+	err = checkWeatherLocation(l, response.Location)
+	if err != nil {
+		log.Println("error to match weather location:", err)
+		recordLocationMismatch(spanWeather, l, response.Location, err)
+		return nil, fmt.Errorf("500")
+	}
+
+	f := &Forecast{CEP: l.GetCEP(), City: l.GetCity(), Days: make([]ForecastDay, len(response.Forecast.ForecastDay))}
+	for i, d := range response.Forecast.ForecastDay {
+		f.Days[i] = ForecastDay{
+			Date:         d.Date,
+			MinC:         d.Day.MinTempC,
+			MaxC:         d.Day.MaxTempC,
+			AvgC:         d.Day.AvgTempC,
+			ChanceOfRain: d.Day.DailyChanceOfRain,
+			Condition:    d.Day.Condition.Text,
+		}
+	}
+	spanWeather.SetAttributes(attribute.String("service.status", "success"))
+
+	s.forecasts.set(key, f)
+
+	return f, nil
+}
+
+// History returns the observed weather of the CEP of l on date. Readings of
+// past dates are stored in the repository and served from it afterwards,
+// without querying the CEP or weather providers.
+func (s *LocationService) History(ctx context.Context, l *Location, date time.Time) (*History, error) {
+
+	tracer := otel.Tracer("service-b")
+
+	ctx, span := tracer.Start(ctx, "service_b-handler-history")
+	defer span.End()
+
+	day := date.Format(time.DateOnly)
+
+	span.SetAttributes(
+		attribute.String("service.name", "service-b"),
+		attribute.String("history.date", day),
+	)
+
+	lang := i18n.FromContext(ctx)
+
+	if h, ok := s.repo.GetHistory(l.GetCEP(), day, lang); ok {
+		span.SetAttributes(attribute.String("history.source", "repository"))
+		return h, nil
+	}
+	span.SetAttributes(attribute.String("history.source", "provider"))
+
+	ctxCity, spanCity := tracer.Start(ctx, "service_b-handler-history-city")
+
+	spanCity.SetAttributes(attribute.String("service.action", "get city"))
+	err := s.GetCEP(ctxCity, l)
+	if err != nil {
+		spanCity.SetAttributes(attribute.String("service.status", "failed"))
+		spanCity.End()
+		return nil, err
+	}
+	spanCity.SetAttributes(attribute.String("service.status", "success"))
+	spanCity.End()
+
+	ctxWeather, spanWeather := tracer.Start(ctxCity, "service_b-handler-history-weather")
+	defer spanWeather.End()
+
+	q := weatherQuery(ctxWeather, l)
+
+	spanWeather.SetAttributes(
+		attribute.String("service.action", "get history"),
+		attribute.String("weather.query", q.String()),
+	)
+	response, err := s.weatherClient.GetHistory(ctxWeather, q, date)
+	if err != nil {
+		log.Println("error to get history for city:", l.GetCity(), err)
+		spanWeather.SetAttributes(attribute.String("service.status", "failed"))
+		return nil, weatherError(err)
+	}
+
+	err = checkWeatherLocation(l, response.Location)
+	if err != nil {
+		log.Println("error to match weather location:", err)
+		recordLocationMismatch(spanWeather, l, response.Location, err)
+		return nil, fmt.Errorf("500")
+	}
+
+	d := response.Forecast.ForecastDay[0]
+	h := &History{
+		CEP:           l.GetCEP(),
+		City:          l.GetCity(),
+		Date:          day,
+		Lang:          lang,
+		MinC:          d.Day.MinTempC,
+		MaxC:          d.Day.MaxTempC,
+		AvgC:          d.Day.AvgTempC,
+		TotalPrecipMm: d.Day.TotalPrecipMm,
+		Condition:     d.Day.Condition.Text,
+	}
+	spanWeather.SetAttributes(attribute.String("service.status", "success"))
+
+	if h.isPast() {
+		err = s.repo.SaveHistory(h)
+		if err != nil {
+			log.Printf("error to save history: %v\n", h)
+		}
+	}
+
+	return h, nil
+}
+
+// recordLocationMismatch marks span as failed because the weather provider
+// resolved l to the wrong place.
+func recordLocationMismatch(span trace.Span, l *Location, got weather.WeatherLocation, err error) {
+
+	expected := ""
+	if a := l.GetAddress(); a != nil {
+		expected = StateName(a.State)
+	}
+
+	span.AddEvent("weather.location_mismatch", trace.WithAttributes(
+		attribute.String("weather.expected_region", expected),
+		attribute.String("weather.region", got.Region),
+		attribute.String("weather.country", got.Country),
+		attribute.String("weather.name", got.Name),
+	))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(attribute.String("service.status", "failed"))
+}
+
+// setAirQuality copies the air quality returned by the weather provider into
+// l. Readings without a valid index are dropped.
+func setAirQuality(l *Location, aq *weather.AirQuality) {
+
+	if aq == nil {
+		return
+	}
+
+	err := l.SetAirQuality(AirQuality{PM25: aq.PM25, PM10: aq.PM10, O3: aq.O3, USEPAIndex: aq.USEPAIndex})
+	if err != nil {
+		log.Println("error to set air quality:", err)
+	}
+}
+
+// setAlerts copies the alerts returned by the weather provider into l.
+func setAlerts(l *Location, alerts []weather.Alert) {
+
+	out := make([]Alert, 0, len(alerts))
+	for _, a := range alerts {
+		out = append(out, Alert{
+			Event:       a.Event,
+			Headline:    a.Headline,
+			Severity:    a.Severity,
+			Urgency:     a.Urgency,
+			Areas:       a.Areas,
+			Description: a.Desc,
+			Instruction: a.Instruction,
+			Effective:   parseAlertTime(a.Effective),
+			Expires:     parseAlertTime(a.Expires),
+		})
+	}
+
+	err := l.SetAlerts(out)
+	if err != nil {
+		log.Println("error to set alerts:", err)
+	}
+}
+
+func parseAlertTime(s string) *time.Time {
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
+
+// weatherLangs maps response languages to the weather provider's language
+// codes. English is the provider's default.
+var weatherLangs = map[i18n.Lang]string{
+	i18n.Portuguese: "pt",
+	i18n.Spanish:    "es",
+}
+
+// weatherQuery qualifies the city with its state and country, or uses its
+// coordinates when known, so that homonymous cities are not mixed up by the
+// weather provider. Condition texts are asked in the language of ctx.
+func weatherQuery(ctx context.Context, l *Location) weather.Query {
+
+	q := weather.Query{City: l.GetCity(), Country: Country, Lang: weatherLangs[i18n.FromContext(ctx)]}
+	if a := l.GetAddress(); a != nil {
+		q.Region = StateName(a.State)
+	}
+	if l.HasCoordinates() {
+		q.Lat, q.Lon = l.GetLat(), l.GetLon()
+	}
+
+	return q
+}
+
+// checkWeatherLocation fails when the weather provider resolved the query to
+// a place outside the CEP's country or state.
+func checkWeatherLocation(l *Location, got weather.WeatherLocation) error {
+
+	if got.Country != "" && !samePlace(got.Country, Country) {
+		return fmt.Errorf("weather for %s resolved to %s, %s", l.GetCity(), got.Name, got.Country)
+	}
+
+	a := l.GetAddress()
+	if a == nil || StateName(a.State) == "" {
+		return nil
+	}
+
+	if !samePlace(got.Region, StateName(a.State)) {
+		return fmt.Errorf("weather for %s, %s resolved to %s, %s", l.GetCity(), a.State, got.Name, got.Region)
+	}
+
+	return nil
+}
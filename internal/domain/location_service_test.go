@@ -2,32 +2,287 @@ package domain_test
 
 import (
 	"context"
-	_ "embed"
-	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
-	"os"
-	"strings"
+	"net/http"
 	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	viacep "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep/ceptest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather/weathertest"
 )
 
+// setupFakes points the location service at fake ViaCEP, AwesomeAPI and
+// WeatherAPI servers for the duration of the test. The geocoding fake knows
+// no CEP unless a test points GEOCODE_BASE_URL at its own fake.
+func setupFakes(t *testing.T) (*ceptest.Server, *weathertest.Server) {
+	t.Helper()
+
+	cepSrv := ceptest.NewServer()
+	t.Cleanup(cepSrv.Close)
+
+	geocodeSrv := geocodetest.NewServer()
+	t.Cleanup(geocodeSrv.Close)
+	t.Setenv("GEOCODE_BASE_URL", geocodeSrv.URL)
+
+	weatherSrv := weathertest.NewServer()
+	t.Cleanup(weatherSrv.Close)
+
+	t.Setenv("VIACEP_BASE_URL", cepSrv.URL)
+	t.Setenv("WEATHER_API_BASE_URL", weatherSrv.URL)
+	t.Setenv("WEATHER_API_KEY", "test-key")
+	weatherSrv.SetAPIKey("test-key")
+
+	return cepSrv, weatherSrv
+}
+
 func TestExecute(t *testing.T) {
 
-	//os.Setenv("WEATHER_API_KEY", "011d847082bc437cbcc192904241206")
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddCity("05541000", "São Paulo")
+	weatherSrv.AddCity("São Paulo", 25)
+
+	cepSrv.AddCity("29902555", "Linhares")
+
+	cepSrv.SetResponse("11111111", ceptest.Response{Status: http.StatusInternalServerError})
+
+	tests := []struct {
+		name     string
+		cep      string
+		wantErr  string
+		wantCity string
+		wantC    float64
+		wantF    float64
+		wantK    float64
+	}{
+		{name: "success", cep: "05541000", wantCity: "São Paulo", wantC: 25, wantF: 77, wantK: 298.15},
+		{name: "cep not found", cep: "12345678", wantErr: "404"},
+		{name: "cep provider failure", cep: "11111111", wantErr: "404"},
+		{name: "weather not found", cep: "29902555", wantErr: "500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+
+			l, err := domain.NewLocation(tt.cep)
+			if err != nil {
+				t.Fatalf("location constructor cannot return error: %v", err)
+			}
+
+			err = s.Execute(context.Background(), l)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q but got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+
+			if l.GetCity() != tt.wantCity {
+				t.Errorf("expected city %q but got %q", tt.wantCity, l.GetCity())
+			}
+			if l.GetTempC() != tt.wantC || l.GetTempF() != tt.wantF || l.GetTempK() != tt.wantK {
+				t.Errorf("expected temperatures %v/%v/%v but got %v/%v/%v",
+					tt.wantC, tt.wantF, tt.wantK, l.GetTempC(), l.GetTempF(), l.GetTempK())
+			}
+		})
+	}
+}
+
+func TestGetCEPAndWeather(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddCity("01308080", "São Paulo")
+	weatherSrv.AddCity("São Paulo", 20)
+
+	tests := []struct {
+		name        string
+		cep         string
+		wantCEP     string
+		wantWeather string
+	}{
+		{name: "found", cep: "01308080"},
+		{name: "cep not found", cep: "12345678", wantCEP: "404", wantWeather: "404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+			l, _ := domain.NewLocation(tt.cep)
+
+			err := s.GetCEP(context.Background(), l)
+			if got := errString(err); got != tt.wantCEP {
+				t.Fatalf("GetCEP: expected error %q but got %q", tt.wantCEP, got)
+			}
+
+			err = s.GetWeather(l)
+			if got := errString(err); got != tt.wantWeather {
+				t.Fatalf("GetWeather: expected error %q but got %q", tt.wantWeather, got)
+			}
+		})
+	}
+}
+
+func TestExecuteDisambiguatesHomonymousCities(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddAddress("64900000", viacep.ViaCepResponse{Localidade: "Bom Jesus", Uf: "PI"})
+	cepSrv.AddAddress("95290000", viacep.ViaCepResponse{Localidade: "Bom Jesus", Uf: "RS"})
+	cepSrv.AddAddress("01001000", viacep.ViaCepResponse{Localidade: "Manila", Uf: "SP"})
+
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Bom Jesus", Region: "Rio Grande do Sul", Country: "Brazil"}, weather.CurrentWeather{FeelsLikeC: 10})
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Bom Jesus", Region: "Piaui", Country: "Brazil"}, weather.CurrentWeather{FeelsLikeC: 35})
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Manila", Region: "", Country: "Philippines"}, weather.CurrentWeather{FeelsLikeC: 30})
+
+	tests := []struct {
+		name    string
+		cep     string
+		wantC   float64
+		wantErr string
+	}{
+		{name: "piaui", cep: "64900000", wantC: 35},
+		{name: "rio grande do sul", cep: "95290000", wantC: 10},
+		{name: "resolved abroad", cep: "01001000", wantErr: "500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+			l, _ := domain.NewLocation(tt.cep)
+
+			err := s.Execute(context.Background(), l)
+			if got := errString(err); got != tt.wantErr {
+				t.Fatalf("expected error %q but got %q", tt.wantErr, got)
+			}
+			if err == nil && l.GetTempC() != tt.wantC {
+				t.Errorf("expected %v but got %v", tt.wantC, l.GetTempC())
+			}
+		})
+	}
+}
+
+func TestExecuteGeocodes(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	geocodeSrv := geocodetest.NewServer()
+	t.Cleanup(geocodeSrv.Close)
+	t.Setenv("GEOCODE_BASE_URL", geocodeSrv.URL)
+
+	cepSrv.AddAddress("01308080", viacep.ViaCepResponse{Localidade: "São Paulo", Uf: "SP", Ibge: "3550308"})
+	cepSrv.AddAddress("64900000", viacep.ViaCepResponse{Localidade: "Bom Jesus", Uf: "PI", Ibge: "2201903"})
+	cepSrv.AddAddress("29902555", viacep.ViaCepResponse{Localidade: "Linhares", Uf: "ES", Ibge: "9999999"})
 
-	b, err := os.ReadFile("../../.env")
-	if err != nil {
-		t.Errorf("file .env not found")
+	geocodeSrv.AddCoordinates("01308080", -23.5505, -46.6333)
+
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "São Paulo", Region: "Sao Paulo", Country: "Brazil", Lat: -23.5505, Lon: -46.6333}, weather.CurrentWeather{FeelsLikeC: 20})
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Bom Jesus", Region: "Piaui", Country: "Brazil", Lat: -9.07124, Lon: -44.3591}, weather.CurrentWeather{FeelsLikeC: 35})
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Linhares", Region: "Espirito Santo", Country: "Brazil"}, weather.CurrentWeather{FeelsLikeC: 25})
+
+	tests := []struct {
+		name    string
+		cep     string
+		wantLat float64
+		wantLon float64
+		wantC   float64
+	}{
+		{name: "from provider", cep: "01308080", wantLat: -23.5505, wantLon: -46.6333, wantC: 20},
+		{name: "from ibge centroid", cep: "64900000", wantLat: -9.07124, wantLon: -44.3591, wantC: 35},
+		{name: "not geocoded", cep: "29902555", wantC: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+			l, _ := domain.NewLocation(tt.cep)
+
+			err := s.Execute(context.Background(), l)
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+			if l.GetLat() != tt.wantLat || l.GetLon() != tt.wantLon {
+				t.Errorf("expected %v,%v but got %v,%v", tt.wantLat, tt.wantLon, l.GetLat(), l.GetLon())
+			}
+			if l.GetTempC() != tt.wantC {
+				t.Errorf("expected %v but got %v", tt.wantC, l.GetTempC())
+			}
+		})
 	}
+}
+
+func TestExecuteWithAirQualityAndAlerts(t *testing.T) {
 
-	env := string(b)
-	list := strings.Split(env, "=")
-	if list[0] == "WEATHER_API_KEY" {
-		_ = os.Setenv(list[0], list[1])
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddCity("29902555", "Linhares")
+	weatherSrv.AddCity("Linhares", 25)
+	weatherSrv.SetAirQuality("Linhares", weather.AirQuality{PM25: 40.2, PM10: 55, O3: 80, USEPAIndex: 4})
+	weatherSrv.AddAlert("Linhares", weather.Alert{
+		Event:     "Tempestade",
+		Headline:  "Alerta de tempestade",
+		Severity:  "Severe",
+		Effective: "2024-06-12T10:00:00-03:00",
+		Expires:   "not a date",
+	})
+
+	tests := []struct {
+		name           string
+		fields         domain.Fields
+		wantAirQuality bool
+		wantAlerts     int
+	}{
+		{name: "not requested", fields: domain.Fields{}},
+		{name: "air quality", fields: domain.Fields{AirQuality: true}, wantAirQuality: true},
+		{name: "alerts", fields: domain.Fields{Alerts: true}, wantAlerts: 1},
 	}
 
-	r := domain.NewLocationRepository()
-	s := domain.NewLocationService(r)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+			l, _ := domain.NewLocation("29902555")
+
+			err := s.ExecuteWith(context.Background(), l, tt.fields)
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
 
-	l, _ := domain.NewLocation("05541000")
+			aq := l.GetAirQuality()
+			if (aq != nil) != tt.wantAirQuality {
+				t.Fatalf("expected air quality %v but got %+v", tt.wantAirQuality, aq)
+			}
+			if aq != nil && (aq.PM25 != 40.2 || aq.USEPAIndex != 4 || aq.Category() != "Unhealthy") {
+				t.Errorf("unexpected air quality %+v", aq)
+			}
 
-	s.Execute(context.Background(), l)
+			alerts := l.GetAlerts()
+			if len(alerts) != tt.wantAlerts {
+				t.Fatalf("expected %d alerts but got %+v", tt.wantAlerts, alerts)
+			}
+			if tt.wantAlerts > 0 {
+				a := alerts[0]
+				if a.Event != "Tempestade" || a.Severity != "Severe" || a.Effective == nil || a.Expires != nil {
+					t.Errorf("unexpected alert %+v", a)
+				}
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
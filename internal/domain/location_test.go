@@ -41,7 +41,103 @@ func TestLocationTemperatureK(t *testing.T) {
 	}
 
 	kelvin := l.GetTempK()
-	if kelvin != 273.0 {
+	if kelvin != 273.15 {
 		t.Errorf("location constructor cannot return error")
 	}
 }
+
+func TestParseUnits(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    domain.Units
+		wantErr bool
+	}{
+		{in: "", want: domain.AllUnits},
+		{in: "c", want: domain.Units{C: true}},
+		{in: "F, k", want: domain.Units{F: true, K: true}},
+		{in: "c,r", want: domain.Units{C: true, R: true}},
+		{in: "c,x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.ParseUnits(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseUnits(%q): expected error %v but got %v", tt.in, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseUnits(%q): expected %+v but got %+v", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestLocationView(t *testing.T) {
+
+	l, _ := domain.NewLocation("12345678")
+	_ = l.SetCity("Linhares")
+	_ = l.SetTemperatures(25)
+
+	v := l.View(domain.ViewOptions{Units: domain.Units{C: true, K: true}})
+	if v.City != "Linhares" || v.TempC == nil || *v.TempC != 25 || v.TempK == nil || *v.TempK != 298.15 {
+		t.Errorf("unexpected view %+v", v)
+	}
+	if v.TempF != nil {
+		t.Errorf("temp_f should be omitted when not requested")
+	}
+	if v.Address != nil {
+		t.Errorf("address should be omitted when not requested")
+	}
+
+	_ = l.SetAddress(domain.Address{City: "Linhares", State: "ES"})
+
+	v = l.View(domain.ViewOptions{Fields: domain.Fields{Address: true}})
+	if v.Address == nil || v.Address.State != "ES" {
+		t.Errorf("expected address to be rendered but got %+v", v.Address)
+	}
+	if v.Current != nil {
+		t.Errorf("current should be omitted when not requested")
+	}
+
+	err := l.SetCurrent(domain.Conditions{TempC: 23, FeelsLikeC: 25, Humidity: 70, Condition: "Partly cloudy"})
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	v = l.View(domain.ViewOptions{Units: domain.Units{K: true}, Fields: domain.Fields{Current: true}})
+	c := v.Current
+	if c == nil || c.TempK == nil || *c.TempK != 296.15 || c.FeelsLikeK == nil || *c.FeelsLikeK != 298.15 || c.TempC != nil {
+		t.Fatalf("unexpected current conditions view %+v", c)
+	}
+	if c.Humidity != 70 || c.Condition != "Partly cloudy" || c.ObservedAt != nil {
+		t.Errorf("unexpected current conditions view %+v", c)
+	}
+
+	if err := l.SetCurrent(domain.Conditions{Humidity: 101}); err == nil {
+		t.Errorf("expected error for humidity out of range")
+	}
+}
+
+func TestParseFields(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    domain.Fields
+		wantErr bool
+	}{
+		{in: "", want: domain.Fields{}},
+		{in: "address", want: domain.Fields{Address: true}},
+		{in: "current, coordinates", want: domain.Fields{Current: true, Coordinates: true}},
+		{in: "air_quality,alerts", want: domain.Fields{AirQuality: true, Alerts: true}},
+		{in: "Address, nope", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.ParseFields(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFields(%q): expected error %v but got %v", tt.in, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseFields(%q): expected %+v but got %+v", tt.in, tt.want, got)
+		}
+	}
+}
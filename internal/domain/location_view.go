@@ -0,0 +1,206 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Units selects which temperature scales are rendered in a response.
+type Units struct {
+	C bool
+	F bool
+	K bool
+	R bool
+}
+
+// AllUnits renders the scales of the legacy response. Rankine is only
+// rendered on request.
+var AllUnits = Units{C: true, F: true, K: true}
+
+// ParseUnits parses a comma separated list such as "c,f,k,r". An empty string
+// selects AllUnits.
+func ParseUnits(s string) (Units, error) {
+
+	if strings.TrimSpace(s) == "" {
+		return AllUnits, nil
+	}
+
+	var u Units
+	for _, part := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "c":
+			u.C = true
+		case "f":
+			u.F = true
+		case "k":
+			u.K = true
+		case "r":
+			u.R = true
+		default:
+			return Units{}, fmt.Errorf("invalid unit %q - example: c,f,k,r", part)
+		}
+	}
+
+	return u, nil
+}
+
+// Fields selects the optional sub-objects rendered in a response.
+type Fields struct {
+	Address     bool
+	Coordinates bool
+	Current     bool
+	AirQuality  bool
+	Alerts      bool
+}
+
+// ParseFields parses a comma separated list such as "address,current". An empty
+// string selects no optional field.
+func ParseFields(s string) (Fields, error) {
+
+	var f Fields
+	if strings.TrimSpace(s) == "" {
+		return f, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "address":
+			f.Address = true
+		case "coordinates":
+			f.Coordinates = true
+		case "current":
+			f.Current = true
+		case "air_quality":
+			f.AirQuality = true
+		case "alerts":
+			f.Alerts = true
+		default:
+			return Fields{}, fmt.Errorf("invalid field %q - example: address,coordinates,current,air_quality,alerts", part)
+		}
+	}
+
+	return f, nil
+}
+
+// ViewOptions controls how a Location is rendered in a response.
+// Temperatures are rounded to Precision decimals only when Round is set, and
+// rendered in Kelvin as C + 273 when LegacyKelvin is set.
+type ViewOptions struct {
+	Units  Units
+	Fields Fields
+
+	Round        bool
+	Precision    int
+	LegacyKelvin bool
+}
+
+// LocationView is the JSON rendering of a Location restricted to the
+// requested units and optional fields.
+type LocationView struct {
+	CEP     string   `json:"cep"`
+	City    string   `json:"city"`
+	TempC   *float64 `json:"temp_c,omitempty"`
+	TempF   *float64 `json:"temp_f,omitempty"`
+	TempK   *float64 `json:"temp_k,omitempty"`
+	TempR   *float64 `json:"temp_r,omitempty"`
+	Lat     *float64 `json:"lat,omitempty"`
+	Lon     *float64 `json:"lon,omitempty"`
+	Address *Address `json:"address,omitempty"`
+
+	Current *ConditionsView `json:"current,omitempty"`
+
+	AirQuality *AirQualityView `json:"air_quality,omitempty"`
+	Alerts     []Alert         `json:"alerts,omitempty"`
+}
+
+// AirQualityView renders AirQuality along with the name of its index.
+type AirQualityView struct {
+	AirQuality
+	Category string `json:"category"`
+}
+
+// ConditionsView renders Conditions with temperatures in the requested
+// units.
+type ConditionsView struct {
+	TempC         *float64   `json:"temp_c,omitempty"`
+	TempF         *float64   `json:"temp_f,omitempty"`
+	TempK         *float64   `json:"temp_k,omitempty"`
+	TempR         *float64   `json:"temp_r,omitempty"`
+	FeelsLikeC    *float64   `json:"feelslike_c,omitempty"`
+	FeelsLikeF    *float64   `json:"feelslike_f,omitempty"`
+	FeelsLikeK    *float64   `json:"feelslike_k,omitempty"`
+	FeelsLikeR    *float64   `json:"feelslike_r,omitempty"`
+	Humidity      int        `json:"humidity"`
+	WindKph       float64    `json:"wind_kph"`
+	WindDegree    int        `json:"wind_degree"`
+	WindDir       string     `json:"wind_dir"`
+	PressureMb    float64    `json:"pressure_mb"`
+	UV            float64    `json:"uv"`
+	Condition     string     `json:"condition"`
+	ConditionCode int        `json:"condition_code"`
+	ObservedAt    *time.Time `json:"observed_at,omitempty"`
+}
+
+func (c *Conditions) View(opts ViewOptions) *ConditionsView {
+
+	v := &ConditionsView{
+		Humidity:      c.Humidity,
+		WindKph:       c.WindKph,
+		WindDegree:    c.WindDegree,
+		WindDir:       c.WindDir,
+		PressureMb:    c.PressureMb,
+		UV:            c.UV,
+		Condition:     c.Condition,
+		ConditionCode: c.ConditionCode,
+	}
+
+	t := Temperature(c.TempC).view(opts)
+	v.TempC, v.TempF, v.TempK, v.TempR = t.C, t.F, t.K, t.R
+
+	t = Temperature(c.FeelsLikeC).view(opts)
+	v.FeelsLikeC, v.FeelsLikeF, v.FeelsLikeK, v.FeelsLikeR = t.C, t.F, t.K, t.R
+
+	if !c.ObservedAt.IsZero() {
+		observedAt := c.ObservedAt
+		v.ObservedAt = &observedAt
+	}
+
+	return v
+}
+
+func (l *Location) View(opts ViewOptions) LocationView {
+
+	f := opts.Fields
+
+	v := LocationView{
+		CEP:  l.GetCEP(),
+		City: l.GetCity(),
+	}
+
+	if f.Address {
+		v.Address = l.GetAddress()
+	}
+
+	if f.Coordinates && l.HasCoordinates() {
+		lat, lon := l.GetLat(), l.GetLon()
+		v.Lat, v.Lon = &lat, &lon
+	}
+
+	t := Temperature(l.GetTempC()).view(opts)
+	v.TempC, v.TempF, v.TempK, v.TempR = t.C, t.F, t.K, t.R
+
+	if f.Current && l.GetCurrent() != nil {
+		v.Current = l.GetCurrent().View(opts)
+	}
+
+	if f.AirQuality && l.GetAirQuality() != nil {
+		v.AirQuality = &AirQualityView{AirQuality: *l.GetAirQuality(), Category: l.GetAirQuality().Category()}
+	}
+
+	if f.Alerts {
+		v.Alerts = l.GetAlerts()
+	}
+
+	return v
+}
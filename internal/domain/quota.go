@@ -0,0 +1,369 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/openmeteo"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Upstream providers whose calls are counted in the repository.
+const (
+	ProviderViaCEP     = "viacep"
+	ProviderGeocode    = "geocode"
+	ProviderWeatherAPI = "weatherapi"
+	ProviderOpenMeteo  = "open-meteo"
+)
+
+// What to do once the WeatherAPI budget threshold is crossed, selected with
+// WEATHER_API_BUDGET_FALLBACK.
+const (
+	// FallbackCacheOnly answers only from the forecast cache and the
+	// stored history; anything else fails with ErrBudgetExhausted.
+	FallbackCacheOnly = "cache-only"
+	// FallbackOpenMeteo sends the weather lookups to Open-Meteo instead.
+	FallbackOpenMeteo = "open-meteo"
+)
+
+// DefaultBudgetThreshold is the share of the monthly budget after which the
+// fallback is used, overridable with WEATHER_API_BUDGET_THRESHOLD.
+const DefaultBudgetThreshold = 0.9
+
+// DefaultStaleTTL is how long the conditions answered are kept to be served
+// once the budget is exceeded, overridable with WEATHER_API_STALE_TTL.
+const DefaultStaleTTL = 24 * time.Hour
+
+// ErrBudgetExhausted is returned by the weather provider once the WeatherAPI
+// budget threshold is crossed and the fallback is FallbackCacheOnly.
+var ErrBudgetExhausted = errors.New("weather api budget exhausted")
+
+// Budget caps the WeatherAPI calls made in a calendar month (UTC).
+type Budget struct {
+	// Monthly is the number of calls allowed per month. Zero means no
+	// budget.
+	Monthly int
+	// Threshold is the share of Monthly after which Fallback is used.
+	Threshold float64
+	Fallback  string
+	// StaleTTL is how long the conditions answered are kept to be served
+	// once the budget is exceeded. Zero keeps none.
+	StaleTTL time.Duration
+}
+
+// budgetFromEnv reads the Budget from WEATHER_API_MONTHLY_BUDGET,
+// WEATHER_API_BUDGET_THRESHOLD (e.g. "0.8"), WEATHER_API_BUDGET_FALLBACK and
+// WEATHER_API_STALE_TTL (e.g. "6h").
+func budgetFromEnv() Budget {
+
+	b := Budget{Threshold: DefaultBudgetThreshold, Fallback: FallbackCacheOnly, StaleTTL: DefaultStaleTTL}
+
+	if v := os.Getenv("WEATHER_API_MONTHLY_BUDGET"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Println("invalid WEATHER_API_MONTHLY_BUDGET, ignoring:", v)
+		} else {
+			b.Monthly = n
+		}
+	}
+
+	if v := os.Getenv("WEATHER_API_BUDGET_THRESHOLD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			log.Println("invalid WEATHER_API_BUDGET_THRESHOLD, using default:", v)
+		} else {
+			b.Threshold = f
+		}
+	}
+
+	switch v := os.Getenv("WEATHER_API_BUDGET_FALLBACK"); v {
+	case "", FallbackCacheOnly:
+	case FallbackOpenMeteo:
+		b.Fallback = FallbackOpenMeteo
+	default:
+		log.Println("invalid WEATHER_API_BUDGET_FALLBACK, using cache-only:", v)
+	}
+
+	if v := os.Getenv("WEATHER_API_STALE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Println("invalid WEATHER_API_STALE_TTL, using default:", v)
+		} else {
+			b.StaleTTL = d
+		}
+	}
+
+	return b
+}
+
+// limit returns how many calls b allows in a month before its threshold is
+// crossed, zero meaning no limit.
+func (b Budget) limit() int {
+
+	if b.Monthly <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(b.Threshold * float64(b.Monthly)))
+}
+
+var (
+	upstreamMeter = otel.Meter("service-b")
+	upstreamCalls metric.Int64Counter
+)
+
+func init() {
+
+	var err error
+
+	upstreamCalls, err = upstreamMeter.Int64Counter("upstream.calls",
+		metric.WithDescription("Calls made to the upstream providers"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		log.Println("error to create upstream calls counter:", err)
+	}
+}
+
+// recordUpstreamCall counts a call to provider in repo and in the
+// upstream.calls metric.
+func recordUpstreamCall(ctx context.Context, repo LocationRepositoryInterface, provider string) {
+
+	err := repo.RecordUpstreamCall(provider, time.Now())
+	if err != nil {
+		log.Println("error to record upstream call:", provider, err)
+	}
+
+	countUpstreamCall(ctx, provider)
+}
+
+// reserveUpstreamCall counts a call to provider like recordUpstreamCall,
+// unless limit calls were already made in the month, in a single repository
+// operation so that concurrent lookups cannot overrun the limit. A limit of
+// zero means no limit.
+func reserveUpstreamCall(ctx context.Context, repo LocationRepositoryInterface, provider string, limit int) bool {
+
+	ok, err := repo.ReserveUpstreamCall(provider, time.Now(), limit)
+	if err != nil {
+		log.Println("error to reserve upstream call:", provider, err)
+		return false
+	}
+
+	if ok {
+		countUpstreamCall(ctx, provider)
+	}
+
+	return ok
+}
+
+// countUpstreamCall adds a call to provider to the upstream.calls metric.
+func countUpstreamCall(ctx context.Context, provider string) {
+
+	if upstreamCalls != nil {
+		upstreamCalls.Add(ctx, 1, metric.WithAttributes(attribute.String("provider", provider)))
+	}
+}
+
+// budgetedWeather sends the weather lookups to WeatherAPI while its monthly
+// budget allows, then to the fallback. It keeps the last conditions answered
+// for every query during StaleTTL, to serve them once the budget is exceeded
+// and the fallback cannot answer.
+type budgetedWeather struct {
+	primary   WeatherProvider
+	secondary WeatherProvider
+	repo      LocationRepositoryInterface
+	budget    Budget
+
+	mu         sync.Mutex
+	exceeded   string // month in which the crossing was last logged
+	conditions map[string]conditionsEntry
+}
+
+type conditionsEntry struct {
+	conditions *weather.WeatherResponse
+	expiresAt  time.Time
+}
+
+// newBudgetedWeather wraps primary with budget, counting the calls in repo,
+// and reports the remaining budget in the upstream.budget.remaining metric.
+func newBudgetedWeather(primary WeatherProvider, repo LocationRepositoryInterface, budget Budget) *budgetedWeather {
+
+	b := &budgetedWeather{primary: primary, repo: repo, budget: budget}
+	if budget.Fallback == FallbackOpenMeteo {
+		b.secondary = openmeteo.NewClient("", "")
+	}
+	if budget.Monthly > 0 && budget.StaleTTL > 0 {
+		b.conditions = make(map[string]conditionsEntry)
+	}
+
+	if budget.Monthly > 0 {
+		_, err := upstreamMeter.Int64ObservableGauge("upstream.budget.remaining",
+			metric.WithDescription("WeatherAPI calls left in the monthly budget"),
+			metric.WithUnit("{call}"),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				_, monthly := repo.UpstreamCalls(ProviderWeatherAPI, time.Now())
+				o.Observe(int64(max(0, budget.Monthly-monthly)), metric.WithAttributes(attribute.String("provider", ProviderWeatherAPI)))
+				return nil
+			}),
+		)
+		if err != nil {
+			log.Println("error to create budget gauge:", err)
+		}
+	}
+
+	return b
+}
+
+// provider returns the provider of the next lookup of q, reserving the call.
+// Past the threshold it fails with ErrBudgetExhausted when the fallback is
+// FallbackCacheOnly, or when q has no coordinates, the only thing Open-Meteo
+// answers for.
+func (b *budgetedWeather) provider(ctx context.Context, q weather.Query) (WeatherProvider, error) {
+
+	span := trace.SpanFromContext(ctx)
+
+	if reserveUpstreamCall(ctx, b.repo, ProviderWeatherAPI, b.budget.limit()) {
+		span.SetAttributes(attribute.String("weather.provider", ProviderWeatherAPI))
+		return b.primary, nil
+	}
+
+	b.mu.Lock()
+	if month := time.Now().UTC().Format("2006-01"); b.exceeded != month {
+		b.exceeded = month
+		log.Println("weather api budget threshold crossed, falling back to:", b.budget.Fallback)
+	}
+	b.mu.Unlock()
+
+	span.SetAttributes(attribute.String("weather.budget", "exceeded"))
+
+	if b.secondary == nil {
+		return nil, ErrBudgetExhausted
+	}
+
+	if q.Lat == 0 || q.Lon == 0 {
+		log.Println("no coordinates to ask open-meteo, skipping to cache:", q)
+		return nil, ErrBudgetExhausted
+	}
+
+	recordUpstreamCall(ctx, b.repo, ProviderOpenMeteo)
+	span.SetAttributes(attribute.String("weather.provider", ProviderOpenMeteo))
+
+	return b.secondary, nil
+}
+
+// GetConditions asks the provider of q for its conditions. When none is left
+// once the budget is exceeded, the last conditions answered for q are served
+// instead, as they were observed.
+func (b *budgetedWeather) GetConditions(ctx context.Context, q weather.Query, inc weather.Include) (*weather.WeatherResponse, error) {
+
+	key := conditionsKey(q, inc)
+
+	p, err := b.provider(ctx, q)
+	if errors.Is(err, ErrBudgetExhausted) {
+		if stored, ok := b.storedConditions(key); ok {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("weather.provider", "cache"))
+			return stored, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := p.GetConditions(ctx, q, inc)
+	if err == nil {
+		b.storeConditions(key, current)
+	}
+
+	return current, err
+}
+
+// storedConditions returns the conditions kept under key, if not expired.
+func (b *budgetedWeather) storedConditions(key string) (*weather.WeatherResponse, bool) {
+
+	if b.conditions == nil {
+		return nil, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry, ok := b.conditions[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(b.conditions, key)
+		return nil, false
+	}
+
+	return entry.conditions, true
+}
+
+// storeConditions keeps c under key for StaleTTL, dropping the entries
+// already expired so that the queries no longer asked do not pile up.
+func (b *budgetedWeather) storeConditions(key string, c *weather.WeatherResponse) {
+
+	if b.conditions == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range b.conditions {
+		if now.After(entry.expiresAt) {
+			delete(b.conditions, key)
+		}
+	}
+
+	b.conditions[key] = conditionsEntry{conditions: c, expiresAt: now.Add(b.budget.StaleTTL)}
+}
+
+// conditionsKey identifies the conditions of q with inc, in the language of
+// q.
+func conditionsKey(q weather.Query, inc weather.Include) string {
+	return fmt.Sprintf("%s/%s/%t/%t", q, q.Lang, inc.AirQuality, inc.Alerts)
+}
+
+func (b *budgetedWeather) GetForecast(ctx context.Context, q weather.Query, days int) (*weather.ForecastResponse, error) {
+
+	p, err := b.provider(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetForecast(ctx, q, days)
+}
+
+func (b *budgetedWeather) GetHistory(ctx context.Context, q weather.Query, day time.Time) (*weather.ForecastResponse, error) {
+
+	p, err := b.provider(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetHistory(ctx, q, day)
+}
+
+// weatherError maps an error of the weather provider to the status code
+// carried by the LocationService errors.
+func weatherError(err error) error {
+
+	if errors.Is(err, ErrBudgetExhausted) {
+		return fmt.Errorf("503")
+	}
+
+	return fmt.Errorf("500")
+}
@@ -0,0 +1,108 @@
+package domain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	viacep "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/openmeteo/openmeteotest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+)
+
+func TestWeatherAPIBudget(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		fallback      string
+		staleTTL      string
+		wantC         float64
+		wantErr       string
+		wantOpenMeteo int
+	}{
+		{name: "cache only", fallback: "", wantC: 25},
+		{name: "cache only without stale conditions", fallback: "", staleTTL: "0", wantErr: "503"},
+		{name: "open-meteo", fallback: domain.FallbackOpenMeteo, wantC: 18, wantOpenMeteo: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			cepSrv, weatherSrv := setupFakes(t)
+
+			geocodeSrv := geocodetest.NewServer()
+			t.Cleanup(geocodeSrv.Close)
+			t.Setenv("GEOCODE_BASE_URL", geocodeSrv.URL)
+
+			meteoSrv := openmeteotest.NewServer()
+			t.Cleanup(meteoSrv.Close)
+			t.Setenv("OPEN_METEO_BASE_URL", meteoSrv.URL)
+			t.Setenv("OPEN_METEO_ARCHIVE_URL", meteoSrv.URL)
+
+			cepSrv.AddAddress("29902555", viacep.ViaCepResponse{Localidade: "Linhares", Uf: "ES"})
+			cepSrv.AddAddress("29900000", viacep.ViaCepResponse{Localidade: "Linhares", Uf: "ES"})
+			geocodeSrv.AddCoordinates("29902555", -19.39, -40.07)
+			weatherSrv.AddLocation(weather.WeatherLocation{Name: "Linhares", Region: "Espirito Santo", Country: "Brazil", Lat: -19.39, Lon: -40.07}, weather.CurrentWeather{FeelsLikeC: 25})
+			meteoSrv.AddPlace(-19.39, -40.07, 18)
+
+			// The threshold is crossed after the third WeatherAPI call.
+			t.Setenv("WEATHER_API_MONTHLY_BUDGET", "10")
+			t.Setenv("WEATHER_API_BUDGET_THRESHOLD", "0.3")
+			t.Setenv("WEATHER_API_BUDGET_FALLBACK", tt.fallback)
+			t.Setenv("WEATHER_API_STALE_TTL", tt.staleTTL)
+
+			repo := domain.NewLocationRepository()
+			s := domain.NewLocationService(repo)
+
+			l, _ := domain.NewLocation("29902555")
+			if _, err := s.Forecast(context.Background(), l, 3); err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				l, _ := domain.NewLocation("29902555")
+				if err := s.Execute(context.Background(), l); err != nil || l.GetTempC() != 25 {
+					t.Fatalf("expected the WeatherAPI answer under the budget but got %v %v", l.GetTempC(), err)
+				}
+			}
+
+			l, _ = domain.NewLocation("29902555")
+			err := s.Execute(context.Background(), l)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q from the fallback but got %v", tt.wantErr, err)
+				}
+			} else if err != nil || l.GetTempC() != tt.wantC {
+				t.Fatalf("expected %v from the fallback but got %v %v", tt.wantC, l.GetTempC(), err)
+			}
+
+			// A CEP never looked up, without coordinates for Open-Meteo, has
+			// nothing to fall back to.
+			l, _ = domain.NewLocation("29900000")
+			if err := s.Execute(context.Background(), l); err == nil || err.Error() != "503" {
+				t.Fatalf("expected error %q but got %v", "503", err)
+			}
+
+			// A cached forecast is still served once the budget is exceeded.
+			l, _ = domain.NewLocation("29902555")
+			if _, err := s.Forecast(context.Background(), l, 3); err != nil {
+				t.Errorf("expected the cached forecast but got %v", err)
+			}
+
+			if _, monthly := repo.UpstreamCalls(domain.ProviderWeatherAPI, time.Now()); monthly != 3 {
+				t.Errorf("expected 3 WeatherAPI calls but got %d", monthly)
+			}
+			if _, monthly := repo.UpstreamCalls(domain.ProviderOpenMeteo, time.Now()); monthly != tt.wantOpenMeteo {
+				t.Errorf("expected %d Open-Meteo calls but got %d", tt.wantOpenMeteo, monthly)
+			}
+			if hits := meteoSrv.Hits(); hits != tt.wantOpenMeteo {
+				t.Errorf("expected %d Open-Meteo hits but got %d", tt.wantOpenMeteo, hits)
+			}
+			if daily, _ := repo.UpstreamCalls(domain.ProviderViaCEP, time.Now()); daily != 5 {
+				t.Errorf("expected 5 ViaCEP calls but got %d", daily)
+			}
+		})
+	}
+}
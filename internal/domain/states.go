@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Country is the country every CEP belongs to, as named by WeatherAPI.
+const Country = "Brazil"
+
+// states maps each UF to the state name used to query the weather provider.
+var states = map[string]string{
+	"AC": "Acre",
+	"AL": "Alagoas",
+	"AP": "Amapá",
+	"AM": "Amazonas",
+	"BA": "Bahia",
+	"CE": "Ceará",
+	"DF": "Distrito Federal",
+	"ES": "Espírito Santo",
+	"GO": "Goiás",
+	"MA": "Maranhão",
+	"MT": "Mato Grosso",
+	"MS": "Mato Grosso do Sul",
+	"MG": "Minas Gerais",
+	"PA": "Pará",
+	"PB": "Paraíba",
+	"PR": "Paraná",
+	"PE": "Pernambuco",
+	"PI": "Piauí",
+	"RJ": "Rio de Janeiro",
+	"RN": "Rio Grande do Norte",
+	"RS": "Rio Grande do Sul",
+	"RO": "Rondônia",
+	"RR": "Roraima",
+	"SC": "Santa Catarina",
+	"SP": "São Paulo",
+	"SE": "Sergipe",
+	"TO": "Tocantins",
+}
+
+// StateName returns the full name of the state with the given UF, or an
+// empty string for unknown UFs.
+func StateName(uf string) string {
+	return states[strings.ToUpper(uf)]
+}
+
+// samePlace compares place names ignoring case and accents, since providers
+// often drop them ("Sao Paulo" vs "São Paulo").
+func samePlace(a, b string) bool {
+	return normalizePlace(a) == normalizePlace(b)
+}
+
+func normalizePlace(s string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, _ := transform.String(stripAccents, strings.ToLower(strings.TrimSpace(s)))
+	return out
+}
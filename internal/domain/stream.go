@@ -0,0 +1,362 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Default limits of the live readings, overridable with the
+// STREAM_POLL_INTERVAL, STREAM_MAX_SUBSCRIBERS and
+// STREAM_MAX_SUBSCRIBERS_PER_CITY environment variables.
+const (
+	DefaultStreamPollInterval   = time.Minute
+	DefaultStreamMaxSubscribers = 1000
+	DefaultStreamMaxPerCity     = 100
+)
+
+// StreamLimits configures a ReadingHub.
+type StreamLimits struct {
+	// PollInterval is how often the conditions of a city are refreshed
+	// while it has subscribers.
+	PollInterval time.Duration
+	// MaxSubscribers caps the subscribers of every city together, and
+	// MaxPerCity those of a single city.
+	MaxSubscribers int
+	MaxPerCity     int
+}
+
+// StreamLimitsFromEnv reads the limits from STREAM_POLL_INTERVAL, a duration
+// such as "30s", STREAM_MAX_SUBSCRIBERS and STREAM_MAX_SUBSCRIBERS_PER_CITY,
+// keeping the defaults for invalid values.
+func StreamLimitsFromEnv() StreamLimits {
+
+	l := StreamLimits{
+		PollInterval:   DefaultStreamPollInterval,
+		MaxSubscribers: DefaultStreamMaxSubscribers,
+		MaxPerCity:     DefaultStreamMaxPerCity,
+	}
+
+	if v := os.Getenv("STREAM_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Println("invalid STREAM_POLL_INTERVAL, using default:", v)
+		} else {
+			l.PollInterval = d
+		}
+	}
+
+	for key, limit := range map[string]*int{"STREAM_MAX_SUBSCRIBERS": &l.MaxSubscribers, "STREAM_MAX_SUBSCRIBERS_PER_CITY": &l.MaxPerCity} {
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Println("invalid "+key+", using default:", v)
+			continue
+		}
+		*limit = n
+	}
+
+	return l
+}
+
+// Reading is a refresh of the current conditions of a city.
+type Reading struct {
+	// ID grows with every new reading of a city, even across pollers, as it
+	// is the time the reading was taken in milliseconds.
+	ID      int64
+	Current Conditions
+	// Err is set, and ID and Current are not, when the refresh failed.
+	Err error
+}
+
+// Apply sets the temperatures and current conditions of l from r.
+func (r Reading) Apply(l *Location) error {
+
+	err := l.SetTemperatures(r.Current.FeelsLikeC)
+	if err != nil {
+		return err
+	}
+
+	return l.SetCurrent(r.Current)
+}
+
+// SubscriberLimitError is returned by Subscribe when a limit of StreamLimits
+// was reached.
+type SubscriberLimitError struct {
+	Limit int
+}
+
+func (e *SubscriberLimitError) Error() string {
+	return fmt.Sprintf("too many subscribers, limit is %d", e.Limit)
+}
+
+var streamSubscribers metric.Int64UpDownCounter
+
+func init() {
+
+	var err error
+
+	streamSubscribers, err = otel.Meter("service-b").Int64UpDownCounter("stream.subscribers",
+		metric.WithDescription("Subscribers of the live readings"),
+		metric.WithUnit("{subscriber}"),
+	)
+	if err != nil {
+		log.Println("error to create stream subscribers counter:", err)
+	}
+}
+
+// ReadingHub shares the live readings of a city among its subscribers: a
+// single poller per city and language refreshes its conditions while it has
+// subscribers, handing every new reading to them. The latest reading of a
+// city is kept after its poller stops, so that it is not handed again with a
+// new ID to the subscribers coming back.
+type ReadingHub struct {
+	service *LocationService
+	limits  StreamLimits
+
+	mu          sync.Mutex
+	pollers     map[string]*poller
+	readings    map[string]Reading
+	subscribers int
+}
+
+// NewReadingHub returns a ReadingHub refreshing the conditions with service.
+func NewReadingHub(service *LocationService, limits StreamLimits) *ReadingHub {
+	return &ReadingHub{
+		service:  service,
+		limits:   limits,
+		pollers:  make(map[string]*poller),
+		readings: make(map[string]Reading),
+	}
+}
+
+// poller refreshes the conditions of a city. Its fields but location are
+// guarded by the hub.
+type poller struct {
+	key      string
+	location Location
+	cancel   context.CancelFunc
+
+	subscribers map[*Subscription]struct{}
+	failing     bool
+}
+
+// Subscription receives the readings of a city until closed.
+type Subscription struct {
+	// C receives the new readings and the failed refreshes. A subscriber
+	// too slow to keep up only gets the latest one.
+	C <-chan Reading
+
+	c      chan Reading
+	hub    *ReadingHub
+	poller *poller
+	once   sync.Once
+}
+
+// readingKey identifies the city of l, by its IBGE code when known, in the
+// language of its condition texts.
+func readingKey(l *Location, lang i18n.Lang) string {
+
+	state := ""
+	if a := l.GetAddress(); a != nil {
+		if a.IBGE != "" {
+			return a.IBGE + "/" + string(lang)
+		}
+		state = a.State
+	}
+
+	return state + "/" + l.GetCity() + "/" + string(lang)
+}
+
+// pollLocation returns the location the city of l is polled at: the centroid
+// of its municipality, or the coordinates of l when its IBGE code is unknown,
+// which still lie within the city the poller is keyed by. Open-Meteo, the
+// budget fallback, only answers for coordinates.
+func pollLocation(l *Location) Location {
+
+	p := Location{City: l.GetCity(), Address: l.GetAddress()}
+
+	if a := l.GetAddress(); a != nil {
+		if centroid, ok := geocode.Centroid(a.IBGE); ok {
+			_ = p.SetCoordinates(centroid.Lat, centroid.Lon)
+			return p
+		}
+	}
+
+	if l.HasCoordinates() {
+		_ = p.SetCoordinates(l.GetLat(), l.GetLon())
+	}
+
+	return p
+}
+
+// Subscribe registers a subscriber to the readings of the city of l, already
+// resolved by GetCEP, in the language of ctx, starting its poller if needed.
+// It also returns the latest reading of the city, with a zero ID when there
+// is none yet. Subscribers must Close the subscription.
+func (h *ReadingHub) Subscribe(ctx context.Context, l *Location) (*Subscription, Reading, error) {
+
+	lang := i18n.FromContext(ctx)
+	key := readingKey(l, lang)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers >= h.limits.MaxSubscribers {
+		return nil, Reading{}, &SubscriberLimitError{Limit: h.limits.MaxSubscribers}
+	}
+
+	p, ok := h.pollers[key]
+	if ok && len(p.subscribers) >= h.limits.MaxPerCity {
+		return nil, Reading{}, &SubscriberLimitError{Limit: h.limits.MaxPerCity}
+	}
+
+	if !ok {
+
+		p = &poller{
+			key:         key,
+			location:    pollLocation(l),
+			subscribers: make(map[*Subscription]struct{}),
+		}
+		h.pollers[key] = p
+
+		pollCtx, cancel := context.WithCancel(i18n.WithLang(context.Background(), lang))
+		p.cancel = cancel
+		go h.poll(pollCtx, p)
+	}
+
+	c := make(chan Reading, 1)
+	sub := &Subscription{C: c, c: c, hub: h, poller: p}
+	p.subscribers[sub] = struct{}{}
+	h.subscribers++
+
+	if streamSubscribers != nil {
+		streamSubscribers.Add(ctx, 1)
+	}
+
+	return sub, h.readings[key], nil
+}
+
+// Close unregisters the subscriber, stopping the poller of the city when it
+// was the last one.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+
+		h := s.hub
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(s.poller.subscribers, s)
+		h.subscribers--
+
+		if streamSubscribers != nil {
+			streamSubscribers.Add(context.Background(), -1)
+		}
+
+		if len(s.poller.subscribers) == 0 {
+			s.poller.cancel()
+			delete(h.pollers, s.poller.key)
+		}
+	})
+}
+
+// poll refreshes the conditions of p right away and then every poll
+// interval, until ctx is cancelled.
+func (h *ReadingHub) poll(ctx context.Context, p *poller) {
+
+	ticker := time.NewTicker(h.limits.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		h.refresh(ctx, p)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh asks the weather provider for the conditions of the city of p and
+// hands them to its subscribers when they changed. A failed refresh is
+// handed to them once, until a refresh succeeds again.
+func (h *ReadingHub) refresh(ctx context.Context, p *poller) {
+
+	ctx, span := otel.Tracer("service-b").Start(ctx, "service_b-stream-refresh")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("service.name", "service-b"),
+		attribute.String("stream.city", p.location.GetCity()),
+	)
+
+	l := p.location
+	err := h.service.RefreshConditions(ctx, &l)
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err != nil {
+
+		span.SetStatus(codes.Error, err.Error())
+
+		if !p.failing {
+			p.failing = true
+			p.publish(Reading{Err: err})
+		}
+		return
+	}
+	p.failing = false
+
+	latest := h.readings[p.key]
+	current := *l.GetCurrent()
+	changed := latest.ID == 0 || latest.Current != current
+	span.SetAttributes(attribute.Bool("stream.changed", changed))
+	if !changed {
+		return
+	}
+
+	id := time.Now().UnixMilli()
+	if id <= latest.ID {
+		id = latest.ID + 1
+	}
+
+	h.readings[p.key] = Reading{ID: id, Current: current}
+	p.publish(h.readings[p.key])
+}
+
+// publish hands r to every subscriber of p, replacing the reading a slow
+// subscriber did not receive yet. Callers must hold the hub's lock.
+func (p *poller) publish(r Reading) {
+
+	for sub := range p.subscribers {
+		select {
+		case sub.c <- r:
+		default:
+			select {
+			case <-sub.c:
+			default:
+			}
+			sub.c <- r
+		}
+	}
+}
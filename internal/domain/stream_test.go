@@ -0,0 +1,257 @@
+package domain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	viacep "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/openmeteo/openmeteotest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+)
+
+// receive waits for the next reading of sub.
+func receive(t *testing.T, sub *domain.Subscription) domain.Reading {
+	t.Helper()
+
+	select {
+	case r := <-sub.C:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a reading but got none")
+		return domain.Reading{}
+	}
+}
+
+// subscribe resolves cep and subscribes to the readings of its city.
+func subscribe(t *testing.T, s *domain.LocationService, hub *domain.ReadingHub, cep string) (*domain.Subscription, domain.Reading, error) {
+	t.Helper()
+
+	l, _ := domain.NewLocation(cep)
+	if err := s.GetCEP(context.Background(), l); err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	return hub.Subscribe(context.Background(), l)
+}
+
+func TestReadingHubSharesReadings(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddCity("29900000", "Linhares")
+	cepSrv.AddCity("29902555", "Linhares")
+	weatherSrv.AddCity("Linhares", 25)
+
+	s := domain.NewLocationService(domain.NewLocationRepository())
+	hub := domain.NewReadingHub(s, domain.StreamLimits{PollInterval: 20 * time.Millisecond, MaxSubscribers: 10, MaxPerCity: 10})
+
+	first, _, err := subscribe(t, s, hub, "29900000")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	defer first.Close()
+
+	reading := receive(t, first)
+	if reading.Err != nil || reading.ID == 0 || reading.Current.FeelsLikeC != 25 {
+		t.Fatalf("expected a reading of 25 °C but got %+v", reading)
+	}
+
+	second, latest, err := subscribe(t, s, hub, "29902555")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	defer second.Close()
+
+	if latest != reading {
+		t.Errorf("expected the latest reading %+v but got %+v", reading, latest)
+	}
+
+	// Unchanged readings are not handed again.
+	select {
+	case r := <-second.C:
+		t.Fatalf("expected no reading while the conditions are unchanged but got %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	weatherSrv.SetCurrent("Linhares", weather.CurrentWeather{FeelsLikeC: 27})
+
+	var newer domain.Reading
+	for _, sub := range []*domain.Subscription{first, second} {
+		newer = receive(t, sub)
+		if newer.Current.FeelsLikeC != 27 || newer.ID <= reading.ID {
+			t.Errorf("expected a newer reading of 27 °C than %d but got %+v", reading.ID, newer)
+		}
+	}
+
+	// The latest reading outlives the poller, and is not handed again by
+	// the next one while unchanged.
+	first.Close()
+	second.Close()
+
+	third, latest, err := subscribe(t, s, hub, "29900000")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	defer third.Close()
+
+	if latest != newer {
+		t.Errorf("expected the latest reading %+v but got %+v", newer, latest)
+	}
+
+	select {
+	case r := <-third.C:
+		t.Fatalf("expected no reading while the conditions are unchanged but got %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReadingHubLimits(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	cepSrv.AddCity("29900000", "Linhares")
+	cepSrv.AddCity("01308080", "São Paulo")
+	weatherSrv.AddCity("Linhares", 25)
+	weatherSrv.AddCity("São Paulo", 20)
+
+	tests := []struct {
+		name      string
+		limits    domain.StreamLimits
+		ceps      []string
+		wantLimit int
+	}{
+		{name: "within limits", limits: domain.StreamLimits{MaxSubscribers: 3, MaxPerCity: 2}, ceps: []string{"29900000", "29900000", "01308080"}},
+		{name: "per city", limits: domain.StreamLimits{MaxSubscribers: 3, MaxPerCity: 2}, ceps: []string{"29900000", "29900000", "29900000"}, wantLimit: 2},
+		{name: "every city", limits: domain.StreamLimits{MaxSubscribers: 2, MaxPerCity: 2}, ceps: []string{"29900000", "01308080", "01308080"}, wantLimit: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := domain.NewLocationService(domain.NewLocationRepository())
+			tt.limits.PollInterval = time.Hour
+			hub := domain.NewReadingHub(s, tt.limits)
+
+			var err error
+			for _, cep := range tt.ceps {
+				var sub *domain.Subscription
+				sub, _, err = subscribe(t, s, hub, cep)
+				if err != nil {
+					break
+				}
+				defer sub.Close()
+			}
+
+			var limitErr *domain.SubscriberLimitError
+			if tt.wantLimit == 0 {
+				if err != nil {
+					t.Fatalf("expected error to be nil and got %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit {
+				t.Fatalf("expected a subscriber limit of %d but got %v", tt.wantLimit, err)
+			}
+		})
+	}
+}
+
+func TestReadingHubFailedRefresh(t *testing.T) {
+
+	cepSrv, _ := setupFakes(t)
+
+	cepSrv.AddCity("29900000", "Linhares")
+
+	s := domain.NewLocationService(domain.NewLocationRepository())
+	hub := domain.NewReadingHub(s, domain.StreamLimits{PollInterval: 20 * time.Millisecond, MaxSubscribers: 1, MaxPerCity: 1})
+
+	sub, _, err := subscribe(t, s, hub, "29900000")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	reading := receive(t, sub)
+	if got := errString(reading.Err); got != "500" {
+		t.Fatalf("expected error %q but got %q", "500", got)
+	}
+
+	// The failure is handed once, not on every refresh.
+	select {
+	case r := <-sub.C:
+		t.Fatalf("expected no reading while the refresh keeps failing but got %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Closing the last subscription frees its room.
+	sub.Close()
+
+	sub, _, err = subscribe(t, s, hub, "29900000")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	sub.Close()
+}
+
+func TestReadingHubOpenMeteoFallback(t *testing.T) {
+
+	cepSrv, weatherSrv := setupFakes(t)
+
+	geocodeSrv := geocodetest.NewServer()
+	t.Cleanup(geocodeSrv.Close)
+	t.Setenv("GEOCODE_BASE_URL", geocodeSrv.URL)
+
+	meteoSrv := openmeteotest.NewServer()
+	t.Cleanup(meteoSrv.Close)
+	t.Setenv("OPEN_METEO_BASE_URL", meteoSrv.URL)
+
+	// The CEPs are geocoded away from the centroid of Linhares, the only
+	// place Open-Meteo knows.
+	cepSrv.AddAddress("29902555", viacep.ViaCepResponse{Localidade: "Linhares", Uf: "ES", Ibge: "3203205"})
+	cepSrv.AddAddress("29900000", viacep.ViaCepResponse{Localidade: "Linhares", Uf: "ES", Ibge: "3203205"})
+	geocodeSrv.AddCoordinates("29902555", -19.41, -40.08)
+	geocodeSrv.AddCoordinates("29900000", -19.38, -40.05)
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Linhares", Region: "Espirito Santo", Country: "Brazil", Lat: -19.3946, Lon: -40.0643}, weather.CurrentWeather{FeelsLikeC: 25})
+	meteoSrv.AddPlace(-19.3946, -40.0643, 18)
+
+	// The budget is crossed by the first refresh.
+	t.Setenv("WEATHER_API_MONTHLY_BUDGET", "1")
+	t.Setenv("WEATHER_API_BUDGET_THRESHOLD", "1")
+	t.Setenv("WEATHER_API_BUDGET_FALLBACK", domain.FallbackOpenMeteo)
+
+	s := domain.NewLocationService(domain.NewLocationRepository())
+	hub := domain.NewReadingHub(s, domain.StreamLimits{PollInterval: 20 * time.Millisecond, MaxSubscribers: 10, MaxPerCity: 10})
+
+	first, _, err := subscribe(t, s, hub, "29902555")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	defer first.Close()
+
+	second, _, err := subscribe(t, s, hub, "29900000")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	defer second.Close()
+
+	for _, want := range []float64{25, 18} {
+		reading := receive(t, first)
+		if reading.Err != nil || reading.Current.FeelsLikeC != want {
+			t.Fatalf("expected a reading of %v °C but got %+v", want, reading)
+		}
+	}
+
+	// Later refreshes keep asking Open-Meteo, at the same place.
+	select {
+	case r := <-first.C:
+		t.Fatalf("expected no reading while the conditions are unchanged but got %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if hits := meteoSrv.Hits(); hits < 2 {
+		t.Errorf("expected the fallback to be polled but got %d hits", hits)
+	}
+}
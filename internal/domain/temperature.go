@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Temperature is a temperature in degrees Celsius that converts exactly to
+// the other supported scales.
+type Temperature float64
+
+const (
+	kelvinOffset = 273.15
+
+	// legacyKelvinOffset is the offset the service answered temp_k with
+	// before conversions were exact, kept for clients that depend on it.
+	legacyKelvinOffset = 273
+)
+
+// Rounding limits for the ?precision= query option.
+const (
+	DefaultPrecision = 2
+	MaxPrecision     = 6
+)
+
+// CompatKelvin273 is the ?compat= value (and TEMPERATURE_COMPAT setting)
+// that renders Kelvin as C + 273.
+const CompatKelvin273 = "kelvin273"
+
+func (t Temperature) Celsius() float64 {
+	return float64(t)
+}
+
+func (t Temperature) Fahrenheit() float64 {
+	return float64(t)*9/5 + 32
+}
+
+func (t Temperature) Kelvin() float64 {
+	return float64(t) + kelvinOffset
+}
+
+func (t Temperature) Rankine() float64 {
+	return (float64(t) + kelvinOffset) * 9 / 5
+}
+
+// legacyKelvin is Kelvin with the legacy offset of 273.
+func (t Temperature) legacyKelvin() float64 {
+	return float64(t) + legacyKelvinOffset
+}
+
+// ParsePrecision parses the ?precision= query option, the number of
+// decimals temperatures are rounded to. An empty string selects
+// DefaultPrecision.
+func ParsePrecision(s string) (int, error) {
+
+	if strings.TrimSpace(s) == "" {
+		return DefaultPrecision, nil
+	}
+
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 0 || p > MaxPrecision {
+		return 0, fmt.Errorf("invalid precision %q - must be between 0 and %d", s, MaxPrecision)
+	}
+
+	return p, nil
+}
+
+// ParseCompat parses the ?compat= query option and reports whether it asks
+// for the legacy Kelvin offset. An empty string selects fallback.
+func ParseCompat(s string, fallback bool) (bool, error) {
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return fallback, nil
+	case CompatKelvin273:
+		return true, nil
+	case "none":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid compat %q - example: %s", s, CompatKelvin273)
+	}
+}
+
+// temperatureView is a temperature converted to each scale selected in
+// opts.Units and rounded as opts asks.
+type temperatureView struct {
+	C, F, K, R *float64
+}
+
+func (t Temperature) view(opts ViewOptions) temperatureView {
+
+	var v temperatureView
+
+	if opts.Units.C {
+		v.C = opts.round(t.Celsius())
+	}
+	if opts.Units.F {
+		v.F = opts.round(t.Fahrenheit())
+	}
+	if opts.Units.K {
+		if opts.LegacyKelvin {
+			v.K = opts.round(t.legacyKelvin())
+		} else {
+			v.K = opts.round(t.Kelvin())
+		}
+	}
+	if opts.Units.R {
+		v.R = opts.round(t.Rankine())
+	}
+
+	return v
+}
+
+// round rounds v to opts.Precision decimals when opts.Round is set.
+func (opts ViewOptions) round(v float64) *float64 {
+
+	if opts.Round {
+		p := math.Pow10(opts.Precision)
+		v = math.Round(v*p) / p
+	}
+
+	return &v
+}
@@ -0,0 +1,120 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+)
+
+func TestTemperature(t *testing.T) {
+
+	tests := []struct {
+		celsius float64
+		wantF   float64
+		wantK   float64
+		wantR   float64
+	}{
+		{celsius: 0, wantF: 32, wantK: 273.15, wantR: 491.67},
+		{celsius: 100, wantF: 212, wantK: 373.15, wantR: 671.67},
+		{celsius: -40, wantF: -40, wantK: 233.15, wantR: 419.67},
+		{celsius: -273.15, wantF: -459.67, wantK: 0, wantR: 0},
+	}
+
+	const epsilon = 1e-9
+
+	for _, tt := range tests {
+		temp := domain.Temperature(tt.celsius)
+		for _, got := range []struct {
+			scale     string
+			got, want float64
+		}{
+			{"F", temp.Fahrenheit(), tt.wantF},
+			{"K", temp.Kelvin(), tt.wantK},
+			{"R", temp.Rankine(), tt.wantR},
+		} {
+			if d := got.got - got.want; d > epsilon || d < -epsilon {
+				t.Errorf("%v°C in %s: expected %v but got %v", tt.celsius, got.scale, got.want, got.got)
+			}
+		}
+	}
+}
+
+func TestTemperatureViewRounding(t *testing.T) {
+
+	l, _ := domain.NewLocation("12345678")
+	_ = l.SetCity("Linhares")
+	_ = l.SetTemperatures(28.5)
+
+	tests := []struct {
+		name  string
+		opts  domain.ViewOptions
+		wantF float64
+		wantK float64
+	}{
+		{name: "two decimals", opts: domain.ViewOptions{Units: domain.AllUnits, Round: true, Precision: 2}, wantF: 83.3, wantK: 301.65},
+		{name: "no decimals", opts: domain.ViewOptions{Units: domain.AllUnits, Round: true}, wantF: 83, wantK: 302},
+		{name: "legacy kelvin", opts: domain.ViewOptions{Units: domain.AllUnits, Round: true, Precision: 2, LegacyKelvin: true}, wantF: 83.3, wantK: 301.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			v := l.View(tt.opts)
+			if *v.TempF != tt.wantF || *v.TempK != tt.wantK {
+				t.Errorf("expected %v°F/%vK but got %v°F/%vK", tt.wantF, tt.wantK, *v.TempF, *v.TempK)
+			}
+		})
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "", want: domain.DefaultPrecision},
+		{in: "0", want: 0},
+		{in: "6", want: 6},
+		{in: "-1", wantErr: true},
+		{in: "7", wantErr: true},
+		{in: "one", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.ParsePrecision(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePrecision(%q): expected error %v but got %v", tt.in, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParsePrecision(%q): expected %d but got %d", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestParseCompat(t *testing.T) {
+
+	tests := []struct {
+		in       string
+		fallback bool
+		want     bool
+		wantErr  bool
+	}{
+		{in: "", fallback: false, want: false},
+		{in: "", fallback: true, want: true},
+		{in: "kelvin273", want: true},
+		{in: "none", fallback: true, want: false},
+		{in: "kelvin300", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.ParseCompat(tt.in, tt.fallback)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCompat(%q): expected error %v but got %v", tt.in, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseCompat(%q, %v): expected %v but got %v", tt.in, tt.fallback, tt.want, got)
+		}
+	}
+}
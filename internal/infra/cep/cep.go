@@ -1,48 +1,98 @@
 package cep
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/httpclient"
 )
 
+// DefaultBaseURL is the ViaCEP endpoint used when no other base URL is configured.
+const DefaultBaseURL = "https://viacep.com.br/ws"
+
 type ViaCepResponse struct {
-	Cep        string `json:"cep"`
-	Localidade string `json:"localidade"`
+	Cep         string `json:"cep"`
+	Logradouro  string `json:"logradouro"`
+	Complemento string `json:"complemento"`
+	Bairro      string `json:"bairro"`
+	Localidade  string `json:"localidade"`
+	Uf          string `json:"uf"`
+	Ibge        string `json:"ibge"`
+	Ddd         string `json:"ddd"`
 }
 
+// Client queries ViaCEP (or any server speaking its protocol) at BaseURL.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for baseURL. An empty baseURL falls back to the
+// VIACEP_BASE_URL environment variable and then to DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+
+	if baseURL == "" {
+		baseURL = os.Getenv("VIACEP_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: httpclient.New(httpclient.Config{TLS: &tls.Config{InsecureSkipVerify: true}, Timeout: 10 * time.Second}),
+	}
+}
+
+// GetCity looks up cep using a Client built from the environment.
 func GetCity(cep string) (string, error) {
+	return NewClient("").GetCity(cep)
+}
 
-	url := fmt.Sprintf("https://viacep.com.br/ws/%s/json/", cep)
+func (c *Client) GetCity(cep string) (string, error) {
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	address, err := c.GetAddress(context.Background(), cep)
+	if err != nil {
+		return "", err
 	}
-	client := &http.Client{Transport: tr}
 
-	req, err := http.NewRequest("GET", url, nil)
+	return address.Localidade, nil
+}
+
+// GetAddress returns everything ViaCEP knows about cep. Unknown CEPs yield
+// an empty response rather than an error, as ViaCEP answers them with 200.
+func (c *Client) GetAddress(ctx context.Context, cep string) (*ViaCepResponse, error) {
+
+	url := fmt.Sprintf("%s/%s/json/", c.BaseURL, cep)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Println("error to build request")
-		return "", fmt.Errorf("internal error")
+		return nil, fmt.Errorf("internal error")
 	}
 
-	resp, err := client.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error to do request to get cep:%v - error:%v\n", cep, err)
+		return nil, fmt.Errorf("error to do request to get cep:%v - error:%v\n", cep, err)
 	}
 
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response body: %v\n", err)
+		return nil, fmt.Errorf("error reading response body: %v\n", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("error to get cep data")
+		return nil, fmt.Errorf("error to get cep data")
 	}
 
 	var viacepResponse ViaCepResponse
@@ -50,8 +100,8 @@ func GetCity(cep string) (string, error) {
 	err = json.Unmarshal(body, &viacepResponse)
 	if err != nil {
 		log.Println("Error unmarshalling JSON:", err)
-		return "", fmt.Errorf("error decode json")
+		return nil, fmt.Errorf("error decode json")
 	}
 
-	return viacepResponse.Localidade, nil
+	return &viacepResponse, nil
 }
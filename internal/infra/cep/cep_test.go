@@ -1,18 +1,105 @@
 package cep_test
 
 import (
-	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"context"
+	"net/http"
 	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep/ceptest"
 )
 
 func TestCepGET(t *testing.T) {
 
-	c, err := cep.GetCity("01308080")
+	srv := ceptest.NewServer()
+	defer srv.Close()
+
+	srv.AddCity("01308080", "São Paulo")
+	srv.SetResponse("99999999", ceptest.Response{Status: http.StatusInternalServerError, Body: "boom"})
+	srv.SetResponse("88888888", ceptest.Response{Status: http.StatusOK, Body: "{not json"})
+	srv.SetResponse("77777777", ceptest.Response{Status: http.StatusOK, Body: `{"localidade":"Lento"}`, Latency: 200 * time.Millisecond})
+
+	tests := []struct {
+		name    string
+		cep     string
+		want    string
+		wantErr bool
+	}{
+		{name: "found", cep: "01308080", want: "São Paulo"},
+		{name: "not found", cep: "12345678", want: ""},
+		{name: "invalid format", cep: "1234", wantErr: true},
+		{name: "upstream failure", cep: "99999999", wantErr: true},
+		{name: "malformed body", cep: "88888888", wantErr: true},
+		{name: "timeout", cep: "77777777", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			c := cep.NewClient(srv.URL)
+			c.HTTPClient.Timeout = 50 * time.Millisecond
+
+			got, err := c.GetCity(tt.cep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected city %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetAddress(t *testing.T) {
+
+	srv := ceptest.NewServer()
+	defer srv.Close()
+
+	want := cep.ViaCepResponse{
+		Cep:        "01308-080",
+		Logradouro: "Rua Avanhandava",
+		Bairro:     "Bela Vista",
+		Localidade: "São Paulo",
+		Uf:         "SP",
+		Ibge:       "3550308",
+		Ddd:        "11",
+	}
+	srv.AddAddress("01308080", want)
+
+	got, err := cep.NewClient(srv.URL).GetAddress(context.Background(), "01308080")
 	if err != nil {
-		t.Error("error to get info by cep")
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	if *got != want {
+		t.Errorf("expected %+v but got %+v", want, *got)
 	}
+}
+
+func TestNewClientBaseURL(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		baseURL string
+		env     string
+		want    string
+	}{
+		{name: "default", want: cep.DefaultBaseURL},
+		{name: "from env", env: "http://fake-viacep/ws/", want: "http://fake-viacep/ws"},
+		{name: "explicit wins", baseURL: "http://explicit", env: "http://fake-viacep", want: "http://explicit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			t.Setenv("VIACEP_BASE_URL", tt.env)
 
-	if c != "São Paulo" {
-		t.Error("error to get city")
+			c := cep.NewClient(tt.baseURL)
+			if c.BaseURL != tt.want {
+				t.Errorf("expected base url %q but got %q", tt.want, c.BaseURL)
+			}
+		})
 	}
 }
@@ -0,0 +1,129 @@
+// Package ceptest provides a scriptable fake ViaCEP server for tests.
+package ceptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"time"
+
+	viacep "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+)
+
+// Response is a canned reply served for a single CEP.
+type Response struct {
+	Status  int
+	Body    string
+	Latency time.Duration
+}
+
+// Server is an httptest.Server answering GET /{cep}/json/ like ViaCEP does.
+// CEPs without a canned response get ViaCEP's `{"erro": true}` reply.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	responses  map[string]Response
+	latency    time.Duration
+	hits       map[string]int
+	requestIDs map[string][]string
+}
+
+var cepFormat = regexp.MustCompile(`^\d{8}$`)
+
+// NewServer starts a fake ViaCEP server. Callers must Close it.
+func NewServer() *Server {
+
+	s := &Server{
+		responses:  make(map[string]Response),
+		hits:       make(map[string]int),
+		requestIDs: make(map[string][]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{cep}/json/", s.handleCEP)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// AddCity registers a successful lookup of cep resolving to city.
+func (s *Server) AddCity(cep, city string) {
+	s.AddAddress(cep, viacep.ViaCepResponse{Localidade: city})
+}
+
+// AddAddress registers a successful lookup of cep resolving to address.
+// The formatted CEP is filled in when address.Cep is empty.
+func (s *Server) AddAddress(cep string, address viacep.ViaCepResponse) {
+
+	if address.Cep == "" {
+		address.Cep = cep[:5] + "-" + cep[5:]
+	}
+
+	body, _ := json.Marshal(address)
+
+	s.SetResponse(cep, Response{Status: http.StatusOK, Body: string(body)})
+}
+
+// SetResponse scripts the exact reply for cep.
+func (s *Server) SetResponse(cep string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[cep] = r
+}
+
+// SetLatency delays every reply by d, on top of any per-response latency.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Hits reports how many requests were received for cep.
+func (s *Server) Hits(cep string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[cep]
+}
+
+// RequestIDs returns the X-Request-ID header of every request received for
+// cep, in arrival order.
+func (s *Server) RequestIDs(cep string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requestIDs[cep]...)
+}
+
+func (s *Server) handleCEP(w http.ResponseWriter, r *http.Request) {
+
+	cep := r.PathValue("cep")
+
+	s.mu.Lock()
+	s.hits[cep]++
+	s.requestIDs[cep] = append(s.requestIDs[cep], r.Header.Get("X-Request-ID"))
+	resp, ok := s.responses[cep]
+	latency := s.latency + resp.Latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if !ok {
+		if !cepFormat.MatchString(cep) {
+			resp = Response{Status: http.StatusBadRequest, Body: "Bad Request"}
+		} else {
+			resp = Response{Status: http.StatusOK, Body: `{"erro": true}`}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
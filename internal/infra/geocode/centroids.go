@@ -0,0 +1,78 @@
+package geocode
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// centroidsCSV is the built-in table of municipality centroids keyed by IBGE
+// code. It only lists state capitals and a few other cities; point
+// IBGE_CENTROIDS_FILE at the full IBGE table (same columns) to cover every
+// municipality.
+//
+//go:embed centroids.csv
+var centroidsCSV string
+
+var (
+	centroidsOnce sync.Once
+	centroids     map[string]Coordinates
+)
+
+// Centroid returns the centroid of the municipality with the given IBGE code.
+func Centroid(ibge string) (Coordinates, bool) {
+
+	centroidsOnce.Do(func() {
+
+		var r io.Reader = strings.NewReader(centroidsCSV)
+
+		if path := os.Getenv("IBGE_CENTROIDS_FILE"); path != "" {
+			f, err := os.Open(path)
+			if err == nil {
+				defer f.Close()
+				r = f
+			}
+		}
+
+		table, err := ParseCentroids(r)
+		if err != nil {
+			table, _ = ParseCentroids(strings.NewReader(centroidsCSV))
+		}
+		centroids = table
+	})
+
+	c, ok := centroids[ibge]
+	return c, ok
+}
+
+// ParseCentroids reads a CSV with the header ibge,name,uf,lat,lon.
+func ParseCentroids(r io.Reader) (map[string]Coordinates, error) {
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(map[string]Coordinates, len(records))
+	for i, record := range records {
+
+		if i == 0 || len(record) < 5 {
+			continue
+		}
+
+		lat, errLat := strconv.ParseFloat(record[3], 64)
+		lon, errLon := strconv.ParseFloat(record[4], 64)
+		if errLat != nil || errLon != nil {
+			return nil, fmt.Errorf("invalid coordinates on line %d", i+1)
+		}
+
+		table[record[0]] = Coordinates{Lat: lat, Lon: lon}
+	}
+
+	return table, nil
+}
@@ -0,0 +1,107 @@
+// Package geocode resolves a CEP to latitude/longitude, either through the
+// AwesomeAPI CEP service or through an offline table of IBGE municipality
+// centroids.
+package geocode
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/httpclient"
+)
+
+// DefaultBaseURL is the AwesomeAPI endpoint used when no other base URL is configured.
+const DefaultBaseURL = "https://cep.awesomeapi.com.br/json"
+
+// Coordinates is a point in decimal degrees.
+type Coordinates struct {
+	Lat float64
+	Lon float64
+}
+
+// AwesomeAPIResponse holds the fields used from AwesomeAPI's CEP lookup,
+// which encodes coordinates as strings.
+type AwesomeAPIResponse struct {
+	Cep      string `json:"cep"`
+	City     string `json:"city"`
+	State    string `json:"state"`
+	CityIBGE string `json:"city_ibge"`
+	Lat      string `json:"lat"`
+	Lng      string `json:"lng"`
+}
+
+// Client queries AwesomeAPI (or any server speaking its protocol) at BaseURL.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for baseURL. An empty baseURL falls back to the
+// GEOCODE_BASE_URL environment variable and then to DefaultBaseURL.
+func NewClient(baseURL string) *Client {
+
+	if baseURL == "" {
+		baseURL = os.Getenv("GEOCODE_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: httpclient.New(httpclient.Config{TLS: &tls.Config{InsecureSkipVerify: true}, Timeout: 10 * time.Second}),
+	}
+}
+
+// GetCoordinates returns the coordinates of cep.
+func (c *Client) GetCoordinates(ctx context.Context, cep string) (*Coordinates, error) {
+
+	url := fmt.Sprintf("%s/%s", c.BaseURL, cep)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		log.Println("error to build request")
+		return nil, fmt.Errorf("internal error")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error to do request to geocode cep:%v - error:%v\n", cep, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v\n", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("response expected 200 but got %v\n", resp.StatusCode)
+	}
+
+	var geocodeResponse AwesomeAPIResponse
+
+	err = json.Unmarshal(body, &geocodeResponse)
+	if err != nil {
+		log.Println("Error unmarshalling JSON:", err)
+		return nil, fmt.Errorf("error decode json")
+	}
+
+	lat, errLat := strconv.ParseFloat(geocodeResponse.Lat, 64)
+	lon, errLon := strconv.ParseFloat(geocodeResponse.Lng, 64)
+	if errLat != nil || errLon != nil {
+		return nil, fmt.Errorf("no coordinates for cep:%v", cep)
+	}
+
+	return &Coordinates{Lat: lat, Lon: lon}, nil
+}
@@ -0,0 +1,79 @@
+package geocode_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+)
+
+func TestGetCoordinates(t *testing.T) {
+
+	srv := geocodetest.NewServer()
+	defer srv.Close()
+
+	srv.AddCoordinates("01308080", -23.5505, -46.6333)
+	srv.SetResponse("22222222", geocodetest.Response{Status: http.StatusOK, Body: `{"cep":"22222222","lat":"","lng":""}`})
+	srv.SetResponse("99999999", geocodetest.Response{Status: http.StatusInternalServerError})
+
+	tests := []struct {
+		name    string
+		cep     string
+		want    geocode.Coordinates
+		wantErr bool
+	}{
+		{name: "found", cep: "01308080", want: geocode.Coordinates{Lat: -23.5505, Lon: -46.6333}},
+		{name: "not found", cep: "12345678", wantErr: true},
+		{name: "without coordinates", cep: "22222222", wantErr: true},
+		{name: "upstream failure", cep: "99999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := geocode.NewClient(srv.URL).GetCoordinates(context.Background(), tt.cep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("expected %+v but got %+v", tt.want, *got)
+			}
+		})
+	}
+}
+
+func TestCentroid(t *testing.T) {
+
+	tests := []struct {
+		ibge   string
+		want   geocode.Coordinates
+		wantOK bool
+	}{
+		{ibge: "3550308", want: geocode.Coordinates{Lat: -23.5329, Lon: -46.6395}, wantOK: true},
+		{ibge: "2201903", want: geocode.Coordinates{Lat: -9.07124, Lon: -44.3591}, wantOK: true},
+		{ibge: "0000000"},
+	}
+
+	for _, tt := range tests {
+		got, ok := geocode.Centroid(tt.ibge)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("Centroid(%s): expected %+v/%v but got %+v/%v", tt.ibge, tt.want, tt.wantOK, got, ok)
+		}
+	}
+}
+
+func TestParseCentroids(t *testing.T) {
+
+	table, err := geocode.ParseCentroids(strings.NewReader("ibge,name,uf,lat,lon\n1,A,AC,-1.5,2.5\n"))
+	if err != nil || table["1"] != (geocode.Coordinates{Lat: -1.5, Lon: 2.5}) {
+		t.Errorf("unexpected table %v, err %v", table, err)
+	}
+
+	_, err = geocode.ParseCentroids(strings.NewReader("ibge,name,uf,lat,lon\n1,A,AC,x,y\n"))
+	if err == nil {
+		t.Errorf("expected invalid coordinates to be rejected")
+	}
+}
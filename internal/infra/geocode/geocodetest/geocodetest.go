@@ -0,0 +1,110 @@
+// Package geocodetest provides a scriptable fake AwesomeAPI CEP server for
+// tests.
+package geocodetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode"
+)
+
+// Response is a canned reply served for a single CEP.
+type Response struct {
+	Status  int
+	Body    string
+	Latency time.Duration
+}
+
+// Server is an httptest.Server answering GET /{cep} like AwesomeAPI does.
+// CEPs without a canned response get a 404.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]Response
+	latency   time.Duration
+	hits      map[string]int
+}
+
+// NewServer starts a fake AwesomeAPI server. Callers must Close it.
+func NewServer() *Server {
+
+	s := &Server{
+		responses: make(map[string]Response),
+		hits:      make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{cep}", s.handleCEP)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// AddCoordinates registers a successful lookup of cep at lat/lon.
+func (s *Server) AddCoordinates(cep string, lat, lon float64) {
+
+	body, _ := json.Marshal(geocode.AwesomeAPIResponse{
+		Cep: cep,
+		Lat: strconv.FormatFloat(lat, 'f', -1, 64),
+		Lng: strconv.FormatFloat(lon, 'f', -1, 64),
+	})
+
+	s.SetResponse(cep, Response{Status: http.StatusOK, Body: string(body)})
+}
+
+// SetResponse scripts the exact reply for cep.
+func (s *Server) SetResponse(cep string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[cep] = r
+}
+
+// SetLatency delays every reply by d, on top of any per-response latency.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Hits reports how many requests were received for cep.
+func (s *Server) Hits(cep string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[cep]
+}
+
+func (s *Server) handleCEP(w http.ResponseWriter, r *http.Request) {
+
+	cep := r.PathValue("cep")
+
+	s.mu.Lock()
+	s.hits[cep]++
+	resp, ok := s.responses[cep]
+	latency := s.latency + resp.Latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if !ok {
+		resp = Response{
+			Status: http.StatusNotFound,
+			Body:   `{"status":404,"code":"not_found","message":"O CEP ` + cep + ` nao foi encontrado"}`,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
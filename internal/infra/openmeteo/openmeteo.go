@@ -0,0 +1,259 @@
+// Package openmeteo queries Open-Meteo, a keyless weather provider used as a
+// secondary source when the WeatherAPI budget runs out. Answers are mapped
+// to the WeatherAPI types so that callers handle both providers alike.
+package openmeteo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/httpclient"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+)
+
+// Default endpoints of the forecast and historical weather APIs.
+const (
+	DefaultBaseURL    = "https://api.open-meteo.com/v1"
+	DefaultArchiveURL = "https://archive-api.open-meteo.com/v1"
+)
+
+const dailyVariables = "weather_code,temperature_2m_max,temperature_2m_min,temperature_2m_mean,precipitation_sum,precipitation_probability_max"
+
+// Client queries Open-Meteo (or any server speaking its protocol) at
+// BaseURL and, for past dates, ArchiveURL.
+type Client struct {
+	BaseURL    string
+	ArchiveURL string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for baseURL and archiveURL. Empty values fall
+// back to OPEN_METEO_BASE_URL / OPEN_METEO_ARCHIVE_URL and then to the
+// defaults.
+func NewClient(baseURL, archiveURL string) *Client {
+
+	if baseURL == "" {
+		baseURL = os.Getenv("OPEN_METEO_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if archiveURL == "" {
+		archiveURL = os.Getenv("OPEN_METEO_ARCHIVE_URL")
+	}
+	if archiveURL == "" {
+		archiveURL = DefaultArchiveURL
+	}
+
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		ArchiveURL: strings.TrimRight(archiveURL, "/"),
+		HTTPClient: httpclient.New(httpclient.Config{Timeout: 10 * time.Second}),
+	}
+}
+
+// Response is the body of Open-Meteo's forecast and archive endpoints, with
+// the current and daily variables requested by Client.
+type Response struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Current   *struct {
+		Time                int64   `json:"time"`
+		Temperature         float64 `json:"temperature_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		RelativeHumidity    int     `json:"relative_humidity_2m"`
+		WeatherCode         int     `json:"weather_code"`
+		WindSpeed           float64 `json:"wind_speed_10m"`
+		WindDirection       int     `json:"wind_direction_10m"`
+		PressureMSL         float64 `json:"pressure_msl"`
+	} `json:"current,omitempty"`
+	Daily *struct {
+		Time                     []string  `json:"time"`
+		WeatherCode              []int     `json:"weather_code"`
+		TemperatureMax           []float64 `json:"temperature_2m_max"`
+		TemperatureMin           []float64 `json:"temperature_2m_min"`
+		TemperatureMean          []float64 `json:"temperature_2m_mean"`
+		PrecipitationSum         []float64 `json:"precipitation_sum"`
+		PrecipitationProbability []int     `json:"precipitation_probability_max"`
+	} `json:"daily,omitempty"`
+}
+
+// GetConditions returns the current conditions at the coordinates of q.
+// Open-Meteo has no air quality nor alerts in this endpoint, so inc is
+// ignored.
+func (c *Client) GetConditions(ctx context.Context, q weather.Query, inc weather.Include) (*weather.WeatherResponse, error) {
+
+	var r Response
+	err := c.get(ctx, c.BaseURL+"/forecast", q, url.Values{
+		"current":    {"temperature_2m,apparent_temperature,relative_humidity_2m,weather_code,wind_speed_10m,wind_direction_10m,pressure_msl"},
+		"timeformat": {"unixtime"},
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Current == nil {
+		return nil, fmt.Errorf("no current conditions for %v", q)
+	}
+
+	return &weather.WeatherResponse{
+		Location: location(q, r),
+		Current: weather.CurrentWeather{
+			LastUpdatedEpoch: r.Current.Time,
+			TempC:            r.Current.Temperature,
+			FeelsLikeC:       r.Current.ApparentTemperature,
+			Humidity:         r.Current.RelativeHumidity,
+			WindKph:          r.Current.WindSpeed,
+			WindDegree:       r.Current.WindDirection,
+			PressureMb:       r.Current.PressureMSL,
+			Condition:        weather.Condition{Text: Description(r.Current.WeatherCode, q.Lang)},
+		},
+	}, nil
+}
+
+// GetForecast returns the daily forecast at the coordinates of q, starting
+// today.
+func (c *Client) GetForecast(ctx context.Context, q weather.Query, days int) (*weather.ForecastResponse, error) {
+
+	var r Response
+	err := c.get(ctx, c.BaseURL+"/forecast", q, url.Values{
+		"daily":         {dailyVariables},
+		"forecast_days": {strconv.Itoa(days)},
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return forecast(q, r), nil
+}
+
+// GetHistory returns the observed weather at the coordinates of q on the
+// local date day.
+func (c *Client) GetHistory(ctx context.Context, q weather.Query, day time.Time) (*weather.ForecastResponse, error) {
+
+	date := day.Format(time.DateOnly)
+
+	var r Response
+	err := c.get(ctx, c.ArchiveURL+"/archive", q, url.Values{
+		"daily":      {strings.TrimSuffix(dailyVariables, ",precipitation_probability_max")},
+		"start_date": {date},
+		"end_date":   {date},
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	f := forecast(q, r)
+	if len(f.Forecast.ForecastDay) == 0 {
+		return nil, fmt.Errorf("no history for %v on %v", q, date)
+	}
+
+	return f, nil
+}
+
+// location is the place of the answer. Open-Meteo answers for coordinates
+// only, so the names are those of the query.
+func location(q weather.Query, r Response) weather.WeatherLocation {
+	return weather.WeatherLocation{Name: q.City, Region: q.Region, Country: q.Country, Lat: r.Latitude, Lon: r.Longitude}
+}
+
+func forecast(q weather.Query, r Response) *weather.ForecastResponse {
+
+	f := &weather.ForecastResponse{Location: location(q, r)}
+	if r.Daily == nil {
+		return f
+	}
+
+	d := r.Daily
+	for i, date := range d.Time {
+
+		day := weather.ForecastDay{Date: date}
+		if i < len(d.TemperatureMax) && i < len(d.TemperatureMin) {
+			day.Day.MaxTempC, day.Day.MinTempC = d.TemperatureMax[i], d.TemperatureMin[i]
+			day.Day.AvgTempC = (day.Day.MaxTempC + day.Day.MinTempC) / 2
+		}
+		if i < len(d.TemperatureMean) {
+			day.Day.AvgTempC = d.TemperatureMean[i]
+		}
+		if i < len(d.PrecipitationSum) {
+			day.Day.TotalPrecipMm = d.PrecipitationSum[i]
+		}
+		if i < len(d.PrecipitationProbability) {
+			day.Day.DailyChanceOfRain = d.PrecipitationProbability[i]
+		}
+		if i < len(d.WeatherCode) {
+			day.Day.Condition.Text = Description(d.WeatherCode[i], q.Lang)
+		}
+
+		f.Forecast.ForecastDay = append(f.Forecast.ForecastDay, day)
+	}
+
+	return f
+}
+
+// get queries endpoint at the coordinates of q with params and decodes the
+// JSON response into out.
+func (c *Client) get(ctx context.Context, endpoint string, q weather.Query, params url.Values, out any) error {
+
+	if q.Lat == 0 || q.Lon == 0 {
+		return fmt.Errorf("open-meteo needs the coordinates of %v", q)
+	}
+
+	params.Set("latitude", strconv.FormatFloat(q.Lat, 'f', -1, 64))
+	params.Set("longitude", strconv.FormatFloat(q.Lon, 'f', -1, 64))
+	params.Set("timezone", "auto")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error to build open-meteo request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error to do request to open-meteo for %v: %w", q, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading open-meteo response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("open-meteo response expected 200 but got %v", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error to decode open-meteo response: %w", err)
+	}
+
+	return nil
+}
+
+// langs maps the WeatherAPI language codes of weather.Query to the languages
+// of the condition texts. English is the default of both.
+var langs = map[string]i18n.Lang{
+	"pt": i18n.Portuguese,
+	"es": i18n.Spanish,
+}
+
+// Description returns the text of a WMO weather code in lang, a WeatherAPI
+// language code as in weather.Query, or in English when lang is empty or
+// unsupported.
+func Description(code int, lang string) string {
+
+	l, ok := langs[lang]
+	if !ok {
+		l = i18n.English
+	}
+
+	return i18n.Condition(l, code)
+}
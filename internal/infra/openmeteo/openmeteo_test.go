@@ -0,0 +1,90 @@
+package openmeteo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/openmeteo"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/openmeteo/openmeteotest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+)
+
+func TestClient(t *testing.T) {
+
+	srv := openmeteotest.NewServer()
+	defer srv.Close()
+
+	srv.AddPlace(-19.39, -40.07, 22)
+
+	c := openmeteo.NewClient(srv.URL, srv.URL)
+	linhares := weather.Query{City: "Linhares", Region: "ES", Country: "Brazil", Lat: -19.39, Lon: -40.07}
+	linharesPT := linhares
+	linharesPT.Lang = "pt"
+
+	tests := []struct {
+		name     string
+		query    weather.Query
+		wantText string
+		wantErr  bool
+	}{
+		{name: "known place", query: linhares, wantText: "Partly cloudy"},
+		{name: "in portuguese", query: linharesPT, wantText: "Parcialmente nublado"},
+		{name: "unknown place", query: weather.Query{City: "Atlantida", Lat: 1, Lon: 1}, wantErr: true},
+		{name: "without coordinates", query: weather.Query{City: "Linhares"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			current, err := c.GetConditions(context.Background(), tt.query, weather.Include{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if current.Location.Name != "Linhares" || current.Current.FeelsLikeC != 22 || current.Current.Condition.Text != tt.wantText {
+				t.Errorf("unexpected conditions %+v", current)
+			}
+
+			forecast, err := c.GetForecast(context.Background(), tt.query, 3)
+			if err != nil || len(forecast.Forecast.ForecastDay) != 3 {
+				t.Fatalf("expected 3 forecast days but got %+v %v", forecast, err)
+			}
+			if d := forecast.Forecast.ForecastDay[0].Day; d.MaxTempC != 27 || d.MinTempC != 17 || d.AvgTempC != 22 || d.DailyChanceOfRain != 40 || d.Condition.Text != tt.wantText {
+				t.Errorf("unexpected forecast day %+v", d)
+			}
+
+			day := time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC)
+			history, err := c.GetHistory(context.Background(), tt.query, day)
+			if err != nil || len(history.Forecast.ForecastDay) != 1 || history.Forecast.ForecastDay[0].Date != "2024-06-12" {
+				t.Errorf("expected the history of 2024-06-12 but got %+v %v", history, err)
+			}
+		})
+	}
+}
+
+func TestDescription(t *testing.T) {
+
+	tests := []struct {
+		code int
+		lang string
+		want string
+	}{
+		{code: 0, want: "Clear sky"},
+		{code: 63, want: "Moderate rain"},
+		{code: 42, want: "Unknown"},
+		{code: 63, lang: "pt", want: "Chuva moderada"},
+		{code: 95, lang: "es", want: "Tormenta"},
+		{code: 42, lang: "pt", want: "Desconhecido"},
+		{code: 0, lang: "fr", want: "Clear sky"},
+	}
+
+	for _, tt := range tests {
+		if got := openmeteo.Description(tt.code, tt.lang); got != tt.want {
+			t.Errorf("expected %q for %d in %q but got %q", tt.want, tt.code, tt.lang, got)
+		}
+	}
+}
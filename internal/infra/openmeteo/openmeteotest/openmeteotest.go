@@ -0,0 +1,126 @@
+// Package openmeteotest provides a fake Open-Meteo server for tests.
+package openmeteotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an httptest.Server answering GET /forecast and GET /archive
+// like Open-Meteo does, for the coordinates registered with AddPlace. Every
+// day of a place has the same readings, and its current conditions are
+// always observed at the time the server started, so that polling a place
+// reads the same conditions until they are changed.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	places   map[string]float64
+	hits     int
+	observed int64
+}
+
+// NewServer starts a fake Open-Meteo server. Callers must Close it.
+func NewServer() *Server {
+
+	s := &Server{places: make(map[string]float64), observed: time.Now().Unix()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /forecast", s.handle)
+	mux.HandleFunc("GET /archive", s.handle)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// AddPlace registers the coordinates lat,lon with the temperature tempC, in
+// Celsius, and a partly cloudy sky.
+func (s *Server) AddPlace(lat, lon, tempC float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.places[key(lat, lon)] = tempC
+}
+
+// Hits reports how many requests were received.
+func (s *Server) Hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits
+}
+
+func key(lat, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	s.hits++
+	lat, _ := strconv.ParseFloat(r.URL.Query().Get("latitude"), 64)
+	lon, _ := strconv.ParseFloat(r.URL.Query().Get("longitude"), 64)
+	temp, ok := s.places[key(lat, lon)]
+	s.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":true,"reason":"unknown place"}`))
+		return
+	}
+
+	body := map[string]any{"latitude": lat, "longitude": lon}
+
+	if r.URL.Query().Get("current") != "" {
+		body["current"] = map[string]any{
+			"time":                 s.observed,
+			"temperature_2m":       temp,
+			"apparent_temperature": temp,
+			"relative_humidity_2m": 60,
+			"weather_code":         2,
+			"wind_speed_10m":       10,
+			"wind_direction_10m":   90,
+			"pressure_msl":         1013,
+		}
+	}
+
+	if r.URL.Query().Get("daily") != "" {
+
+		var dates []string
+		if start := r.URL.Query().Get("start_date"); start != "" {
+			dates = []string{start}
+		} else {
+			days, _ := strconv.Atoi(r.URL.Query().Get("forecast_days"))
+			for i := 0; i < days; i++ {
+				dates = append(dates, time.Now().AddDate(0, 0, i).Format(time.DateOnly))
+			}
+		}
+
+		daily := map[string]any{"time": dates}
+		for _, v := range strings.Split(r.URL.Query().Get("daily"), ",") {
+			values := make([]float64, len(dates))
+			for i := range values {
+				switch v {
+				case "weather_code":
+					values[i] = 2
+				case "temperature_2m_max":
+					values[i] = temp + 5
+				case "temperature_2m_min":
+					values[i] = temp - 5
+				case "temperature_2m_mean":
+					values[i] = temp
+				case "precipitation_probability_max":
+					values[i] = 40
+				}
+			}
+			daily[v] = values
+		}
+		body["daily"] = daily
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
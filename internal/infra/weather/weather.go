@@ -1,6 +1,7 @@
 package weather
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -9,60 +10,308 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/httpclient"
 )
 
+// DefaultBaseURL is the WeatherAPI endpoint used when no other base URL is configured.
+const DefaultBaseURL = "http://api.weatherapi.com/v1"
+
 type WeatherResponse struct {
-	Current CurrentWeather `json:"current"`
+	Location WeatherLocation `json:"location"`
+	Current  CurrentWeather  `json:"current"`
+	Alerts   Alerts          `json:"alerts"`
+}
+
+// WeatherLocation is the place WeatherAPI resolved the query to.
+type WeatherLocation struct {
+	Name    string  `json:"name"`
+	Region  string  `json:"region"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+// Query identifies the place to look up. Coordinates take precedence when
+// both Lat and Lon are set; otherwise City, Region and Country are joined so
+// that homonymous cities in different states are told apart. Lang, a
+// WeatherAPI language code such as "pt", selects the language of the
+// condition texts; it is not part of String.
+type Query struct {
+	City    string
+	Region  string
+	Country string
+	Lat     float64
+	Lon     float64
+	Lang    string
+}
+
+func (q Query) String() string {
+
+	if q.Lat != 0 && q.Lon != 0 {
+		return strconv.FormatFloat(q.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(q.Lon, 'f', -1, 64)
+	}
+
+	parts := []string{q.City}
+	for _, p := range []string{q.Region, q.Country} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	return strings.Join(parts, ", ")
 }
 
 type CurrentWeather struct {
-	FeelsLikeC float64 `json:"feelslike_c"`
+	LastUpdatedEpoch int64       `json:"last_updated_epoch"`
+	TempC            float64     `json:"temp_c"`
+	FeelsLikeC       float64     `json:"feelslike_c"`
+	Humidity         int         `json:"humidity"`
+	WindKph          float64     `json:"wind_kph"`
+	WindDegree       int         `json:"wind_degree"`
+	WindDir          string      `json:"wind_dir"`
+	PressureMb       float64     `json:"pressure_mb"`
+	UV               float64     `json:"uv"`
+	Condition        Condition   `json:"condition"`
+	AirQuality       *AirQuality `json:"air_quality,omitempty"`
+}
+
+// AirQuality holds the pollutant concentrations in μg/m3 returned with
+// aqi=yes. USEPAIndex goes from 1 (Good) to 6 (Hazardous).
+type AirQuality struct {
+	CO           float64 `json:"co"`
+	NO2          float64 `json:"no2"`
+	O3           float64 `json:"o3"`
+	SO2          float64 `json:"so2"`
+	PM25         float64 `json:"pm2_5"`
+	PM10         float64 `json:"pm10"`
+	USEPAIndex   int     `json:"us-epa-index"`
+	GBDefraIndex int     `json:"gb-defra-index"`
+}
+
+// Alerts are the government weather alerts returned with alerts=yes.
+type Alerts struct {
+	Alert []Alert `json:"alert"`
+}
+
+// Alert is a severe weather alert. Effective and Expires are RFC 3339
+// timestamps.
+type Alert struct {
+	Headline    string `json:"headline"`
+	MsgType     string `json:"msgtype"`
+	Severity    string `json:"severity"`
+	Urgency     string `json:"urgency"`
+	Areas       string `json:"areas"`
+	Category    string `json:"category"`
+	Certainty   string `json:"certainty"`
+	Event       string `json:"event"`
+	Note        string `json:"note"`
+	Effective   string `json:"effective"`
+	Expires     string `json:"expires"`
+	Desc        string `json:"desc"`
+	Instruction string `json:"instruction"`
+}
+
+// Include selects the optional data requested along with the current
+// conditions.
+type Include struct {
+	AirQuality bool
+	Alerts     bool
 }
 
+// Condition is WeatherAPI's description of the sky, e.g. "Partly cloudy"
+// with code 1003.
+type Condition struct {
+	Text string `json:"text"`
+	Icon string `json:"icon"`
+	Code int    `json:"code"`
+}
+
+// ForecastResponse is the body of WeatherAPI's forecast.json.
+type ForecastResponse struct {
+	Location WeatherLocation `json:"location"`
+	Current  CurrentWeather  `json:"current"`
+	Forecast Forecast        `json:"forecast"`
+	Alerts   Alerts          `json:"alerts"`
+}
+
+type Forecast struct {
+	ForecastDay []ForecastDay `json:"forecastday"`
+}
+
+// ForecastDay is the forecast for one local day, dated "2006-01-02".
+type ForecastDay struct {
+	Date      string   `json:"date"`
+	DateEpoch int64    `json:"date_epoch"`
+	Day       DayStats `json:"day"`
+}
+
+// DayStats summarises a forecast day.
+type DayStats struct {
+	MaxTempC          float64   `json:"maxtemp_c"`
+	MinTempC          float64   `json:"mintemp_c"`
+	AvgTempC          float64   `json:"avgtemp_c"`
+	TotalPrecipMm     float64   `json:"totalprecip_mm"`
+	DailyChanceOfRain int       `json:"daily_chance_of_rain"`
+	Condition         Condition `json:"condition"`
+}
+
+// Client queries WeatherAPI (or any server speaking its protocol) at BaseURL.
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a Client for baseURL authenticated with apiKey. Empty
+// values fall back to WEATHER_API_BASE_URL / WEATHER_API_KEY and then to
+// DefaultBaseURL.
+func NewClient(baseURL, apiKey string) *Client {
+
+	if baseURL == "" {
+		baseURL = os.Getenv("WEATHER_API_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("WEATHER_API_KEY")
+	}
+
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: httpclient.New(httpclient.Config{TLS: &tls.Config{InsecureSkipVerify: true}, Timeout: 10 * time.Second}),
+	}
+}
+
+// GetWeather looks up city using a Client built from the environment.
 func GetWeather(city string) (float64, error) {
+	return NewClient("", "").GetWeather(city)
+}
 
-	apiKey := os.Getenv("WEATHER_API_KEY")
+func (c *Client) GetWeather(city string) (float64, error) {
 
-	encodedCity := url.QueryEscape(city)
+	weatherResponse, err := c.GetCurrent(Query{City: city})
+	if err != nil {
+		return 0, err
+	}
 
-	url := fmt.Sprintf("http://api.weatherapi.com/v1/current.json?key=%s&q=%s&aqi=no",
-		apiKey,
-		encodedCity)
+	return weatherResponse.Current.FeelsLikeC, nil
+}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+// GetCurrent returns the current conditions for q along with the location
+// WeatherAPI resolved it to, so callers can check it is the expected place.
+func (c *Client) GetCurrent(q Query) (*WeatherResponse, error) {
+	return c.GetConditions(context.Background(), q, Include{})
+}
+
+// GetConditions is GetCurrent with the optional data selected by inc. Alerts
+// are only served by forecast.json, so they cost a one-day forecast instead
+// of a current.json call.
+func (c *Client) GetConditions(ctx context.Context, q Query, inc Include) (*WeatherResponse, error) {
+
+	aqi := "aqi=no"
+	if inc.AirQuality {
+		aqi = "aqi=yes"
 	}
-	client := &http.Client{Transport: tr}
 
-	req, err := http.NewRequest("GET", url, nil)
+	var weatherResponse WeatherResponse
+
+	var err error
+	if inc.Alerts {
+		err = c.get(ctx, "forecast.json", q, "days=1&alerts=yes&"+aqi, &weatherResponse)
+	} else {
+		err = c.get(ctx, "current.json", q, aqi, &weatherResponse)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &weatherResponse, nil
+}
+
+// GetForecast returns the daily forecast for q, starting today, along with
+// the location WeatherAPI resolved it to.
+func (c *Client) GetForecast(ctx context.Context, q Query, days int) (*ForecastResponse, error) {
+
+	var forecastResponse ForecastResponse
+
+	err := c.get(ctx, "forecast.json", q, fmt.Sprintf("days=%d&aqi=no&alerts=no", days), &forecastResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &forecastResponse, nil
+}
+
+// GetHistory returns the observed weather for q on the local date day.
+// WeatherAPI's history.json answers in the same shape as forecast.json, with
+// a single forecast day.
+func (c *Client) GetHistory(ctx context.Context, q Query, day time.Time) (*ForecastResponse, error) {
+
+	var historyResponse ForecastResponse
+
+	err := c.get(ctx, "history.json", q, "dt="+day.Format(time.DateOnly), &historyResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(historyResponse.Forecast.ForecastDay) == 0 {
+		return nil, fmt.Errorf("no history for %v on %v", q, day.Format(time.DateOnly))
+	}
+
+	return &historyResponse, nil
+}
+
+// get queries endpoint for q, appending the extra URL parameters, and decodes
+// the JSON response into out.
+func (c *Client) get(ctx context.Context, endpoint string, q Query, extra string, out any) error {
+
+	city := q.String()
+	encodedCity := url.QueryEscape(city)
+
+	if q.Lang != "" {
+		extra += "&lang=" + url.QueryEscape(q.Lang)
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s&q=%s&%s",
+		c.BaseURL,
+		endpoint,
+		c.APIKey,
+		encodedCity,
+		extra)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Println("error to build request")
-		return 0, fmt.Errorf("internal error")
+		return fmt.Errorf("internal error")
 	}
 
-	resp, err := client.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("error to do request to get city:%v - error:%v\n", city, err)
+		return fmt.Errorf("error to do request to get city:%v - error:%v\n", city, err)
 	}
 
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return 0, fmt.Errorf("error reading response body: %v\n", err)
+		return fmt.Errorf("error reading response body: %v\n", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("response expected 200 but got %v\n", resp.StatusCode)
+		return fmt.Errorf("response expected 200 but got %v\n", resp.StatusCode)
 	}
 
-	var weatherResponse WeatherResponse
-
-	err = json.Unmarshal(body, &weatherResponse)
+	err = json.Unmarshal(body, out)
 	if err != nil {
 		log.Println("Error unmarshalling JSON:", err)
-		return 0, fmt.Errorf("error decode json")
+		return fmt.Errorf("error decode json")
 	}
 
-	return weatherResponse.Current.FeelsLikeC, nil
+	return nil
 }
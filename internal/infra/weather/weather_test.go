@@ -1,50 +1,311 @@
 package weather_test
 
 import (
-	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
-	"log"
-	"os"
-	"strings"
+	"context"
+	"net/http"
 	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather/weathertest"
 )
 
 func TestWeatherGet(t *testing.T) {
 
-	// Lê o conteúdo do arquivo .env
-	b, err := os.ReadFile("../../../.env")
-	if err != nil {
-		t.Errorf("file .env not found")
+	srv := weathertest.NewServer()
+	defer srv.Close()
+
+	srv.SetAPIKey("secret")
+	srv.AddCity("São Paulo", 28.5)
+	srv.SetResponse("Quebrada", weathertest.Response{Status: http.StatusInternalServerError, Body: "boom"})
+	srv.SetResponse("Torta", weathertest.Response{Status: http.StatusOK, Body: "{not json"})
+	srv.SetResponse("Lenta", weathertest.Response{Status: http.StatusOK, Body: `{"current":{"feelslike_c":1}}`, Latency: 200 * time.Millisecond})
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		city    string
+		want    float64
+		wantErr bool
+	}{
+		{name: "found", apiKey: "secret", city: "São Paulo", want: 28.5},
+		{name: "invalid api key", apiKey: "wrong", city: "São Paulo", wantErr: true},
+		{name: "unknown city", apiKey: "secret", city: "Atlantida", wantErr: true},
+		{name: "upstream failure", apiKey: "secret", city: "Quebrada", wantErr: true},
+		{name: "malformed body", apiKey: "secret", city: "Torta", wantErr: true},
+		{name: "timeout", apiKey: "secret", city: "Lenta", wantErr: true},
 	}
 
-	env := string(b)
-	log.Println("env:", env)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
 
-	// Remove o caractere % do final da linha, se existir
-	env = strings.TrimRight(env, "%\n")
+			c := weather.NewClient(srv.URL, tt.apiKey)
+			c.HTTPClient.Timeout = 50 * time.Millisecond
 
-	// Divide a string em chave e valor
-	list := strings.Split(env, "=")
-	if list[0] == "WEATHER_API_KEY" {
+			got, err := c.GetWeather(tt.city)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
 
-		secret := strings.Trim(list[1], "\"")
-		err = os.Setenv(list[0], secret)
-		if err != nil {
-			log.Println("error to set env:", err)
+			if got != tt.want {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQueryString(t *testing.T) {
+
+	tests := []struct {
+		q    weather.Query
+		want string
+	}{
+		{q: weather.Query{City: "Linhares"}, want: "Linhares"},
+		{q: weather.Query{City: "Bom Jesus", Region: "Piauí", Country: "Brazil"}, want: "Bom Jesus, Piauí, Brazil"},
+		{q: weather.Query{City: "Bom Jesus", Country: "Brazil"}, want: "Bom Jesus, Brazil"},
+		{q: weather.Query{City: "Bom Jesus", Lat: -9.07, Lon: -44.36}, want: "-9.07,-44.36"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.q.String(); got != tt.want {
+			t.Errorf("expected %q but got %q", tt.want, got)
 		}
 	}
+}
+
+func TestGetCurrent(t *testing.T) {
+
+	srv := weathertest.NewServer()
+	defer srv.Close()
+
+	srv.AddLocation(weather.WeatherLocation{Name: "Bom Jesus", Region: "Rio Grande do Sul", Country: "Brazil"}, weather.CurrentWeather{FeelsLikeC: 10})
+	srv.AddLocation(weather.WeatherLocation{Name: "Bom Jesus", Region: "Piaui", Country: "Brazil", Lat: -9.07, Lon: -44.36}, weather.CurrentWeather{FeelsLikeC: 35})
+
+	tests := []struct {
+		name       string
+		q          weather.Query
+		wantRegion string
+	}{
+		{name: "bare city is ambiguous", q: weather.Query{City: "Bom Jesus"}, wantRegion: "Rio Grande do Sul"},
+		{name: "qualified by state", q: weather.Query{City: "Bom Jesus", Region: "Piauí", Country: "Brazil"}, wantRegion: "Piaui"},
+		{name: "by coordinates", q: weather.Query{Lat: -9.07, Lon: -44.36}, wantRegion: "Piaui"},
+	}
 
-	checkApiToken := os.Getenv("WEATHER_API_KEY")
-	log.Println("checkApiToken:", checkApiToken)
-	if checkApiToken == "" {
-		t.Error("error to load weather api key, check end of line in .env with cat if has %")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := weather.NewClient(srv.URL, "").GetCurrent(tt.q)
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+			if got.Location.Region != tt.wantRegion {
+				t.Errorf("expected region %q but got %q", tt.wantRegion, got.Location.Region)
+			}
+		})
 	}
+}
 
-	wc, err := weather.GetWeather("São Paulo")
+func TestGetCurrentDecodesConditions(t *testing.T) {
+
+	srv := weathertest.NewServer()
+	defer srv.Close()
+
+	srv.SetResponse("Linhares", weathertest.Response{Status: http.StatusOK, Body: `{
+		"location": {"name": "Linhares", "region": "Espirito Santo", "country": "Brazil", "lat": -19.39, "lon": -40.07},
+		"current": {
+			"last_updated_epoch": 1718200800,
+			"temp_c": 27.1, "feelslike_c": 29.4, "humidity": 70,
+			"wind_kph": 13.0, "wind_degree": 90, "wind_dir": "E",
+			"pressure_mb": 1015.0, "uv": 7.0,
+			"condition": {"text": "Partly cloudy", "icon": "//cdn.weatherapi.com/116.png", "code": 1003}
+		}
+	}`})
+
+	got, err := weather.NewClient(srv.URL, "").GetCurrent(weather.Query{City: "Linhares"})
 	if err != nil {
-		t.Error("error to get weather")
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	want := weather.CurrentWeather{
+		LastUpdatedEpoch: 1718200800,
+		TempC:            27.1,
+		FeelsLikeC:       29.4,
+		Humidity:         70,
+		WindKph:          13,
+		WindDegree:       90,
+		WindDir:          "E",
+		PressureMb:       1015,
+		UV:               7,
+		Condition:        weather.Condition{Text: "Partly cloudy", Icon: "//cdn.weatherapi.com/116.png", Code: 1003},
+	}
+	if got.Current != want {
+		t.Errorf("expected %+v but got %+v", want, got.Current)
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		baseURL    string
+		apiKey     string
+		envBaseURL string
+		envAPIKey  string
+		wantURL    string
+		wantKey    string
+	}{
+		{name: "default", wantURL: weather.DefaultBaseURL},
+		{name: "from env", envBaseURL: "http://fake-weather/v1/", envAPIKey: "env-key", wantURL: "http://fake-weather/v1", wantKey: "env-key"},
+		{name: "explicit wins", baseURL: "http://explicit", apiKey: "key", envBaseURL: "http://env", envAPIKey: "env-key", wantURL: "http://explicit", wantKey: "key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			t.Setenv("WEATHER_API_BASE_URL", tt.envBaseURL)
+			t.Setenv("WEATHER_API_KEY", tt.envAPIKey)
+
+			c := weather.NewClient(tt.baseURL, tt.apiKey)
+			if c.BaseURL != tt.wantURL {
+				t.Errorf("expected base url %q but got %q", tt.wantURL, c.BaseURL)
+			}
+			if c.APIKey != tt.wantKey {
+				t.Errorf("expected api key %q but got %q", tt.wantKey, c.APIKey)
+			}
+		})
 	}
+}
+
+func TestGetForecast(t *testing.T) {
+
+	srv := weathertest.NewServer()
+	defer srv.Close()
 
-	if wc < 1 {
-		t.Error("sorry but something wrong with São Paulo")
+	srv.AddCity("Linhares", 25)
+	srv.SetForecast("Linhares", []weather.ForecastDay{
+		{Date: "2024-06-12", Day: weather.DayStats{MinTempC: 20, MaxTempC: 30, AvgTempC: 25, DailyChanceOfRain: 80}},
+		{Date: "2024-06-13", Day: weather.DayStats{MinTempC: 18, MaxTempC: 28, AvgTempC: 23}},
+	})
+
+	got, err := weather.NewClient(srv.URL, "").GetForecast(context.Background(), weather.Query{City: "Linhares"}, 1)
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	days := got.Forecast.ForecastDay
+	if got.Location.Name != "Linhares" || len(days) != 1 {
+		t.Fatalf("expected 1 day for Linhares but got %+v", got)
+	}
+	if days[0].Date != "2024-06-12" || days[0].Day.MaxTempC != 30 || days[0].Day.DailyChanceOfRain != 80 {
+		t.Errorf("unexpected forecast day %+v", days[0])
+	}
+
+	_, err = weather.NewClient(srv.URL, "").GetForecast(context.Background(), weather.Query{City: "Atlantida"}, 1)
+	if err == nil {
+		t.Errorf("expected error for unknown city")
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+
+	srv := weathertest.NewServer()
+	defer srv.Close()
+
+	srv.AddCity("Linhares", 25)
+	srv.AddHistory("Linhares", weather.ForecastDay{Date: "2024-06-12", Day: weather.DayStats{MaxTempC: 31, TotalPrecipMm: 12.5}})
+
+	c := weather.NewClient(srv.URL, "")
+
+	got, err := c.GetHistory(context.Background(), weather.Query{City: "Linhares"}, time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	day := got.Forecast.ForecastDay[0]
+	if day.Date != "2024-06-12" || day.Day.MaxTempC != 31 || day.Day.TotalPrecipMm != 12.5 {
+		t.Errorf("unexpected history day %+v", day)
+	}
+
+	_, err = c.GetHistory(context.Background(), weather.Query{City: "Atlantida"}, time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC))
+	if err == nil {
+		t.Errorf("expected error for unknown city")
+	}
+}
+
+func TestGetConditions(t *testing.T) {
+
+	srv := weathertest.NewServer()
+	defer srv.Close()
+
+	srv.AddCity("Linhares", 25)
+	srv.SetAirQuality("Linhares", weather.AirQuality{PM25: 12.5, PM10: 20.1, O3: 48, USEPAIndex: 2})
+	srv.AddAlert("Linhares", weather.Alert{Event: "Tempestade", Severity: "Severe", Effective: "2024-06-12T10:00:00-03:00"})
+
+	tests := []struct {
+		name           string
+		inc            weather.Include
+		wantAirQuality bool
+		wantAlerts     int
+	}{
+		{name: "none", inc: weather.Include{}},
+		{name: "air quality", inc: weather.Include{AirQuality: true}, wantAirQuality: true},
+		{name: "alerts", inc: weather.Include{Alerts: true}, wantAlerts: 1},
+		{name: "both", inc: weather.Include{AirQuality: true, Alerts: true}, wantAirQuality: true, wantAlerts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := weather.NewClient(srv.URL, "").GetConditions(context.Background(), weather.Query{City: "Linhares"}, tt.inc)
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+
+			if (got.Current.AirQuality != nil) != tt.wantAirQuality {
+				t.Errorf("expected air quality %v but got %+v", tt.wantAirQuality, got.Current.AirQuality)
+			}
+			if tt.wantAirQuality && got.Current.AirQuality.USEPAIndex != 2 {
+				t.Errorf("expected us-epa-index 2 but got %+v", got.Current.AirQuality)
+			}
+			if len(got.Alerts.Alert) != tt.wantAlerts {
+				t.Errorf("expected %d alerts but got %+v", tt.wantAlerts, got.Alerts.Alert)
+			}
+			if got.Current.FeelsLikeC != 25 {
+				t.Errorf("expected current conditions to be returned but got %+v", got.Current)
+			}
+		})
+	}
+}
+
+func TestGetConditionsLang(t *testing.T) {
+
+	srv := weathertest.NewServer()
+	defer srv.Close()
+
+	srv.AddLocation(
+		weather.WeatherLocation{Name: "Linhares", Country: "Brazil"},
+		weather.CurrentWeather{FeelsLikeC: 25, Condition: weather.Condition{Text: "Partly cloudy", Code: 1003}},
+	)
+	srv.AddTranslation("pt", 1003, "Parcialmente nublado")
+
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{lang: "", want: "Partly cloudy"},
+		{lang: "pt", want: "Parcialmente nublado"},
+		{lang: "es", want: "Partly cloudy"},
+	}
+
+	for _, tt := range tests {
+
+		got, err := weather.NewClient(srv.URL, "").GetConditions(context.Background(), weather.Query{City: "Linhares", Lang: tt.lang}, weather.Include{})
+		if err != nil {
+			t.Fatalf("expected error to be nil and got %v", err)
+		}
+
+		if got.Current.Condition.Text != tt.want {
+			t.Errorf("lang %q: expected condition %q but got %q", tt.lang, tt.want, got.Current.Condition.Text)
+		}
 	}
 }
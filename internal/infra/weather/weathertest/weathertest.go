@@ -0,0 +1,435 @@
+// Package weathertest provides a scriptable fake WeatherAPI server for tests.
+package weathertest
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Response is a canned reply served for a single query.
+type Response struct {
+	Status  int
+	Body    string
+	Latency time.Duration
+}
+
+// Server is an httptest.Server answering GET /current.json,
+// GET /forecast.json and GET /history.json like WeatherAPI does. Queries are first matched against
+// canned responses, which both endpoints share, then resolved against the
+// registered places ("city[, region][, country]" or "lat,lon"); anything else
+// gets WeatherAPI's "No matching location found." error.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	apiKey    string
+	responses map[string]Response
+	places    []place
+	latency   time.Duration
+	hits      map[string]int
+
+	// translations holds condition texts by lang and condition code.
+	translations map[string]map[int]string
+}
+
+type place struct {
+	location weather.WeatherLocation
+	current  weather.CurrentWeather
+	forecast []weather.ForecastDay
+	history  map[string]weather.ForecastDay
+	alerts   []weather.Alert
+}
+
+// NewServer starts a fake WeatherAPI server. Callers must Close it.
+func NewServer() *Server {
+
+	s := &Server{
+		responses:    make(map[string]Response),
+		hits:         make(map[string]int),
+		translations: make(map[string]map[int]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /current.json", s.handleCurrent)
+	mux.HandleFunc("GET /forecast.json", s.handleForecast)
+	mux.HandleFunc("GET /history.json", s.handleHistory)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// AddCity registers a Brazilian city with no region and the given
+// feels-like temperature in Celsius.
+func (s *Server) AddCity(name string, feelsLikeC float64) {
+	s.AddLocation(
+		weather.WeatherLocation{Name: name, Country: "Brazil"},
+		weather.CurrentWeather{FeelsLikeC: feelsLikeC},
+	)
+}
+
+// AddLocation registers a place queries can resolve to. When several places
+// match a query the first registered one wins, like an ambiguous search on
+// WeatherAPI.
+func (s *Server) AddLocation(location weather.WeatherLocation, current weather.CurrentWeather) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.places = append(s.places, place{location: location, current: current})
+}
+
+// SetForecast sets the days forecast for the registered places called name.
+// Places without a forecast get one synthesized from their current
+// conditions.
+func (s *Server) SetForecast(name string, days []weather.ForecastDay) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.places {
+		if normalize(s.places[i].location.Name) == normalize(name) {
+			s.places[i].forecast = days
+		}
+	}
+}
+
+// AddHistory records the observed weather of the registered places called
+// name on day.Date. Other dates get a day synthesized from their current
+// conditions.
+func (s *Server) AddHistory(name string, day weather.ForecastDay) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.places {
+		if normalize(s.places[i].location.Name) != normalize(name) {
+			continue
+		}
+		if s.places[i].history == nil {
+			s.places[i].history = make(map[string]weather.ForecastDay)
+		}
+		s.places[i].history[day.Date] = day
+	}
+}
+
+// SetCurrent replaces the current conditions of the registered places called
+// name, as if the provider refreshed its reading.
+func (s *Server) SetCurrent(name string, current weather.CurrentWeather) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.places {
+		if normalize(s.places[i].location.Name) == normalize(name) {
+			s.places[i].current = current
+		}
+	}
+}
+
+// SetAirQuality sets the air quality of the registered places called name,
+// served when a request asks for aqi=yes.
+func (s *Server) SetAirQuality(name string, aq weather.AirQuality) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.places {
+		if normalize(s.places[i].location.Name) == normalize(name) {
+			s.places[i].current.AirQuality = &aq
+		}
+	}
+}
+
+// AddAlert adds an active alert to the registered places called name, served
+// by forecast.json when a request asks for alerts=yes.
+func (s *Server) AddAlert(name string, alert weather.Alert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.places {
+		if normalize(s.places[i].location.Name) == normalize(name) {
+			s.places[i].alerts = append(s.places[i].alerts, alert)
+		}
+	}
+}
+
+// AddTranslation makes requests with lang=lang get text for condition code,
+// in the current conditions and in forecast days.
+func (s *Server) AddTranslation(lang string, code int, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.translations[lang] == nil {
+		s.translations[lang] = make(map[int]string)
+	}
+	s.translations[lang][code] = text
+}
+
+// SetResponse scripts the exact reply for q.
+func (s *Server) SetResponse(q string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[q] = r
+}
+
+// SetAPIKey makes the server reject requests not carrying key.
+func (s *Server) SetAPIKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = key
+}
+
+// SetLatency delays every reply by d, on top of any per-response latency.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Hits reports how many requests were received for q.
+func (s *Server) Hits(q string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[q]
+}
+
+func (s *Server) handleCurrent(w http.ResponseWriter, r *http.Request) {
+	aqi := r.URL.Query().Get("aqi") == "yes"
+
+	s.handle(w, r, func(p place) any {
+		return weather.WeatherResponse{Location: p.location, Current: current(p, aqi)}
+	})
+}
+
+func (s *Server) handleForecast(w http.ResponseWriter, r *http.Request) {
+
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 {
+		days = 1
+	}
+
+	aqi := r.URL.Query().Get("aqi") == "yes"
+	alerts := r.URL.Query().Get("alerts") == "yes"
+
+	s.handle(w, r, func(p place) any {
+
+		resp := weather.ForecastResponse{
+			Location: p.location,
+			Current:  current(p, aqi),
+			Forecast: weather.Forecast{ForecastDay: forecastDays(p, days)},
+		}
+		if alerts {
+			resp.Alerts.Alert = append([]weather.Alert{}, p.alerts...)
+		}
+
+		return resp
+	})
+}
+
+// current returns the current conditions of p, with its air quality only
+// when aqi is set.
+func current(p place, aqi bool) weather.CurrentWeather {
+
+	c := p.current
+	if !aqi {
+		c.AirQuality = nil
+	}
+
+	return c
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+
+	date, err := time.Parse(time.DateOnly, r.URL.Query().Get("dt"))
+	if err != nil {
+		resp := apiError(http.StatusBadRequest, 1011, "Invalid date.")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.Status)
+		_, _ = w.Write([]byte(resp.Body))
+		return
+	}
+
+	s.handle(w, r, func(p place) any {
+
+		day, ok := p.history[date.Format(time.DateOnly)]
+		if !ok {
+			day = synthesizeDay(p, date)
+		}
+
+		return weather.ForecastResponse{
+			Location: p.location,
+			Forecast: weather.Forecast{ForecastDay: []weather.ForecastDay{day}},
+		}
+	})
+}
+
+// forecastDays returns up to days days of the forecast of p, synthesizing
+// them from its current conditions when none was set.
+func forecastDays(p place, days int) []weather.ForecastDay {
+
+	if p.forecast != nil {
+		return p.forecast[:min(days, len(p.forecast))]
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	out := make([]weather.ForecastDay, days)
+	for i := range out {
+		out[i] = synthesizeDay(p, today.AddDate(0, 0, i))
+	}
+
+	return out
+}
+
+// synthesizeDay makes up the weather of p on date from its current
+// conditions.
+func synthesizeDay(p place, date time.Time) weather.ForecastDay {
+	return weather.ForecastDay{
+		Date:      date.Format(time.DateOnly),
+		DateEpoch: date.Unix(),
+		Day: weather.DayStats{
+			MinTempC:  p.current.FeelsLikeC - 5,
+			MaxTempC:  p.current.FeelsLikeC + 5,
+			AvgTempC:  p.current.FeelsLikeC,
+			Condition: p.current.Condition,
+		},
+	}
+}
+
+// handle answers a WeatherAPI request for q, rendering the matching place
+// with body.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, body func(place) any) {
+
+	q := r.URL.Query().Get("q")
+
+	s.mu.Lock()
+	s.hits[q]++
+	resp, ok := s.responses[q]
+	if !ok {
+		var p place
+		p, ok = s.resolve(q)
+		if ok {
+			p = s.translate(p, r.URL.Query().Get("lang"))
+			b, _ := json.Marshal(body(p))
+			resp = Response{Status: http.StatusOK, Body: string(b)}
+		}
+	}
+	latency := s.latency + resp.Latency
+	apiKey := s.apiKey
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case apiKey != "" && r.URL.Query().Get("key") != apiKey:
+		resp = apiError(http.StatusUnauthorized, 2006, "API key is invalid.")
+	case q == "":
+		resp = apiError(http.StatusBadRequest, 1003, "Parameter q is missing.")
+	case !ok:
+		resp = apiError(http.StatusBadRequest, 1006, "No matching location found.")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write([]byte(resp.Body))
+}
+
+// translate returns a copy of p with its condition texts in lang. Callers
+// must hold s.mu.
+func (s *Server) translate(p place, lang string) place {
+
+	texts := s.translations[lang]
+	if texts == nil {
+		return p
+	}
+
+	translated := func(c weather.Condition) weather.Condition {
+		if text, ok := texts[c.Code]; ok {
+			c.Text = text
+		}
+		return c
+	}
+
+	p.current.Condition = translated(p.current.Condition)
+
+	forecast := make([]weather.ForecastDay, len(p.forecast))
+	for i, d := range p.forecast {
+		d.Day.Condition = translated(d.Day.Condition)
+		forecast[i] = d
+	}
+	if p.forecast != nil {
+		p.forecast = forecast
+	}
+
+	return p
+}
+
+// resolve finds the registered place matching q. Callers must hold s.mu.
+func (s *Server) resolve(q string) (place, bool) {
+
+	for _, p := range s.places {
+		if matches(p.location, q) {
+			return p, true
+		}
+	}
+
+	return place{}, false
+}
+
+func matches(l weather.WeatherLocation, q string) bool {
+
+	parts := strings.Split(q, ",")
+	for i := range parts {
+		parts[i] = normalize(parts[i])
+	}
+
+	if len(parts) == 2 {
+		lat, errLat := strconv.ParseFloat(parts[0], 64)
+		lon, errLon := strconv.ParseFloat(parts[1], 64)
+		if errLat == nil && errLon == nil {
+			return math.Abs(lat-l.Lat) < 0.01 && math.Abs(lon-l.Lon) < 0.01
+		}
+	}
+
+	if parts[0] != normalize(l.Name) {
+		return false
+	}
+
+	// Every qualifier must name the place's region or country; places
+	// registered without a region accept any region.
+	for _, qualifier := range parts[1:] {
+		switch qualifier {
+		case normalize(l.Country):
+		case normalize(l.Region):
+		default:
+			if l.Region != "" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// normalize lowercases s and strips its accents, as WeatherAPI matches
+// "Sao Paulo" and "São Paulo" alike.
+func normalize(s string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, _ := transform.String(stripAccents, strings.ToLower(strings.TrimSpace(s)))
+	return out
+}
+
+func apiError(status, code int, message string) Response {
+
+	body, _ := json.Marshal(map[string]any{
+		"error": map[string]any{"code": code, "message": message},
+	})
+
+	return Response{Status: status, Body: string(body)}
+}
@@ -0,0 +1,75 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBatchLookup(t *testing.T) {
+
+	st := newStack(t)
+	st.cep.AddCity("29902555", "Linhares")
+	st.cep.AddCity("01308080", "São Paulo")
+	st.weather.AddCity("Linhares", 25)
+	st.weather.AddCity("São Paulo", 20)
+
+	body := `{"ceps": ["29902555", "01308080", "29902555", "123", "12345678"]}`
+
+	status, data := st.do(t, http.MethodPost, "/v1/weather:batch?units=c", body)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %v", status, data)
+	}
+
+	results, _ := data["results"].([]any)
+
+	tests := []struct {
+		cep     string
+		status  float64
+		city    string
+		message string
+	}{
+		{cep: "29902555", status: 200, city: "Linhares"},
+		{cep: "01308080", status: 200, city: "São Paulo"},
+		{cep: "29902555", status: 200, city: "Linhares"},
+		{cep: "123", status: 422, message: "invalid zipcode"},
+		{cep: "12345678", status: 404, message: "can not find zipcode"},
+	}
+
+	if len(results) != len(tests) {
+		t.Fatalf("expected %d results but got %d", len(tests), len(results))
+	}
+
+	for i, tt := range tests {
+		item := results[i].(map[string]any)
+		if item["cep"] != tt.cep || item["status"] != tt.status {
+			t.Errorf("result %d: expected %s/%v but got %v", i, tt.cep, tt.status, item)
+		}
+		if tt.city != "" {
+			location, _ := item["location"].(map[string]any)
+			if location["city"] != tt.city {
+				t.Errorf("result %d: expected city %q but got %v", i, tt.city, location)
+			}
+			if _, ok := location["temp_f"]; ok {
+				t.Errorf("result %d: units were not applied: %v", i, location)
+			}
+		}
+		if msg, _ := item["message"].(string); msg != tt.message {
+			t.Errorf("result %d: expected message %q but got %q", i, tt.message, msg)
+		}
+	}
+
+	items := 0
+	for _, s := range st.spans.Ended() {
+		if s.Name() == "service_b-handler-execute-batch-item" {
+			items++
+		}
+	}
+	if items != 4 {
+		t.Errorf("expected one span per distinct cep (4) but got %d", items)
+	}
+
+	status, _ = st.do(t, http.MethodPost, "/v1/weather:batch", `{"ceps": []}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected empty batch to be rejected with 400 but got %d", status)
+	}
+}
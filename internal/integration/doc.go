@@ -0,0 +1,4 @@
+// Package integration holds end-to-end tests that run service-a and
+// service-b in-process against fake upstreams. The tests are split by
+// feature, all built on the stack of stack_test.go.
+package integration
@@ -0,0 +1,133 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+)
+
+func TestForecast(t *testing.T) {
+
+	st := newLinharesStack(t)
+	st.weather.SetForecast("Linhares", []weather.ForecastDay{
+		{Date: "2024-06-12", Day: weather.DayStats{MinTempC: 20, MaxTempC: 30, AvgTempC: 25, DailyChanceOfRain: 80, Condition: weather.Condition{Text: "Patchy rain nearby"}}},
+		{Date: "2024-06-13", Day: weather.DayStats{MinTempC: 18, MaxTempC: 28, AvgTempC: 23, DailyChanceOfRain: 10, Condition: weather.Condition{Text: "Sunny"}}},
+		{Date: "2024-06-14", Day: weather.DayStats{MinTempC: 19, MaxTempC: 29, AvgTempC: 24}},
+	})
+
+	status, data := st.do(t, http.MethodGet, "/v1/forecast/29902555?days=2&units=c,f", "")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %v", status, data)
+	}
+
+	days, _ := data["days"].([]any)
+	if data["city"] != "Linhares" || len(days) != 2 {
+		t.Fatalf("expected 2 days for Linhares but got %v", data)
+	}
+
+	first := days[0].(map[string]any)
+	want := map[string]any{"date": "2024-06-12", "min_c": 20.0, "max_f": 86.0, "avg_c": 25.0, "chance_of_rain": 80.0, "condition": "Patchy rain nearby"}
+	for k, v := range want {
+		if first[k] != v {
+			t.Errorf("expected %s=%v but got %v", k, v, first[k])
+		}
+	}
+	if _, ok := first["min_k"]; ok {
+		t.Errorf("units were not applied: %v", first)
+	}
+
+	assertSpanChain(t, st.spans.Ended(), []string{
+		"check-cep",
+		"service_b-handler: check cep and forecast",
+		"service_b-handler-forecast",
+		"service_b-handler-forecast-city",
+		"service_b-handler-forecast-weather",
+	})
+
+	// The second lookup is served from the cache.
+	status, _ = st.do(t, http.MethodGet, "/v1/forecast/29902555?days=2", "")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", status)
+	}
+	var cached bool
+	for _, s := range st.spans.Ended() {
+		if s.Name() != "service_b-handler-forecast" {
+			continue
+		}
+		for _, kv := range s.Attributes() {
+			if kv.Key == "forecast.cache" && kv.Value.AsString() == "hit" {
+				cached = true
+			}
+		}
+	}
+	if !cached {
+		t.Errorf("expected the second forecast to be served from the cache")
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/v1/forecast/29902555?days=0", wantStatus: http.StatusBadRequest},
+		{path: "/v1/forecast/29902555?days=15", wantStatus: http.StatusBadRequest},
+		{path: "/v1/forecast/123", wantStatus: http.StatusUnprocessableEntity},
+		{path: "/v1/forecast/12345678", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		status, data := st.do(t, http.MethodGet, tt.path, "")
+		if status != tt.wantStatus {
+			t.Errorf("%s: expected status %d but got %d: %v", tt.path, tt.wantStatus, status, data)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+
+	st := newLinharesStack(t)
+	st.weather.AddHistory("Linhares", weather.ForecastDay{
+		Date: "2024-06-12",
+		Day:  weather.DayStats{MinTempC: 19, MaxTempC: 31, AvgTempC: 24, TotalPrecipMm: 12.5, Condition: weather.Condition{Text: "Moderate rain"}},
+	})
+
+	status, data := st.do(t, http.MethodGet, "/v1/weather/29902555/history?date=2024-06-12&units=c", "")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %v", status, data)
+	}
+
+	want := map[string]any{"cep": "29902555", "city": "Linhares", "date": "2024-06-12", "min_c": 19.0, "max_c": 31.0, "total_precip_mm": 12.5, "condition": "Moderate rain"}
+	for k, v := range want {
+		if data[k] != v {
+			t.Errorf("expected %s=%v but got %v", k, v, data[k])
+		}
+	}
+	if _, ok := data["max_f"]; ok {
+		t.Errorf("units were not applied: %v", data)
+	}
+
+	assertSpanChain(t, st.spans.Ended(), []string{
+		"check-cep",
+		"service_b-handler: check cep and history",
+		"service_b-handler-history",
+		"service_b-handler-history-city",
+		"service_b-handler-history-weather",
+	})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/v1/weather/29902555/history", wantStatus: http.StatusBadRequest},
+		{path: "/v1/weather/29902555/history?date=2999-01-01", wantStatus: http.StatusBadRequest},
+		{path: "/v1/weather/123/history?date=2024-06-12", wantStatus: http.StatusUnprocessableEntity},
+		{path: "/v1/weather/12345678/history?date=2024-06-12", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		status, data := st.do(t, http.MethodGet, tt.path, "")
+		if status != tt.wantStatus {
+			t.Errorf("%s: expected status %d but got %d: %v", tt.path, tt.wantStatus, status, data)
+		}
+	}
+}
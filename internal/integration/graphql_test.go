@@ -0,0 +1,192 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// graphQL posts query with variables to /graphql on service-a.
+func (st *stack) graphQL(t *testing.T, query string, variables map[string]any) (int, map[string]any) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+	return st.do(t, http.MethodPost, "/graphql", string(body))
+}
+
+func TestGraphQL(t *testing.T) {
+
+	st := newStack(t)
+	st.cep.AddCity("29902555", "Linhares")
+	st.cep.AddCity("01308080", "São Paulo")
+	st.weather.AddCity("Linhares", 25)
+	st.weather.AddCity("São Paulo", 20)
+	st.weather.SetForecast("Linhares", []weather.ForecastDay{
+		{Date: "2024-06-12", Day: weather.DayStats{MinTempC: 20, MaxTempC: 30, AvgTempC: 25}},
+		{Date: "2024-06-13", Day: weather.DayStats{MinTempC: 18, MaxTempC: 28, AvgTempC: 23}},
+	})
+	st.weather.SetAirQuality("Linhares", weather.AirQuality{PM25: 12.5, PM10: 20, O3: 40, USEPAIndex: 2})
+
+	query := `query Lookup($ceps: [String!]!) {
+		linhares: location(cep: "29902555") {
+			...place tempK forecast(days: 2) { days { date maxC } }
+			airQuality { pm2_5 pm10 o3 usEpaIndex category }
+		}
+		saoPaulo: location(cep: "01308080") { city address { city } }
+		many: locations(ceps: $ceps) { cep city }
+	}
+	fragment place on Location { city tempC }`
+
+	status, data := st.graphQL(t, query, map[string]any{"ceps": []string{"29902555", "123", "12345678"}})
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %v", status, data)
+	}
+
+	result, _ := data["data"].(map[string]any)
+	linhares, _ := result["linhares"].(map[string]any)
+	if linhares["city"] != "Linhares" || linhares["tempC"] != 25.0 || linhares["tempK"] != 298.15 {
+		t.Errorf("expected Linhares at 25C but got %v", linhares)
+	}
+	forecast, _ := linhares["forecast"].(map[string]any)
+	if days, _ := forecast["days"].([]any); len(days) != 2 || days[0].(map[string]any)["maxC"] != 30.0 {
+		t.Errorf("expected 2 forecast days but got %v", forecast)
+	}
+	airQuality, _ := linhares["airQuality"].(map[string]any)
+	wantAirQuality := map[string]any{"pm2_5": 12.5, "pm10": 20.0, "o3": 40.0, "usEpaIndex": 2.0, "category": "Moderate"}
+	if !reflect.DeepEqual(airQuality, wantAirQuality) {
+		t.Errorf("expected air quality %v but got %v", wantAirQuality, airQuality)
+	}
+	saoPaulo, _ := result["saoPaulo"].(map[string]any)
+	if address, _ := saoPaulo["address"].(map[string]any); address["city"] != "São Paulo" {
+		t.Errorf("expected the address of São Paulo but got %v", saoPaulo)
+	}
+	if _, ok := saoPaulo["tempC"]; ok {
+		t.Errorf("expected only the selected fields but got %v", saoPaulo)
+	}
+
+	many, _ := result["many"].([]any)
+	if len(many) != 3 || many[0].(map[string]any)["city"] != "Linhares" || many[1] != nil || many[2] != nil {
+		t.Errorf("expected Linhares and two failures but got %v", many)
+	}
+
+	errs, _ := data["errors"].([]any)
+	wantErrors := map[string]string{"many.1": "invalid_zipcode", "many.2": "zipcode_not_found"}
+	if len(errs) != len(wantErrors) {
+		t.Fatalf("expected %d errors but got %v", len(wantErrors), errs)
+	}
+	for _, e := range errs {
+		e := e.(map[string]any)
+		var path []string
+		for _, p := range e["path"].([]any) {
+			path = append(path, fmt.Sprint(p))
+		}
+		extensions, _ := e["extensions"].(map[string]any)
+		if code := wantErrors[strings.Join(path, ".")]; code == "" || extensions["code"] != code {
+			t.Errorf("unexpected error %v", e)
+		}
+	}
+
+	// Every CEP of the query is looked up with a single batch call.
+	counts := make(map[string]int)
+	for _, s := range st.spans.Ended() {
+		counts[s.Name()]++
+	}
+	tests := map[string]int{
+		"graphql-batch": 1,
+		"service_b-handler: batch check cep and weather": 1,
+		"graphql-resolve Query.location":                 2,
+		"graphql-resolve Query.locations":                1,
+		"graphql-resolve Location.forecast":              1,
+		"graphql-resolve Location.airQuality":            1,
+		"graphql-resolve Location.address":               1,
+	}
+	for name, want := range tests {
+		if counts[name] != want {
+			t.Errorf("expected %d %q spans but got %d", want, name, counts[name])
+		}
+	}
+
+	// The span of a field descends from the span of its location.
+	byID := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range st.spans.Ended() {
+		byID[s.SpanContext().SpanID().String()] = s
+	}
+	for _, s := range st.spans.Ended() {
+		if !strings.HasPrefix(s.Name(), "graphql-resolve Location.") {
+			continue
+		}
+		parent, ok := byID[s.Parent().SpanID().String()]
+		if !ok || parent.Name() != "graphql-resolve Query.location" {
+			t.Errorf("expected the %q span to descend from its location span", s.Name())
+		}
+	}
+}
+
+func TestGraphQLLimitsAndErrors(t *testing.T) {
+
+	t.Setenv("API_KEYS", "lookup-only "+apikey.Hash("lookup-key")+" lookup")
+	t.Setenv("GRAPHQL_MAX_DEPTH", "3")
+	t.Setenv("GRAPHQL_MAX_COMPLEXITY", "20")
+
+	st := newStack(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "too deep", body: `{"query": "{ location(cep: \"29902555\") { forecast { days { date } } } }"}`, wantStatus: http.StatusBadRequest, wantCode: "query_too_deep"},
+		{name: "too complex", body: `{"query": "query($ceps: [String!]!) { locations(ceps: $ceps) { cep city tempC } }", "variables": {"ceps": ["1", "2", "3", "4", "5", "6", "7"]}}`, wantStatus: http.StatusBadRequest, wantCode: "query_too_complex"},
+		{name: "introspection is free", body: `{"query": "{ __schema { types { name fields { name type { name ofType { name } } } } } }"}`, wantStatus: http.StatusOK},
+		{name: "invalid field", body: `{"query": "{ location(cep: \"29902555\") { unknown } }"}`, wantStatus: http.StatusBadRequest},
+		{name: "syntax error", body: `{"query": "{ location("}`, wantStatus: http.StatusBadRequest},
+		{name: "missing batch scope", body: `{"query": "{ locations(ceps: [\"29902555\"]) { city } }"}`, wantStatus: http.StatusOK, wantCode: "forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			req, _ := http.NewRequest(http.MethodPost, st.serviceA.URL+"/graphql", strings.NewReader(tt.body))
+			req.Header.Set("X-API-Key", "lookup-key")
+			req.Header.Set("Accept-Language", "pt-BR")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error calling service-a: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var data map[string]any
+			_ = json.NewDecoder(resp.Body).Decode(&data)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d but got %d: %v", tt.wantStatus, resp.StatusCode, data)
+			}
+
+			errs, _ := data["errors"].([]any)
+			if tt.wantStatus == http.StatusBadRequest && len(errs) == 0 {
+				t.Fatalf("expected errors but got %v", data)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+
+			e, _ := errs[0].(map[string]any)
+			extensions, _ := e["extensions"].(map[string]any)
+			if extensions["code"] != tt.wantCode {
+				t.Errorf("expected code %s but got %v", tt.wantCode, e)
+			}
+			if msg, _ := e["message"].(string); tt.wantCode == "query_too_deep" && msg != "a profundidade da consulta 4 excede o limite de 3" {
+				t.Errorf("expected a localized message but got %q", msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package integration_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+)
+
+func TestGRPCTransport(t *testing.T) {
+
+	t.Setenv("REQUEST_SIGNING_KEY", "shared-secret")
+
+	setup := func(st *stack) {
+		st.cep.AddCity("29902555", "Linhares")
+		st.geocode.AddCoordinates("29902555", -19.39, -40.07)
+		st.weather.AddLocation(weather.WeatherLocation{Name: "Linhares", Country: "Brazil", Lat: -19.39, Lon: -40.07}, weather.CurrentWeather{FeelsLikeC: 25})
+	}
+
+	overHTTP := newStack(t)
+	setup(overHTTP)
+
+	t.Setenv("SERVICE_B_TRANSPORT", "grpc")
+	overGRPC := newStack(t)
+	setup(overGRPC)
+
+	tests := []struct {
+		name, method, path, body, lang string
+		wantStatus                     int
+		wantGRPC                       string
+		wantKeys                       []string
+	}{
+		{name: "index", method: http.MethodPost, path: "/", body: `{"cep": "29902555"}`, wantStatus: 200, wantGRPC: "weather.v1.WeatherService/GetWeatherByCEP"},
+		{name: "index not found", method: http.MethodPost, path: "/", body: `{"cep": "12345678"}`, lang: "pt-BR", wantStatus: 404, wantGRPC: "weather.v1.WeatherService/GetWeatherByCEP"},
+		{name: "weather", method: http.MethodGet, path: "/v1/weather/29902555", wantStatus: 200, wantGRPC: "weather.v1.WeatherService/GetWeatherByCEP"},
+		{name: "weather invalid", method: http.MethodGet, path: "/v1/weather/1234567a", wantStatus: 422},
+		{name: "weather in rankine with coordinates", method: http.MethodGet, path: "/v1/weather/29902555?units=c,r&fields=coordinates", wantStatus: 200, wantGRPC: "weather.v1.WeatherService/GetWeatherByCEP", wantKeys: []string{"temp_c", "temp_r", "lat", "lon"}},
+		{name: "weather with options", method: http.MethodGet, path: "/v1/weather/29902555?units=c&fields=address", wantStatus: 200},
+		{name: "weather with precision", method: http.MethodGet, path: "/v1/weather/29902555?precision=2", wantStatus: 200},
+		{name: "weather invalid units", method: http.MethodGet, path: "/v1/weather/29902555?units=x", wantStatus: 400},
+		{name: "forecast", method: http.MethodGet, path: "/v1/forecast/29902555?days=2", wantStatus: 200, wantGRPC: "weather.v1.WeatherService/StreamForecast"},
+		{name: "forecast in rankine", method: http.MethodGet, path: "/v1/forecast/29902555?days=2&units=k,r", wantStatus: 200, wantGRPC: "weather.v1.WeatherService/StreamForecast", wantKeys: []string{"days"}},
+		{name: "forecast invalid days", method: http.MethodGet, path: "/v1/forecast/29902555?days=20", wantStatus: 400},
+		{name: "forecast not found", method: http.MethodGet, path: "/v1/forecast/12345678", wantStatus: 404, wantGRPC: "weather.v1.WeatherService/StreamForecast"},
+		{name: "batch", method: http.MethodPost, path: "/v1/weather:batch", body: `{"ceps": ["29902555", "123", "12345678"]}`, lang: "es", wantStatus: 200, wantGRPC: "weather.v1.WeatherService/BatchGetWeather"},
+		{name: "batch with coordinates", method: http.MethodPost, path: "/v1/weather:batch?units=r&fields=coordinates", body: `{"ceps": ["29902555"]}`, wantStatus: 200, wantGRPC: "weather.v1.WeatherService/BatchGetWeather"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			before := len(overGRPC.spans.Ended())
+
+			wantResp, want := overHTTP.doLang(t, tt.method, tt.path, tt.body, tt.lang)
+			gotResp, got := overGRPC.doLang(t, tt.method, tt.path, tt.body, tt.lang)
+
+			if wantResp.StatusCode != tt.wantStatus || gotResp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d over both transports but got %d and %d: %v", tt.wantStatus, wantResp.StatusCode, gotResp.StatusCode, got)
+			}
+			if wantResp.Header.Get("Content-Type") != gotResp.Header.Get("Content-Type") {
+				t.Errorf("expected content type %q but got %q", wantResp.Header.Get("Content-Type"), gotResp.Header.Get("Content-Type"))
+			}
+
+			delete(want, "trace_id")
+			delete(got, "trace_id")
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("expected the same body over both transports:\nhttp: %v\ngrpc: %v", want, got)
+			}
+			for _, key := range tt.wantKeys {
+				if got[key] == nil {
+					t.Errorf("expected %q in the body but got %v", key, got)
+				}
+			}
+
+			var called string
+			for _, s := range overGRPC.spans.Ended()[before:] {
+				if strings.HasPrefix(s.Name(), "weather.v1.") {
+					called = s.Name()
+				}
+			}
+			if called != tt.wantGRPC {
+				t.Errorf("expected grpc call %q but got %q", tt.wantGRPC, called)
+			}
+		})
+	}
+}
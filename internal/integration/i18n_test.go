@@ -0,0 +1,134 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+func TestLocalizedResponses(t *testing.T) {
+
+	st := newStack(t)
+	st.cep.AddCity("29902555", "Linhares")
+	st.weather.AddLocation(
+		weather.WeatherLocation{Name: "Linhares", Country: "Brazil"},
+		weather.CurrentWeather{FeelsLikeC: 25, Condition: weather.Condition{Text: "Partly cloudy", Code: 1003}},
+	)
+	st.weather.AddTranslation("pt", 1003, "Parcialmente nublado")
+	st.weather.AddTranslation("es", 1003, "Parcialmente nublado")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		acceptLanguage string
+		wantStatus     int
+		wantLanguage   string
+		wantDetail     string
+		wantCode       string
+	}{
+		{name: "default invalid", method: http.MethodGet, path: "/v1/weather/123", wantStatus: http.StatusUnprocessableEntity, wantLanguage: "en", wantDetail: "invalid zipcode", wantCode: "invalid_zipcode"},
+		{name: "pt-BR invalid", method: http.MethodGet, path: "/v1/weather/123", acceptLanguage: "pt-BR,pt;q=0.9", wantStatus: http.StatusUnprocessableEntity, wantLanguage: "pt-BR", wantDetail: "CEP inválido", wantCode: "invalid_zipcode"},
+		{name: "pt falls back to pt-BR", method: http.MethodGet, path: "/v1/weather/12345678", acceptLanguage: "pt", wantStatus: http.StatusNotFound, wantLanguage: "pt-BR", wantDetail: "não foi possível encontrar o CEP", wantCode: "zipcode_not_found"},
+		{name: "es not found", method: http.MethodGet, path: "/v1/weather/12345678", acceptLanguage: "es-AR", wantStatus: http.StatusNotFound, wantLanguage: "es", wantDetail: "no se pudo encontrar el código postal", wantCode: "zipcode_not_found"},
+		{name: "unsupported language", method: http.MethodGet, path: "/v1/weather/123", acceptLanguage: "ja", wantStatus: http.StatusUnprocessableEntity, wantLanguage: "en", wantDetail: "invalid zipcode", wantCode: "invalid_zipcode"},
+		{name: "legacy route", method: http.MethodPost, path: "/", body: `{"cep": "123"}`, acceptLanguage: "pt-BR", wantStatus: http.StatusUnprocessableEntity, wantLanguage: "pt-BR", wantDetail: "CEP inválido", wantCode: "invalid_zipcode"},
+		{name: "invalid units", method: http.MethodGet, path: "/v1/weather/29902555?units=x", acceptLanguage: "es", wantStatus: http.StatusBadRequest, wantLanguage: "es", wantCode: "invalid_units"},
+		{name: "unknown route", method: http.MethodGet, path: "/v1/unknown", acceptLanguage: "pt-BR", wantStatus: http.StatusNotFound, wantLanguage: "pt-BR", wantCode: "not_found"},
+		{name: "method not allowed", method: http.MethodDelete, path: "/v1/weather/29902555", acceptLanguage: "pt-BR", wantStatus: http.StatusMethodNotAllowed, wantLanguage: "pt-BR", wantCode: "method_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			resp, data := st.doLang(t, tt.method, tt.path, tt.body, tt.acceptLanguage)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d but got %d: %v", tt.wantStatus, resp.StatusCode, data)
+			}
+			if got := resp.Header.Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("expected Content-Language %q but got %q", tt.wantLanguage, got)
+			}
+			if tt.wantDetail != "" && data["detail"] != tt.wantDetail {
+				t.Errorf("expected detail %q but got %v", tt.wantDetail, data["detail"])
+			}
+			if data["code"] != tt.wantCode {
+				t.Errorf("expected code %q but got %v", tt.wantCode, data["code"])
+			}
+		})
+	}
+
+	conditions := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{acceptLanguage: "", want: "Partly cloudy"},
+		{acceptLanguage: "pt-BR", want: "Parcialmente nublado"},
+		{acceptLanguage: "es", want: "Parcialmente nublado"},
+	}
+
+	for _, tt := range conditions {
+		_, data := st.doLang(t, http.MethodGet, "/v1/weather/29902555?fields=current", "", tt.acceptLanguage)
+		current, _ := data["current"].(map[string]any)
+		if current["condition"] != tt.want {
+			t.Errorf("%q: expected condition %q but got %v", tt.acceptLanguage, tt.want, data["current"])
+		}
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantTitle  string
+		wantCode   string
+	}{
+		{name: "relayed from service-b", method: http.MethodGet, path: "/v1/weather/12345678", wantStatus: http.StatusNotFound, wantTitle: "Not Found", wantCode: "zipcode_not_found"},
+		{name: "answered by service-a", method: http.MethodGet, path: "/v1/weather/123", wantStatus: http.StatusUnprocessableEntity, wantTitle: "Unprocessable Entity", wantCode: "invalid_zipcode"},
+		{name: "legacy route", method: http.MethodPost, path: "/", body: `{"cep": "12345678"}`, wantStatus: http.StatusNotFound, wantTitle: "Not Found", wantCode: "zipcode_not_found"},
+		{name: "unknown route", method: http.MethodGet, path: "/v1/unknown", wantStatus: http.StatusNotFound, wantTitle: "Not Found", wantCode: "not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			st := newStack(t)
+
+			resp, data := st.doLang(t, tt.method, tt.path, tt.body, "")
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d but got %d: %v", tt.wantStatus, resp.StatusCode, data)
+			}
+			if got := resp.Header.Get("Content-Type"); got != problem.ContentType {
+				t.Errorf("expected Content-Type %q but got %q", problem.ContentType, got)
+			}
+
+			want := map[string]any{
+				"type":     problem.TypePrefix + tt.wantCode,
+				"title":    tt.wantTitle,
+				"status":   float64(tt.wantStatus),
+				"instance": tt.path,
+				"code":     tt.wantCode,
+			}
+			for k, v := range want {
+				if data[k] != v {
+					t.Errorf("expected %s=%v but got %v", k, v, data[k])
+				}
+			}
+
+			// Every request is traced by service-a, matched route or not.
+			traces := make(map[string]bool)
+			for _, s := range st.spans.Ended() {
+				traces[s.SpanContext().TraceID().String()] = true
+			}
+			if id, _ := data["trace_id"].(string); !traces[id] {
+				t.Errorf("expected trace_id of a recorded trace but got %v", data["trace_id"])
+			}
+		})
+	}
+}
@@ -0,0 +1,487 @@
+package integration_test
+
+import (
+	"net/http"
+	"testing"
+
+	viacep "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather/weathertest"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLookupThroughBothServices(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func(st *stack)
+		wantStatus int
+		want       map[string]any
+		wantSpans  []string
+	}{
+		{
+			name: "success",
+			body: `{"cep": "29902555"}`,
+			setup: func(st *stack) {
+				st.cep.AddCity("29902555", "Linhares")
+				st.weather.AddCity("Linhares", 25)
+			},
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"city": "Linhares", "temp_c": 25.0, "temp_f": 77.0, "temp_k": 298.15},
+			wantSpans: []string{
+				"check-cep",
+				"service_b-handler: check cep and weather",
+				"service_b-handler-execute",
+				"service_b-handler-execute-city",
+				"service_b-handler-execute-weather",
+			},
+		},
+		{
+			name:       "cep not found",
+			body:       `{"cep": "12345678"}`,
+			wantStatus: http.StatusNotFound,
+			want:       map[string]any{"detail": "can not find zipcode"},
+			wantSpans: []string{
+				"check-cep",
+				"service_b-handler: check cep and weather",
+				"service_b-handler-execute",
+				"service_b-handler-execute-city",
+			},
+		},
+		{
+			name:       "invalid cep",
+			body:       `{"cep": "1234"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       map[string]any{"detail": "invalid zipcode"},
+			wantSpans:  []string{"check-cep"},
+		},
+		{
+			name: "weather provider failure",
+			body: `{"cep": "29902555"}`,
+			setup: func(st *stack) {
+				st.cep.AddCity("29902555", "Linhares")
+				st.weather.SetResponse("Linhares", weathertest.Response{Status: http.StatusInternalServerError})
+			},
+			wantStatus: http.StatusInternalServerError,
+			want:       map[string]any{"detail": "bad request"},
+			wantSpans: []string{
+				"check-cep",
+				"service_b-handler: check cep and weather",
+				"service_b-handler-execute",
+				"service_b-handler-execute-city",
+				"service_b-handler-execute-weather",
+			},
+		},
+		{
+			name: "service-b unreachable",
+			body: `{"cep": "29902555"}`,
+			setup: func(st *stack) {
+				st.serviceB.Close()
+			},
+			wantStatus: http.StatusInternalServerError,
+			want:       map[string]any{"detail": "internal server error"},
+			wantSpans:  []string{"check-cep"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			st := newStack(t)
+			if tt.setup != nil {
+				tt.setup(st)
+			}
+
+			status, data := st.post(t, tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("expected status %d but got %d", tt.wantStatus, status)
+			}
+			for k, v := range tt.want {
+				if data[k] != v {
+					t.Errorf("expected %s=%v but got %v", k, v, data[k])
+				}
+			}
+
+			assertSpanChain(t, st.spans.Ended(), tt.wantSpans)
+		})
+	}
+}
+
+func TestRESTRoutes(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		want        map[string]any
+		wantFields  map[string]any
+		wantCurrent map[string]any
+		wantAbsent  []string
+	}{
+		{
+			name:       "weather with all units",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"cep": "29902555", "city": "Linhares", "temp_c": 25.0, "temp_f": 77.0, "temp_k": 298.15},
+		},
+		{
+			name:       "weather restricted to units",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?units=c,k",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"city": "Linhares", "temp_c": 25.0, "temp_k": 298.15},
+			wantAbsent: []string{"temp_f"},
+		},
+		{
+			name:       "weather in rankine rounded to one decimal",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?units=k,r&precision=1",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"temp_k": 298.2, "temp_r": 536.7},
+			wantAbsent: []string{"temp_c", "temp_f"},
+		},
+		{
+			name:       "weather with legacy kelvin",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?units=k&compat=kelvin273",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"temp_k": 298.0},
+		},
+		{
+			name:       "legacy post with legacy kelvin",
+			method:     http.MethodPost,
+			path:       "/?compat=kelvin273",
+			body:       `{"cep": "29902555"}`,
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"temp_c": 25.0, "temp_f": 77.0, "temp_k": 298.0},
+		},
+		{
+			name:       "weather with invalid precision",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?precision=9",
+			wantStatus: http.StatusBadRequest,
+			want:       map[string]any{"detail": "invalid precision"},
+		},
+		{
+			name:       "weather with invalid compat",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?compat=kelvin300",
+			wantStatus: http.StatusBadRequest,
+			want:       map[string]any{"detail": "invalid compat"},
+		},
+		{
+			name:       "weather with invalid units",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?units=x",
+			wantStatus: http.StatusBadRequest,
+			want:       map[string]any{"detail": "invalid units"},
+		},
+		{
+			name:       "weather with invalid cep",
+			method:     http.MethodGet,
+			path:       "/v1/weather/abc",
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       map[string]any{"detail": "invalid zipcode"},
+		},
+		{
+			name:       "weather for unknown cep",
+			method:     http.MethodGet,
+			path:       "/v1/weather/12345678",
+			wantStatus: http.StatusNotFound,
+			want:       map[string]any{"detail": "can not find zipcode"},
+		},
+		{
+			name:       "weather with address",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?fields=address",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"city": "Linhares", "temp_c": 25.0},
+			wantFields: map[string]any{"state": "ES", "neighborhood": "Centro", "ibge": "3203205", "ddd": "27"},
+		},
+		{
+			name:       "weather with coordinates",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?fields=coordinates",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"city": "Linhares", "lat": -19.3946, "lon": -40.0643},
+			wantAbsent: []string{"address"},
+		},
+		{
+			name:        "weather with current conditions",
+			method:      http.MethodGet,
+			path:        "/v1/weather/29902555?fields=current&units=c,k",
+			wantStatus:  http.StatusOK,
+			want:        map[string]any{"temp_c": 25.0},
+			wantCurrent: map[string]any{"temp_c": 23.0, "temp_k": 296.15, "feelslike_c": 25.0, "humidity": 70.0, "wind_dir": "E", "condition": "Partly cloudy", "condition_code": 1003.0, "observed_at": "2024-06-12T14:00:00Z"},
+		},
+		{
+			name:        "legacy post with current conditions",
+			method:      http.MethodPost,
+			path:        "/?fields=current",
+			body:        `{"cep": "29902555"}`,
+			wantStatus:  http.StatusOK,
+			wantCurrent: map[string]any{"temp_c": 23.0, "temp_f": 73.4, "condition": "Partly cloudy"},
+		},
+		{
+			name:       "legacy post with address",
+			method:     http.MethodPost,
+			path:       "/?fields=address",
+			body:       `{"cep": "29902555"}`,
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"city": "Linhares", "temp_k": 298.15},
+			wantFields: map[string]any{"state": "ES", "street": "Avenida Augusto Calmon"},
+		},
+		{
+			name:       "legacy post without address",
+			method:     http.MethodPost,
+			path:       "/",
+			body:       `{"cep": "29902555"}`,
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"city": "Linhares"},
+			wantAbsent: []string{"address"},
+		},
+		{
+			name:       "weather with invalid fields",
+			method:     http.MethodGet,
+			path:       "/v1/weather/29902555?fields=nope",
+			wantStatus: http.StatusBadRequest,
+			want:       map[string]any{"detail": "invalid fields"},
+		},
+		{
+			name:       "cep only",
+			method:     http.MethodGet,
+			path:       "/v1/cep/29902555",
+			wantStatus: http.StatusOK,
+			want:       map[string]any{"cep": "29902555", "city": "Linhares"},
+			wantAbsent: []string{"temp_c", "temp_f", "temp_k"},
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodPost,
+			path:       "/v1/weather/29902555",
+			wantStatus: http.StatusMethodNotAllowed,
+			want:       map[string]any{"detail": "method not allowed"},
+		},
+		{
+			name:       "get on legacy root",
+			method:     http.MethodGet,
+			path:       "/",
+			wantStatus: http.StatusMethodNotAllowed,
+			want:       map[string]any{"detail": "method not allowed"},
+		},
+		{
+			name:       "unknown path",
+			method:     http.MethodGet,
+			path:       "/v2/nothing",
+			wantStatus: http.StatusNotFound,
+			want:       map[string]any{"detail": "not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			st := newStack(t)
+			st.cep.AddAddress("29902555", viacep.ViaCepResponse{
+				Logradouro: "Avenida Augusto Calmon",
+				Bairro:     "Centro",
+				Localidade: "Linhares",
+				Uf:         "ES",
+				Ibge:       "3203205",
+				Ddd:        "27",
+			})
+			st.weather.AddLocation(
+				weather.WeatherLocation{Name: "Linhares", Region: "Espirito Santo", Country: "Brazil", Lat: -19.3946, Lon: -40.0643},
+				weather.CurrentWeather{
+					LastUpdatedEpoch: 1718200800,
+					TempC:            23,
+					FeelsLikeC:       25,
+					Humidity:         70,
+					WindDir:          "E",
+					Condition:        weather.Condition{Text: "Partly cloudy", Code: 1003},
+				},
+			)
+
+			status, data := st.do(t, tt.method, tt.path, tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("expected status %d but got %d", tt.wantStatus, status)
+			}
+			for k, v := range tt.want {
+				if data[k] != v {
+					t.Errorf("expected %s=%v but got %v", k, v, data[k])
+				}
+			}
+			address, _ := data["address"].(map[string]any)
+			for k, v := range tt.wantFields {
+				if address[k] != v {
+					t.Errorf("expected address.%s=%v but got %v", k, v, address[k])
+				}
+			}
+			current, _ := data["current"].(map[string]any)
+			for k, v := range tt.wantCurrent {
+				if current[k] != v {
+					t.Errorf("expected current.%s=%v but got %v", k, v, current[k])
+				}
+			}
+			for _, k := range tt.wantAbsent {
+				if _, ok := data[k]; ok {
+					t.Errorf("expected %s to be absent but got %v", k, data[k])
+				}
+			}
+		})
+	}
+}
+
+func TestWeatherRegionMismatch(t *testing.T) {
+
+	st := newStack(t)
+	st.cep.AddAddress("64900000", viacep.ViaCepResponse{Localidade: "Bom Jesus", Uf: "PI"})
+	// The provider ignores the qualifiers and answers with the homonymous
+	// city from another state.
+	st.weather.SetResponse("Bom Jesus, Piauí, Brazil", weathertest.Response{
+		Status: http.StatusOK,
+		Body:   `{"location":{"name":"Bom Jesus","region":"Rio Grande do Sul","country":"Brazil"},"current":{"feelslike_c":12}}`,
+	})
+
+	status, _ := st.do(t, http.MethodGet, "/v1/weather/64900000", "")
+	if status != http.StatusInternalServerError {
+		t.Fatalf("expected status 500 but got %d", status)
+	}
+
+	var found bool
+	for _, s := range st.spans.Ended() {
+		if s.Name() != "service_b-handler-execute-weather" {
+			continue
+		}
+		for _, e := range s.Events() {
+			if e.Name == "weather.location_mismatch" {
+				found = true
+			}
+		}
+		if s.Status().Code != codes.Error {
+			t.Errorf("expected weather span to be marked as error but got %v", s.Status())
+		}
+	}
+	if !found {
+		t.Errorf("expected a weather.location_mismatch span event")
+	}
+}
+
+func TestAirQualityAndAlerts(t *testing.T) {
+
+	st := newLinharesStack(t)
+	st.weather.SetAirQuality("Linhares", weather.AirQuality{PM25: 40.2, PM10: 55, O3: 80, USEPAIndex: 4})
+	st.weather.AddAlert("Linhares", weather.Alert{Event: "Tempestade", Headline: "Alerta de tempestade", Severity: "Severe"})
+
+	status, data := st.do(t, http.MethodGet, "/v1/weather/29902555?fields=air_quality,alerts", "")
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %v", status, data)
+	}
+
+	aq, _ := data["air_quality"].(map[string]any)
+	want := map[string]any{"pm2_5": 40.2, "pm10": 55.0, "o3": 80.0, "us_epa_index": 4.0, "category": "Unhealthy"}
+	for k, v := range want {
+		if aq[k] != v {
+			t.Errorf("expected air_quality.%s=%v but got %v", k, v, aq[k])
+		}
+	}
+
+	alerts, _ := data["alerts"].([]any)
+	if len(alerts) != 1 || alerts[0].(map[string]any)["severity"] != "Severe" {
+		t.Errorf("expected one severe alert but got %v", data["alerts"])
+	}
+
+	var events int
+	for _, s := range st.spans.Ended() {
+		if s.Name() != "service_b-handler-execute-weather" {
+			continue
+		}
+		for _, e := range s.Events() {
+			if e.Name == "weather.alert" {
+				events++
+			}
+		}
+		var index int64
+		for _, kv := range s.Attributes() {
+			if kv.Key == "air_quality.us_epa_index" {
+				index = kv.Value.AsInt64()
+			}
+		}
+		if index != 4 {
+			t.Errorf("expected air_quality.us_epa_index=4 on the weather span but got %d", index)
+		}
+	}
+	if events != 1 {
+		t.Errorf("expected one weather.alert span event but got %d", events)
+	}
+
+	_, data = st.do(t, http.MethodPost, "/?fields=air_quality,alerts", `{"cep": "29902555"}`)
+	if _, ok := data["air_quality"]; !ok {
+		t.Errorf("expected the legacy route to relay air_quality: %v", data)
+	}
+	if _, ok := data["alerts"]; !ok {
+		t.Errorf("expected the legacy route to relay alerts: %v", data)
+	}
+
+	_, data = st.post(t, `{"cep": "29902555"}`)
+	if _, ok := data["air_quality"]; ok {
+		t.Errorf("air_quality should be omitted when not requested: %v", data)
+	}
+}
+
+// assertSpanChain checks that every span in want was recorded in a single
+// trace and that each one descends from the previous one. Spans added in
+// between (e.g. the otelhttp client span) are allowed.
+// TestWeatherCallsDescendFromWeatherSpans checks that the calls to the
+// weather provider are traced under the span of the weather lookup.
+func TestWeatherCallsDescendFromWeatherSpans(t *testing.T) {
+
+	tests := []struct {
+		path     string
+		wantSpan string
+	}{
+		{path: "/v1/weather/29902555", wantSpan: "service_b-handler-execute-weather"},
+		{path: "/v1/forecast/29902555?days=1", wantSpan: "service_b-handler-forecast-weather"},
+		{path: "/v1/weather/29902555/history?date=2024-06-12", wantSpan: "service_b-handler-history-weather"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wantSpan, func(t *testing.T) {
+
+			st := newLinharesStack(t)
+			st.weather.AddHistory("Linhares", weather.ForecastDay{Date: "2024-06-12"})
+
+			if status, data := st.do(t, http.MethodGet, tt.path, ""); status != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d: %v", status, data)
+			}
+
+			spans := st.spans.Ended()
+
+			var parent sdktrace.ReadOnlySpan
+			for _, s := range spans {
+				if s.Name() == tt.wantSpan {
+					parent = s
+				}
+			}
+			if parent == nil {
+				t.Fatalf("span %q was not recorded", tt.wantSpan)
+			}
+
+			var calls int
+			for _, s := range spans {
+				if s.SpanKind() == trace.SpanKindClient && s.Parent().SpanID() == parent.SpanContext().SpanID() {
+					calls++
+				}
+			}
+			if calls == 0 {
+				t.Errorf("expected the weather provider call to descend from %q", tt.wantSpan)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+)
+
+func TestRequestIDPropagation(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		requestID string
+	}{
+		{name: "client id on rest route", method: http.MethodGet, path: "/v1/weather/29902555", requestID: "req-123"},
+		{name: "client id on legacy route", method: http.MethodPost, path: "/", body: `{"cep": "29902555"}`, requestID: "req-456"},
+		{name: "generated id", method: http.MethodGet, path: "/v1/cep/29902555"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			st := newLinharesStack(t)
+
+			req, err := http.NewRequest(tt.method, st.serviceA.URL+tt.path, bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatalf("error building request: %v", err)
+			}
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-ID", tt.requestID)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error calling service-a: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d", resp.StatusCode)
+			}
+
+			got := resp.Header.Get("X-Request-ID")
+			if got == "" || tt.requestID != "" && got != tt.requestID {
+				t.Errorf("expected X-Request-ID %q to be echoed but got %q", tt.requestID, got)
+			}
+
+			// ViaCEP is called by service-b, so the ID went through both.
+			upstream := st.cep.RequestIDs("29902555")
+			if len(upstream) != 1 || upstream[0] != got {
+				t.Errorf("expected ViaCEP to receive X-Request-ID %q but got %v", got, upstream)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+
+	t.Setenv("API_KEYS", "basic "+apikey.Hash("basic-key")+" *; partner "+apikey.Hash("partner-key")+" *")
+	t.Setenv("RATE_LIMIT", "2/m")
+	t.Setenv("RATE_LIMIT_TIERS", "pro=10/m")
+	t.Setenv("RATE_LIMIT_KEYS", "partner=pro")
+
+	st := newLinharesStack(t)
+
+	tests := []struct {
+		apiKey        string
+		wantStatus    int
+		wantRemaining string
+	}{
+		{apiKey: "basic-key", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{apiKey: "basic-key", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{apiKey: "basic-key", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{apiKey: "partner-key", wantStatus: http.StatusOK, wantRemaining: "9"},
+		// Unknown keys are throttled by IP before being refused.
+		{apiKey: "guess", wantStatus: http.StatusUnauthorized, wantRemaining: "1"},
+		{apiKey: "guess", wantStatus: http.StatusUnauthorized, wantRemaining: "0"},
+		{apiKey: "guess", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{apiKey: "partner-key", wantStatus: http.StatusOK, wantRemaining: "8"},
+	}
+
+	for i, tt := range tests {
+
+		req, _ := http.NewRequest(http.MethodGet, st.serviceA.URL+"/v1/weather/29902555", nil)
+		req.Header.Set("X-API-Key", tt.apiKey)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error calling service-a: %v", err)
+		}
+
+		var data map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&data)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.wantStatus {
+			t.Fatalf("%d: expected status %d but got %d", i, tt.wantStatus, resp.StatusCode)
+		}
+		if got := resp.Header.Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%d: expected RateLimit-Remaining %s but got %q", i, tt.wantRemaining, got)
+		}
+
+		if tt.wantStatus == http.StatusTooManyRequests {
+			if data["code"] != "too_many_requests" || resp.Header.Get("Retry-After") != "30" {
+				t.Errorf("%d: expected a too_many_requests problem with Retry-After 30 but got %v %v", i, data, resp.Header)
+			}
+		}
+	}
+
+	// Throttled requests never reach service-b nor the providers.
+	if got := st.cep.Hits("29902555"); got != 4 {
+		t.Errorf("expected ViaCEP to be called 4 times but got %d", got)
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+
+	t.Setenv("API_KEYS", "lookup-only "+apikey.Hash("lookup-key")+" lookup; everything "+apikey.Hash("all-key")+" lookup,batch,forecast")
+
+	st := newLinharesStack(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		header     string
+		value      string
+		wantStatus int
+		wantCode   string
+		wantKeyID  string
+	}{
+		{name: "no key", method: http.MethodGet, path: "/v1/weather/29902555", wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "unknown key", method: http.MethodGet, path: "/v1/weather/29902555", header: "X-API-Key", value: "guess", wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "header key", method: http.MethodGet, path: "/v1/weather/29902555", header: "X-API-Key", value: "lookup-key", wantStatus: http.StatusOK, wantKeyID: "lookup-only"},
+		{name: "bearer token", method: http.MethodPost, path: "/", body: `{"cep": "29902555"}`, header: "Authorization", value: "Bearer lookup-key", wantStatus: http.StatusOK, wantKeyID: "lookup-only"},
+		{name: "missing forecast scope", method: http.MethodGet, path: "/v1/forecast/29902555", header: "X-API-Key", value: "lookup-key", wantStatus: http.StatusForbidden, wantCode: "forbidden", wantKeyID: "lookup-only"},
+		{name: "missing batch scope", method: http.MethodPost, path: "/v1/weather:batch", body: `{"ceps": ["29902555"]}`, header: "X-API-Key", value: "lookup-key", wantStatus: http.StatusForbidden, wantCode: "forbidden", wantKeyID: "lookup-only"},
+		{name: "batch scope", method: http.MethodPost, path: "/v1/weather:batch", body: `{"ceps": ["29902555"]}`, header: "Authorization", value: "bearer all-key", wantStatus: http.StatusOK, wantKeyID: "everything"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			before := len(st.spans.Ended())
+
+			req, _ := http.NewRequest(tt.method, st.serviceA.URL+tt.path, bytes.NewBufferString(tt.body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("error calling service-a: %v", err)
+			}
+
+			var data map[string]any
+			_ = json.NewDecoder(resp.Body).Decode(&data)
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d but got %d: %v", tt.wantStatus, resp.StatusCode, data)
+			}
+			if tt.wantCode != "" && data["code"] != tt.wantCode {
+				t.Errorf("expected code %s but got %v", tt.wantCode, data["code"])
+			}
+			if tt.wantStatus == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("expected a WWW-Authenticate challenge")
+			}
+
+			// Only the key ID, never the key, is recorded on the server span.
+			var keyID string
+			for _, s := range st.spans.Ended()[before:] {
+				for _, kv := range s.Attributes() {
+					if v := kv.Value.Emit(); strings.Contains(v, "lookup-key") || strings.Contains(v, "all-key") {
+						t.Errorf("span %q records the key in %s", s.Name(), kv.Key)
+					}
+					if kv.Key == "apikey.id" {
+						keyID = kv.Value.AsString()
+					}
+				}
+			}
+			if keyID != tt.wantKeyID {
+				t.Errorf("expected apikey.id %q on the span but got %q", tt.wantKeyID, keyID)
+			}
+		})
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+
+	t.Setenv("REQUEST_SIGNING_KEY", "shared-secret")
+
+	st := newLinharesStack(t)
+
+	// service-a signs its calls, so lookups through it keep working.
+	for _, tt := range []struct{ method, path, body string }{
+		{method: http.MethodPost, path: "/", body: `{"cep": "29902555"}`},
+		{method: http.MethodGet, path: "/v1/weather/29902555?units=c,k"},
+		{method: http.MethodPost, path: "/v1/weather:batch", body: `{"ceps": ["29902555"]}`},
+	} {
+		if status, data := st.do(t, tt.method, tt.path, tt.body); status != http.StatusOK {
+			t.Errorf("%s %s: expected status 200 through service-a but got %d: %v", tt.method, tt.path, status, data)
+		}
+	}
+
+	// Calls straight to service-b are refused.
+	resp, err := http.Post(st.serviceB.URL+"/", "application/json", bytes.NewBufferString(`{"cep": "29902555"}`))
+	if err != nil {
+		t.Fatalf("error calling service-b: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var data map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&data)
+	if resp.StatusCode != http.StatusUnauthorized || data["code"] != "invalid_signature" {
+		t.Errorf("expected an invalid_signature problem from service-b but got %d %v", resp.StatusCode, data)
+	}
+}
@@ -0,0 +1,192 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep/ceptest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather/weathertest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/servicea"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/serviceb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var tracerProvider = sdktrace.NewTracerProvider()
+
+func TestMain(m *testing.M) {
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	os.Exit(m.Run())
+}
+
+// stack is one in-process deployment of both services and their upstreams.
+type stack struct {
+	cep      *ceptest.Server
+	geocode  *geocodetest.Server
+	weather  *weathertest.Server
+	serviceA *httptest.Server
+	serviceB *httptest.Server
+	spans    *tracetest.SpanRecorder
+}
+
+func newStack(t *testing.T) *stack {
+	t.Helper()
+
+	st := &stack{
+		cep:     ceptest.NewServer(),
+		weather: weathertest.NewServer(),
+		spans:   tracetest.NewSpanRecorder(),
+	}
+	t.Cleanup(st.cep.Close)
+	t.Cleanup(st.weather.Close)
+
+	tracerProvider.RegisterSpanProcessor(st.spans)
+	t.Cleanup(func() { tracerProvider.UnregisterSpanProcessor(st.spans) })
+
+	st.geocode = geocodetest.NewServer()
+	t.Cleanup(st.geocode.Close)
+
+	t.Setenv("VIACEP_BASE_URL", st.cep.URL)
+	t.Setenv("GEOCODE_BASE_URL", st.geocode.URL)
+	t.Setenv("WEATHER_API_BASE_URL", st.weather.URL)
+	t.Setenv("WEATHER_API_KEY", "test-key")
+	st.weather.SetAPIKey("test-key")
+
+	service := domain.NewLocationService(domain.NewLocationRepository())
+	st.serviceB = httptest.NewServer(serviceb.NewServer(service).Handler())
+	t.Cleanup(st.serviceB.Close)
+
+	// With SERVICE_B_TRANSPORT=grpc, service-a reaches the gRPC API of the
+	// same service-b.
+	if os.Getenv("SERVICE_B_TRANSPORT") == "grpc" {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		grpcSrv := serviceb.NewServer(service).GRPCServer(nil)
+		go func() { _ = grpcSrv.Serve(lis) }()
+		t.Cleanup(grpcSrv.Stop)
+		t.Setenv("SERVICE_B_GRPC_ADDR", lis.Addr().String())
+	}
+
+	st.serviceA = httptest.NewServer(servicea.NewServer(st.serviceB.URL).Handler())
+	t.Cleanup(st.serviceA.Close)
+
+	return st
+}
+
+// newLinharesStack returns a stack in which the CEP 29902555 is in
+// Linhares, where it feels like 25°C.
+func newLinharesStack(t *testing.T) *stack {
+	t.Helper()
+
+	st := newStack(t)
+	st.cep.AddCity("29902555", "Linhares")
+	st.weather.AddCity("Linhares", 25)
+
+	return st
+}
+
+func (st *stack) post(t *testing.T, body string) (int, map[string]any) {
+	t.Helper()
+	return st.do(t, http.MethodPost, "/", body)
+}
+
+func (st *stack) do(t *testing.T, method, path, body string) (int, map[string]any) {
+	t.Helper()
+	resp, data := st.doLang(t, method, path, body, "")
+	return resp.StatusCode, data
+}
+
+// doLang calls service-a with acceptLanguage as the Accept-Language header,
+// if not empty.
+func (st *stack) doLang(t *testing.T, method, path, body, acceptLanguage string) (*http.Response, map[string]any) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, st.serviceA.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error calling service-a: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var data map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		t.Fatalf("error decoding service-a response: %v", err)
+	}
+
+	return resp, data
+}
+
+func assertSpanChain(t *testing.T, spans []sdktrace.ReadOnlySpan, want []string) {
+	t.Helper()
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	byID := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = s
+		byID[s.SpanContext().SpanID().String()] = s
+	}
+
+	var previous sdktrace.ReadOnlySpan
+	for _, n := range want {
+
+		s, ok := byName[n]
+		if !ok {
+			t.Fatalf("span %q was not recorded", n)
+		}
+
+		if previous != nil {
+
+			if s.SpanContext().TraceID() != previous.SpanContext().TraceID() {
+				t.Errorf("span %q is not in the same trace as %q", n, previous.Name())
+			}
+
+			if !descendsFrom(s, previous, byID) {
+				t.Errorf("span %q does not descend from %q", n, previous.Name())
+			}
+		}
+
+		previous = s
+	}
+}
+
+func descendsFrom(s, ancestor sdktrace.ReadOnlySpan, byID map[string]sdktrace.ReadOnlySpan) bool {
+
+	for s.Parent().IsValid() {
+		if s.Parent().SpanID() == ancestor.SpanContext().SpanID() {
+			return true
+		}
+
+		parent, ok := byID[s.Parent().SpanID().String()]
+		if !ok {
+			return false
+		}
+		s = parent
+	}
+
+	return false
+}
@@ -0,0 +1,219 @@
+package integration_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+// sseEvent is one Server-Sent Event read from a stream.
+type sseEvent struct {
+	name string
+	id   string
+	data string
+}
+
+// stream opens the event stream of service-a at path, resuming after
+// lastEventID if not empty. The events of a 200 are read into the returned
+// channel until the stream is closed, by cancel or with the test.
+func (st *stack) stream(t *testing.T, path, lastEventID string) (*http.Response, <-chan sseEvent, context.CancelFunc) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, st.serviceA.URL+path, nil)
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error calling service-a: %v", err)
+	}
+
+	events := make(chan sseEvent, 16)
+	if resp.StatusCode != http.StatusOK {
+		close(events)
+		return resp, events, cancel
+	}
+
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "event":
+				e.name = value
+			case "id":
+				e.id = value
+			case "data":
+				e.data = value
+			case "":
+				if e.name != "" {
+					events <- e
+				}
+				e = sseEvent{}
+			}
+		}
+	}()
+
+	return resp, events, cancel
+}
+
+// nextEvent returns the next event of events named name, skipping the others.
+func nextEvent(t *testing.T, events <-chan sseEvent, name string) sseEvent {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("expected a %s event but the stream ended", name)
+			}
+			if e.name == name {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("expected a %s event but got none", name)
+		}
+	}
+}
+
+// feelsLike decodes the temp_c of the location carried by a weather event.
+func feelsLike(t *testing.T, e sseEvent) float64 {
+	t.Helper()
+
+	var data struct {
+		City  string  `json:"city"`
+		TempC float64 `json:"temp_c"`
+	}
+	if err := json.Unmarshal([]byte(e.data), &data); err != nil {
+		t.Fatalf("error decoding event %q: %v", e.data, err)
+	}
+	if data.City != "Linhares" {
+		t.Errorf("expected city Linhares but got %q", data.City)
+	}
+
+	return data.TempC
+}
+
+func TestWeatherStream(t *testing.T) {
+
+	t.Setenv("STREAM_POLL_INTERVAL", "20ms")
+	t.Setenv("STREAM_HEARTBEAT_INTERVAL", "50ms")
+	t.Setenv("STREAM_MAX_PER_CLIENT", "3")
+
+	st := newStack(t)
+	st.cep.AddCity("29902555", "Linhares")
+	st.cep.AddCity("29900000", "Linhares")
+	st.weather.AddCity("Linhares", 25)
+
+	resp, events, _ := st.stream(t, "/v1/weather/29902555/stream", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected Content-Type text/event-stream but got %q", ct)
+	}
+
+	first := nextEvent(t, events, "weather")
+	if got := feelsLike(t, first); got != 25 {
+		t.Errorf("expected temp_c 25 but got %v", got)
+	}
+
+	st.weather.SetCurrent("Linhares", weather.CurrentWeather{FeelsLikeC: 27})
+
+	second := nextEvent(t, events, "weather")
+	if got := feelsLike(t, second); got != 27 {
+		t.Errorf("expected temp_c 27 but got %v", got)
+	}
+	firstID, _ := strconv.ParseInt(first.id, 10, 64)
+	secondID, _ := strconv.ParseInt(second.id, 10, 64)
+	if firstID == 0 || secondID <= firstID {
+		t.Errorf("expected growing event IDs but got %q and %q", first.id, second.id)
+	}
+
+	nextEvent(t, events, "heartbeat")
+
+	// A client resuming after an older reading gets the latest one right
+	// away, from the poller shared by the CEPs of the city.
+	_, behind, _ := st.stream(t, "/v1/weather/29900000/stream", first.id)
+	if e := nextEvent(t, behind, "weather"); e.id != second.id {
+		t.Errorf("expected the latest event %s but got %s", second.id, e.id)
+	}
+
+	// A client resuming after the latest reading only gets the next one.
+	_, upToDate, _ := st.stream(t, "/v1/weather/29902555/stream", second.id)
+	if e := <-upToDate; e.name != "heartbeat" {
+		t.Errorf("expected a heartbeat before any new reading but got %+v", e)
+	}
+
+	st.weather.SetCurrent("Linhares", weather.CurrentWeather{FeelsLikeC: 30})
+
+	if got := feelsLike(t, nextEvent(t, upToDate, "weather")); got != 30 {
+		t.Errorf("expected temp_c 30 but got %v", got)
+	}
+
+	// The client has STREAM_MAX_PER_CLIENT streams open.
+	resp, _, _ = st.stream(t, "/v1/weather/29902555/stream", "")
+	var p problem.Problem
+	_ = json.NewDecoder(resp.Body).Decode(&p)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || p.Code != string(i18n.CodeTooManyStreams) {
+		t.Errorf("expected status %d with code %s but got %d with %q", http.StatusTooManyRequests, i18n.CodeTooManyStreams, resp.StatusCode, p.Code)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected a Retry-After header")
+	}
+}
+
+func TestWeatherStreamErrors(t *testing.T) {
+
+	t.Setenv("STREAM_MAX_SUBSCRIBERS", "1")
+
+	st := newLinharesStack(t)
+
+	_, events, _ := st.stream(t, "/v1/weather/29902555/stream", "")
+	nextEvent(t, events, "weather")
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCode   i18n.Code
+	}{
+		{name: "invalid cep", path: "/v1/weather/123/stream", wantStatus: http.StatusUnprocessableEntity, wantCode: i18n.CodeInvalidZipcode},
+		{name: "unknown cep", path: "/v1/weather/12345678/stream", wantStatus: http.StatusNotFound, wantCode: i18n.CodeZipcodeNotFound},
+		{name: "invalid units", path: "/v1/weather/29902555/stream?units=x", wantStatus: http.StatusBadRequest, wantCode: i18n.CodeInvalidUnits},
+		{name: "service-b full", path: "/v1/weather/29902555/stream", wantStatus: http.StatusServiceUnavailable, wantCode: i18n.CodeTooManyStreams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			status, data := st.do(t, http.MethodGet, tt.path, "")
+			if status != tt.wantStatus || data["code"] != string(tt.wantCode) {
+				t.Errorf("expected status %d with code %s but got %d with %v", tt.wantStatus, tt.wantCode, status, data["code"])
+			}
+		})
+	}
+}
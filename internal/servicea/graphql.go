@@ -0,0 +1,489 @@
+package servicea
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// graphQLSchema is the schema served on /graphql. Its resolvers find the
+// state of the request with gqlQueryFrom.
+var graphQLSchema = mustGraphQLSchema()
+
+func mustGraphQLSchema() graphql.Schema {
+
+	address := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Address",
+		Fields: graphql.Fields{
+			"street":       {Type: graphql.String},
+			"complement":   {Type: graphql.String},
+			"neighborhood": {Type: graphql.String},
+			"city":         {Type: graphql.String},
+			"state":        {Type: graphql.String},
+			"ibge":         {Type: graphql.String},
+			"ddd":          {Type: graphql.String},
+		},
+	})
+
+	conditions := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Conditions",
+		Fields: graphql.Fields{
+			"tempC":         {Type: graphql.Float},
+			"tempF":         {Type: graphql.Float},
+			"tempK":         {Type: graphql.Float},
+			"tempR":         {Type: graphql.Float},
+			"feelsLikeC":    {Type: graphql.Float},
+			"feelsLikeF":    {Type: graphql.Float},
+			"feelsLikeK":    {Type: graphql.Float},
+			"feelsLikeR":    {Type: graphql.Float},
+			"humidity":      {Type: graphql.Int},
+			"windKph":       {Type: graphql.Float},
+			"windDegree":    {Type: graphql.Int},
+			"windDir":       {Type: graphql.String},
+			"pressureMb":    {Type: graphql.Float},
+			"uv":            {Type: graphql.Float},
+			"condition":     {Type: graphql.String},
+			"conditionCode": {Type: graphql.Int},
+			"observedAt":    {Type: graphql.DateTime},
+		},
+	})
+
+	airQuality := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AirQuality",
+		Fields: graphql.Fields{
+			"pm2_5":      {Type: graphql.Float, Resolve: airQualityField(func(a *domain.AirQualityView) any { return a.PM25 })},
+			"pm10":       {Type: graphql.Float, Resolve: airQualityField(func(a *domain.AirQualityView) any { return a.PM10 })},
+			"o3":         {Type: graphql.Float, Resolve: airQualityField(func(a *domain.AirQualityView) any { return a.O3 })},
+			"usEpaIndex": {Type: graphql.Int, Resolve: airQualityField(func(a *domain.AirQualityView) any { return a.USEPAIndex })},
+			"category":   {Type: graphql.String},
+		},
+	})
+
+	alert := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Alert",
+		Fields: graphql.Fields{
+			"event":       {Type: graphql.String},
+			"headline":    {Type: graphql.String},
+			"severity":    {Type: graphql.String},
+			"urgency":     {Type: graphql.String},
+			"areas":       {Type: graphql.String},
+			"description": {Type: graphql.String},
+			"instruction": {Type: graphql.String},
+			"effective":   {Type: graphql.DateTime},
+			"expires":     {Type: graphql.DateTime},
+		},
+	})
+
+	forecastDay := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ForecastDay",
+		Fields: graphql.Fields{
+			"date":         {Type: graphql.String},
+			"minC":         {Type: graphql.Float},
+			"minF":         {Type: graphql.Float},
+			"minK":         {Type: graphql.Float},
+			"minR":         {Type: graphql.Float},
+			"maxC":         {Type: graphql.Float},
+			"maxF":         {Type: graphql.Float},
+			"maxK":         {Type: graphql.Float},
+			"maxR":         {Type: graphql.Float},
+			"avgC":         {Type: graphql.Float},
+			"avgF":         {Type: graphql.Float},
+			"avgK":         {Type: graphql.Float},
+			"avgR":         {Type: graphql.Float},
+			"chanceOfRain": {Type: graphql.Int},
+			"condition":    {Type: graphql.String},
+		},
+	})
+
+	forecast := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Forecast",
+		Fields: graphql.Fields{
+			"cep":  {Type: graphql.String},
+			"city": {Type: graphql.String},
+			"days": {Type: graphql.NewList(graphql.NewNonNull(forecastDay))},
+		},
+	})
+
+	location := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Location",
+		Fields: graphql.Fields{
+			"cep":        {Type: graphql.NewNonNull(graphql.String)},
+			"city":       {Type: graphql.String},
+			"tempC":      {Type: graphql.Float},
+			"tempF":      {Type: graphql.Float},
+			"tempK":      {Type: graphql.Float},
+			"tempR":      {Type: graphql.Float},
+			"lat":        {Type: graphql.Float},
+			"lon":        {Type: graphql.Float},
+			"address":    {Type: address, Resolve: traced("Location", "address", graphql.DefaultResolveFn)},
+			"current":    {Type: conditions, Resolve: traced("Location", "current", graphql.DefaultResolveFn)},
+			"airQuality": {Type: airQuality, Resolve: traced("Location", "airQuality", graphql.DefaultResolveFn)},
+			"alerts":     {Type: graphql.NewList(graphql.NewNonNull(alert)), Resolve: traced("Location", "alerts", graphql.DefaultResolveFn)},
+			"forecast": {
+				Type:        forecast,
+				Description: "Daily forecast, starting today. Requires the forecast scope.",
+				Args: graphql.FieldConfigArgument{
+					"days": {Type: graphql.Int, DefaultValue: domain.DefaultForecastDays},
+				},
+				Resolve: traced("Location", "forecast", resolveForecast),
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"location": {
+				Type: location,
+				Args: graphql.FieldConfigArgument{
+					"cep": {Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: traced("Query", "location", resolveLocation),
+			},
+			"locations": {
+				Type:        graphql.NewList(location),
+				Description: "Locations of every CEP, in order. Requires the batch scope.",
+				Args: graphql.FieldConfigArgument{
+					"ceps": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: traced("Query", "locations", resolveLocations),
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		log.Fatalln("error to build graphql schema:", err)
+	}
+
+	return schema
+}
+
+// airQualityField resolves a field of AirQuality, whose readings the
+// default resolver can not find in the struct embedded by the view.
+func airQualityField(field func(a *domain.AirQualityView) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+
+		a, ok := p.Source.(*domain.AirQualityView)
+		if !ok || a == nil {
+			return nil, nil
+		}
+
+		return field(a), nil
+	}
+}
+
+// gqlLocation is the source of the Location fields: the view answered by
+// service-b and the context of the span of the field that resolved it, so
+// that the spans of its own fields descend from it.
+type gqlLocation struct {
+	ctx  context.Context
+	view *domain.LocationView
+}
+
+// Resolve implements graphql.FieldResolver for the fields read from view.
+func (l *gqlLocation) Resolve(p graphql.ResolveParams) (any, error) {
+	p.Source = l.view
+	return graphql.DefaultResolveFn(p)
+}
+
+func resolveLocation(p graphql.ResolveParams) (any, error) {
+
+	q := gqlQueryFrom(p.Context)
+	q.selectFields(selection(p.Info))
+
+	cep, _ := p.Args["cep"].(string)
+	thunk := q.locations.load(cep)
+
+	return func() (any, error) {
+
+		view, err := thunk()
+		if err != nil {
+			return nil, err
+		}
+
+		return &gqlLocation{ctx: p.Context, view: view}, nil
+	}, nil
+}
+
+// resolveLocations answers one item per CEP, the failed ones being thunks
+// returning their error so that it is reported at the path of the item.
+func resolveLocations(p graphql.ResolveParams) (any, error) {
+
+	q := gqlQueryFrom(p.Context)
+	if !q.server.keys.Allowed(p.Context, apikey.ScopeBatch) {
+		return nil, newFieldError(p.Context, http.StatusForbidden, i18n.CodeForbidden, apikey.ScopeBatch)
+	}
+	q.selectFields(selection(p.Info))
+
+	ceps, _ := p.Args["ceps"].([]any)
+	thunks := make([]func() (*domain.LocationView, error), len(ceps))
+	for i, cep := range ceps {
+		thunks[i] = q.locations.load(cep.(string))
+	}
+
+	return func() (any, error) {
+
+		items := make([]any, len(thunks))
+		for i, thunk := range thunks {
+
+			view, err := thunk()
+			if err != nil {
+				items[i] = func() (any, error) { return nil, err }
+				continue
+			}
+			items[i] = &gqlLocation{ctx: p.Context, view: view}
+		}
+
+		return items, nil
+	}, nil
+}
+
+func resolveForecast(p graphql.ResolveParams) (any, error) {
+
+	q := gqlQueryFrom(p.Context)
+	if !q.server.keys.Allowed(p.Context, apikey.ScopeForecast) {
+		return nil, newFieldError(p.Context, http.StatusForbidden, i18n.CodeForbidden, apikey.ScopeForecast)
+	}
+
+	days, _ := p.Args["days"].(int)
+	if days < 1 || days > domain.MaxForecastDays {
+		return nil, newFieldError(p.Context, http.StatusBadRequest, i18n.CodeInvalidDays)
+	}
+
+	l := p.Source.(*gqlLocation)
+	thunk := q.forecasts.load(forecastKey{cep: l.view.CEP, days: days})
+
+	return func() (any, error) { return thunk() }, nil
+}
+
+// traced resolves the field of parent inside a span of its own, child of
+// the span of the Location it belongs to, if any. The span of a field
+// resolved by a thunk ends when the thunk returns.
+func traced(parent, field string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+
+	return func(p graphql.ResolveParams) (any, error) {
+
+		ctx := p.Context
+		if l, ok := p.Source.(*gqlLocation); ok {
+			ctx = l.ctx
+		}
+
+		ctx, span := tracer.Start(ctx, "graphql-resolve "+parent+"."+field)
+		span.SetAttributes(
+			attribute.String("graphql.field.name", field),
+			attribute.String("graphql.field.parent", parent),
+			attribute.String("graphql.field.path", responsePath(p.Info.Path)),
+		)
+		p.Context = ctx
+
+		value, err := resolve(p)
+		if thunk, ok := value.(func() (any, error)); ok && err == nil {
+			return func() (any, error) {
+				value, err := thunk()
+				endFieldSpan(span, err)
+				return value, err
+			}, nil
+		}
+
+		endFieldSpan(span, err)
+		return value, err
+	}
+}
+
+func endFieldSpan(span trace.Span, err error) {
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func responsePath(path *graphql.ResponsePath) string {
+
+	var parts []string
+	for _, p := range path.AsArray() {
+		switch p := p.(type) {
+		case string:
+			parts = append(parts, p)
+		case int:
+			parts = append(parts, "["+strconv.Itoa(p)+"]")
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// selection returns the names of the fields selected under the field
+// being resolved, fragments included.
+func selection(info graphql.ResolveInfo) []string {
+
+	var names []string
+
+	var walk func(set *ast.SelectionSet)
+	walk = func(set *ast.SelectionSet) {
+
+		if set == nil {
+			return
+		}
+
+		for _, sel := range set.Selections {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				names = append(names, sel.Name.Value)
+			case *ast.InlineFragment:
+				walk(sel.SelectionSet)
+			case *ast.FragmentSpread:
+				if f, ok := info.Fragments[sel.Name.Value].(*ast.FragmentDefinition); ok {
+					walk(f.SelectionSet)
+				}
+			}
+		}
+	}
+
+	for _, f := range info.FieldASTs {
+		walk(f.SelectionSet)
+	}
+
+	return names
+}
+
+// graphQLRequest is the body of POST /graphql, also read from the query
+// string of GET /graphql.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// handlerGraphQL answers a GraphQL query. The document is parsed, validated
+// and checked against the depth and complexity limits before it runs; a
+// query failing any of these is answered with 400 and no data.
+func (s *Server) handlerGraphQL(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := tracer.Start(r.Context(), "graphql")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	span.SetAttributes(attribute.String("service.name", "service-a"))
+
+	var req graphQLRequest
+
+	var err error
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			err = json.Unmarshal([]byte(v), &req.Variables)
+		}
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&req)
+	}
+	if err != nil || strings.TrimSpace(req.Query) == "" {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeBadRequest)
+		return
+	}
+
+	span.SetAttributes(attribute.String("graphql.operation.name", req.OperationName))
+	webserver.AccessLogAttrs(ctx, slog.String("graphql.operation", req.OperationName))
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		replyGraphQL(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	if v := graphql.ValidateDocument(&graphQLSchema, doc, nil); !v.IsValid {
+		replyGraphQL(w, http.StatusBadRequest, &graphql.Result{Errors: v.Errors})
+		return
+	}
+
+	depth, complexity := queryCost(doc, req.OperationName, req.Variables)
+	span.SetAttributes(
+		attribute.Int("graphql.query.depth", depth),
+		attribute.Int("graphql.query.complexity", complexity),
+	)
+	webserver.AccessLogAttrs(ctx, slog.Int("graphql.complexity", complexity))
+
+	var limitErr *fieldError
+	if depth > s.graphQLLimits.depth {
+		limitErr = newFieldError(ctx, http.StatusBadRequest, i18n.CodeQueryTooDeep, depth, s.graphQLLimits.depth)
+	} else if complexity > s.graphQLLimits.complexity {
+		limitErr = newFieldError(ctx, http.StatusBadRequest, i18n.CodeQueryTooComplex, complexity, s.graphQLLimits.complexity)
+	}
+	if limitErr != nil {
+		log.Println("graphql query refused:", limitErr)
+		replyGraphQL(w, http.StatusBadRequest, &graphql.Result{Errors: []gqlerrors.FormattedError{
+			{Message: limitErr.message, Extensions: limitErr.Extensions()},
+		}})
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        graphQLSchema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withGQLQuery(ctx, newGQLQuery(ctx, s)),
+	})
+	restoreExtensions(result.Errors)
+
+	span.SetAttributes(attribute.Int("graphql.errors", len(result.Errors)))
+
+	replyGraphQL(w, http.StatusOK, result)
+}
+
+// restoreExtensions puts back the extensions of the errors returned by
+// thunks, which graphql-go loses while wrapping them.
+func restoreExtensions(errs []gqlerrors.FormattedError) {
+
+	for i := range errs {
+
+		err := errs[i].OriginalError()
+		for err != nil && errs[i].Extensions == nil {
+			switch e := err.(type) {
+			case *fieldError:
+				errs[i].Extensions = e.Extensions()
+			case *gqlerrors.Error:
+				err = e.OriginalError
+			case gqlerrors.FormattedError:
+				err = e.OriginalError()
+			default:
+				err = nil
+			}
+		}
+	}
+}
+
+func replyGraphQL(w http.ResponseWriter, status int, result *graphql.Result) {
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		log.Println("error marshaling graphql result:", err)
+		status, b = http.StatusInternalServerError, []byte(`{"errors":[{"message":"internal server error"}]}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
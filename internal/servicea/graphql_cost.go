@@ -0,0 +1,174 @@
+package servicea
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+)
+
+// Default limits of the queries answered by POST /graphql, overridable with
+// the GRAPHQL_MAX_DEPTH and GRAPHQL_MAX_COMPLEXITY environment variables.
+const (
+	DefaultGraphQLMaxDepth      = 6
+	DefaultGraphQLMaxComplexity = 1000
+)
+
+// queryLimits caps the depth and complexity of a GraphQL operation, checked
+// before it runs.
+type queryLimits struct {
+	depth      int
+	complexity int
+}
+
+// queryLimitsFromEnv reads the limits from GRAPHQL_MAX_DEPTH and
+// GRAPHQL_MAX_COMPLEXITY, keeping the defaults for invalid values.
+func queryLimitsFromEnv() queryLimits {
+
+	l := queryLimits{depth: DefaultGraphQLMaxDepth, complexity: DefaultGraphQLMaxComplexity}
+
+	for key, limit := range map[string]*int{"GRAPHQL_MAX_DEPTH": &l.depth, "GRAPHQL_MAX_COMPLEXITY": &l.complexity} {
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Println("invalid "+key+", using default:", v)
+			continue
+		}
+		*limit = n
+	}
+
+	return l
+}
+
+// queryCost returns the depth and complexity of the operation of doc named
+// operationName, or of its only operation. Fragments are inlined and the
+// introspection fields are free. Every field costs one, and the selection of
+// a field returning one item per CEP or per forecast day costs once per item.
+func queryCost(doc *ast.Document, operationName string, variables map[string]any) (depth, complexity int) {
+
+	w := costWalker{fragments: make(map[string]*ast.FragmentDefinition), variables: variables}
+
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			w.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		return 0, 0
+	}
+
+	return w.selectionSet(op.SelectionSet)
+}
+
+type costWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+func (w costWalker) selectionSet(set *ast.SelectionSet) (depth, complexity int) {
+
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = w.field(sel)
+		case *ast.InlineFragment:
+			d, c = w.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			if f, ok := w.fragments[sel.Name.Value]; ok {
+				d, c = w.selectionSet(f.SelectionSet)
+			}
+		}
+
+		depth = max(depth, d)
+		complexity += c
+	}
+
+	return depth, complexity
+}
+
+func (w costWalker) field(f *ast.Field) (depth, complexity int) {
+
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+
+	depth, complexity = w.selectionSet(f.SelectionSet)
+
+	return depth + 1, 1 + w.items(f)*complexity
+}
+
+// items is the number of times the selection of f is resolved.
+func (w costWalker) items(f *ast.Field) int {
+
+	switch f.Name.Value {
+	case "locations":
+		if ceps, ok := w.argument(f, "ceps").([]any); ok {
+			return max(1, len(ceps))
+		}
+	case "forecast":
+		switch days := w.argument(f, "days").(type) {
+		case nil:
+			return domain.DefaultForecastDays
+		case int:
+			return max(1, days)
+		}
+	}
+
+	return 1
+}
+
+// argument returns the value of the argument name of f, with lists as []any
+// and integers as int, looking variables up.
+func (w costWalker) argument(f *ast.Field, name string) any {
+
+	for _, arg := range f.Arguments {
+		if arg.Name.Value == name {
+			return w.value(arg.Value)
+		}
+	}
+
+	return nil
+}
+
+func (w costWalker) value(v ast.Value) any {
+
+	switch v := v.(type) {
+	case *ast.Variable:
+		// Variables come decoded from JSON.
+		switch value := w.variables[v.Name.Value].(type) {
+		case float64:
+			return int(value)
+		default:
+			return value
+		}
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(v.Value)
+		return n
+	case *ast.ListValue:
+		values := make([]any, len(v.Values))
+		for i, item := range v.Values {
+			values[i] = w.value(item)
+		}
+		return values
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package servicea
+
+import (
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+func TestQueryCost(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		query          string
+		operation      string
+		variables      map[string]any
+		wantDepth      int
+		wantComplexity int
+	}{
+		{name: "one field", query: `{ location(cep: "29902555") { city } }`, wantDepth: 2, wantComplexity: 2},
+		{name: "nested", query: `{ location(cep: "29902555") { city address { city state } } }`, wantDepth: 3, wantComplexity: 5},
+		{name: "forecast days", query: `{ location(cep: "29902555") { forecast(days: 5) { days { date } } } }`, wantDepth: 4, wantComplexity: 1 + 1 + 5*2},
+		{name: "default forecast days", query: `{ location(cep: "29902555") { forecast { city } } }`, wantDepth: 3, wantComplexity: 1 + 1 + 3},
+		{name: "literal ceps", query: `{ locations(ceps: ["1", "2", "3"]) { cep city } }`, wantDepth: 2, wantComplexity: 1 + 3*2},
+		{name: "variable ceps", query: `query($ceps: [String!]!) { locations(ceps: $ceps) { cep } }`, variables: map[string]any{"ceps": []any{"1", "2"}}, wantDepth: 2, wantComplexity: 1 + 2},
+		{name: "variable days", query: `query($days: Int) { location(cep: "1") { forecast(days: $days) { city } } }`, variables: map[string]any{"days": 7.0}, wantDepth: 3, wantComplexity: 1 + 1 + 7},
+		{name: "fragments", query: `{ location(cep: "1") { ...place ... on Location { tempC } } } fragment place on Location { city address { city } }`, wantDepth: 3, wantComplexity: 1 + 4},
+		{name: "introspection", query: `{ __schema { types { name fields { name } } } location(cep: "1") { __typename city } }`, wantDepth: 2, wantComplexity: 2},
+		{name: "named operation", query: `query A { location(cep: "1") { city } } query B { location(cep: "1") { address { city } } }`, operation: "B", wantDepth: 3, wantComplexity: 3},
+		{name: "unknown operation", query: `query A { location(cep: "1") { city } }`, operation: "C"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			doc, err := parser.Parse(parser.ParseParams{Source: tt.query})
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+
+			depth, complexity := queryCost(doc, tt.operation, tt.variables)
+			if depth != tt.wantDepth || complexity != tt.wantComplexity {
+				t.Errorf("expected depth %d and complexity %d but got %d and %d", tt.wantDepth, tt.wantComplexity, depth, complexity)
+			}
+		})
+	}
+}
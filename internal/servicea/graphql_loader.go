@@ -0,0 +1,298 @@
+package servicea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// graphQLUnits are the scales asked to service-b; the query picks among them.
+const graphQLUnits = "c,f,k,r"
+
+// loaded is the outcome of the lookup of one key.
+type loaded[V any] struct {
+	value V
+	err   error
+}
+
+// loader batches the lookups of the keys asked while a query runs: load
+// queues a key and returns a thunk, and the first thunk called fetches
+// every key queued so far with one call of fetch. graphql-go calls the
+// thunks only once every field of a level was resolved, so the keys of
+// sibling fields end up in the same fetch.
+type loader[K comparable, V any] struct {
+	fetch func(keys []K) map[K]loaded[V]
+
+	mu      sync.Mutex
+	queued  []K
+	results map[K]loaded[V]
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) map[K]loaded[V]) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, results: make(map[K]loaded[V])}
+}
+
+func (l *loader[K, V]) load(key K) func() (V, error) {
+
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok && !slices.Contains(l.queued, key) {
+		l.queued = append(l.queued, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, ok := l.results[key]; !ok && len(l.queued) > 0 {
+			keys := l.queued
+			l.queued = nil
+			for k, r := range l.fetch(keys) {
+				l.results[k] = r
+			}
+		}
+
+		r, ok := l.results[key]
+		if !ok {
+			var zero V
+			return zero, &fieldError{code: i18n.CodeInternalError, status: http.StatusInternalServerError, message: "no result for key"}
+		}
+
+		return r.value, r.err
+	}
+}
+
+// fieldError is the error of a field, reported with its code and HTTP
+// status in the extensions of the GraphQL error.
+type fieldError struct {
+	code    i18n.Code
+	status  int
+	message string
+}
+
+func newFieldError(ctx context.Context, status int, code i18n.Code, args ...any) *fieldError {
+	return &fieldError{code: code, status: status, message: i18n.Message(i18n.FromContext(ctx), code, args...)}
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *fieldError) Extensions() map[string]any {
+	return map[string]any{"code": e.code, "status": e.status}
+}
+
+// forecastKey identifies a forecast asked by a query.
+type forecastKey struct {
+	cep  string
+	days int
+}
+
+// gqlQuery is the state of one GraphQL request: the loaders batching its
+// calls to service-b and the optional fields its locations select.
+type gqlQuery struct {
+	ctx    context.Context
+	server *Server
+
+	mu     sync.Mutex
+	fields []string
+
+	locations *loader[string, *domain.LocationView]
+	forecasts *loader[forecastKey, *domain.ForecastView]
+}
+
+func newGQLQuery(ctx context.Context, s *Server) *gqlQuery {
+
+	q := &gqlQuery{ctx: ctx, server: s}
+	q.locations = newLoader(q.fetchLocations)
+	q.forecasts = newLoader(q.fetchForecasts)
+
+	return q
+}
+
+type gqlQueryKey struct{}
+
+func withGQLQuery(ctx context.Context, q *gqlQuery) context.Context {
+	return context.WithValue(ctx, gqlQueryKey{}, q)
+}
+
+func gqlQueryFrom(ctx context.Context) *gqlQuery {
+	q, _ := ctx.Value(gqlQueryKey{}).(*gqlQuery)
+	return q
+}
+
+// selectFields adds the optional fields of service-b, as in ?fields=, that
+// are needed to answer names.
+func (q *gqlQuery) selectFields(names []string) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, n := range names {
+
+		var field string
+		switch n {
+		case "address":
+			field = "address"
+		case "lat", "lon":
+			field = "coordinates"
+		case "current":
+			field = "current"
+		case "airQuality":
+			field = "air_quality"
+		case "alerts":
+			field = "alerts"
+		default:
+			continue
+		}
+
+		if !slices.Contains(q.fields, field) {
+			q.fields = append(q.fields, field)
+		}
+	}
+}
+
+// fetchLocations looks every CEP up with one POST /v1/weather:batch.
+func (q *gqlQuery) fetchLocations(ceps []string) map[string]loaded[*domain.LocationView] {
+
+	ctx, span := tracer.Start(q.ctx, "graphql-batch")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("batch.size", len(ceps)))
+
+	params := url.Values{"units": {graphQLUnits}}
+	q.mu.Lock()
+	if len(q.fields) > 0 {
+		params.Set("fields", strings.Join(q.fields, ","))
+	}
+	q.mu.Unlock()
+
+	body, err := json.Marshal(struct {
+		CEPs []string `json:"ceps"`
+	}{ceps})
+	if err != nil {
+		log.Println("error marshaling data:", err)
+	}
+
+	var data struct {
+		Results []batchItem `json:"results"`
+	}
+	if err == nil {
+		err = q.server.callServiceB(ctx, http.MethodPost, "/v1/weather:batch?"+params.Encode(), body, &data)
+	}
+	if err == nil && len(data.Results) != len(ceps) {
+		log.Println("service-b answered", len(data.Results), "results for", len(ceps), "ceps")
+		err = newFieldError(ctx, http.StatusInternalServerError, i18n.CodeInternalError)
+	}
+
+	results := make(map[string]loaded[*domain.LocationView], len(ceps))
+	for i, cep := range ceps {
+
+		if err != nil {
+			results[cep] = loaded[*domain.LocationView]{err: err}
+			continue
+		}
+
+		item := data.Results[i]
+		if item.Status != http.StatusOK {
+			results[cep] = loaded[*domain.LocationView]{err: &fieldError{code: i18n.Code(item.Code), status: item.Status, message: item.Message}}
+			continue
+		}
+		results[cep] = loaded[*domain.LocationView]{value: item.Location}
+	}
+
+	return results
+}
+
+// fetchForecasts asks service-b, which has no batch route for them, for
+// every forecast at once.
+func (q *gqlQuery) fetchForecasts(keys []forecastKey) map[forecastKey]loaded[*domain.ForecastView] {
+
+	ctx, span := tracer.Start(q.ctx, "graphql-forecast-batch")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("batch.size", len(keys)))
+
+	outcomes := make([]loaded[*domain.ForecastView], len(keys))
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			params := url.Values{"days": {strconv.Itoa(key.days)}, "units": {graphQLUnits}}
+
+			var view domain.ForecastView
+			err := q.server.callServiceB(ctx, http.MethodGet, "/v1/forecast/"+url.PathEscape(key.cep)+"?"+params.Encode(), nil, &view)
+			if err != nil {
+				outcomes[i] = loaded[*domain.ForecastView]{err: err}
+				return
+			}
+			outcomes[i] = loaded[*domain.ForecastView]{value: &view}
+		}()
+	}
+	wg.Wait()
+
+	results := make(map[forecastKey]loaded[*domain.ForecastView], len(keys))
+	for i, key := range keys {
+		results[key] = outcomes[i]
+	}
+
+	return results
+}
+
+// callServiceB sends method and path with body to service-b and decodes the
+// JSON reply into out. Problems answered by service-b are returned as a
+// *fieldError with their code and detail.
+func (s *Server) callServiceB(ctx context.Context, method, path string, body []byte, out any) error {
+
+	req, err := http.NewRequestWithContext(ctx, method, s.serviceBURL+path, bytes.NewReader(body))
+	if err != nil {
+		log.Println("error creating request:", err)
+		return newFieldError(ctx, http.StatusInternalServerError, i18n.CodeInternalError)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept-Language", string(i18n.FromContext(ctx)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Println("error making request to service b:", err)
+		return newFieldError(ctx, http.StatusInternalServerError, i18n.CodeInternalError)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+
+		log.Println("service-b returned non-OK status:", resp.Status)
+
+		var p problem.Problem
+		if err := json.NewDecoder(resp.Body).Decode(&p); err != nil || p.Code == "" {
+			return newFieldError(ctx, http.StatusInternalServerError, i18n.CodeInternalError)
+		}
+		return &fieldError{code: i18n.Code(p.Code), status: resp.StatusCode, message: p.Detail}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		log.Println("error to decode service-b response:", err)
+		return newFieldError(ctx, http.StatusInternalServerError, i18n.CodeInternalError)
+	}
+
+	return nil
+}
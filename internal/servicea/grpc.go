@@ -0,0 +1,350 @@
+package servicea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+
+	weatherv1 "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/api/weather/v1"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/mtls"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultServiceBGRPCAddr is where the gRPC API of service-b is reached
+// inside docker-compose.
+const DefaultServiceBGRPCAddr = "service-b:9090"
+
+// Transports to service-b, selected with SERVICE_B_TRANSPORT.
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// serviceBGRPC returns a client of the gRPC API of service-b at
+// SERVICE_B_GRPC_ADDR, secured and signed like the HTTP calls. The
+// connection is made on the first call.
+func serviceBGRPC() (weatherv1.WeatherServiceClient, error) {
+
+	addr := os.Getenv("SERVICE_B_GRPC_ADDR")
+	if addr == "" {
+		addr = DefaultServiceBGRPCAddr
+	}
+
+	creds := insecure.NewCredentials()
+	if files := serviceBTLS(); files != (mtls.Files{}) {
+		certs := mtls.NewReloader(files)
+		if err := certs.Load(); err != nil {
+			// Handshakes keep failing until the files are fixed.
+			log.Println("error to load service-b tls certificates:", err)
+		}
+		creds = credentials.NewTLS(certs.ClientConfig())
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+	if key := signature.KeyFromEnv(); key != nil {
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(signature.UnaryClientInterceptor(key)),
+			grpc.WithChainStreamInterceptor(signature.StreamClientInterceptor(key)),
+		)
+	}
+
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return weatherv1.NewWeatherServiceClient(conn), nil
+}
+
+// outgoing passes the language of the request on to service-b.
+func outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "accept-language", string(i18n.FromContext(ctx)))
+}
+
+// grpcStatuses are the HTTP statuses of the error codes whose status is
+// not implied by their gRPC code.
+var grpcStatuses = map[i18n.Code]int{
+	i18n.CodeInvalidZipcode:  http.StatusUnprocessableEntity,
+	i18n.CodeTooManyZipcodes: http.StatusRequestEntityTooLarge,
+}
+
+// httpStatuses maps the gRPC codes answered by service-b to HTTP statuses.
+var httpStatuses = map[codes.Code]int{
+	codes.InvalidArgument: http.StatusBadRequest,
+	codes.NotFound:        http.StatusNotFound,
+	codes.Unavailable:     http.StatusServiceUnavailable,
+	codes.Unauthenticated: http.StatusUnauthorized,
+}
+
+// replyGRPCError answers the problem of an error of a gRPC call. Errors
+// answered by service-b keep their code and localized message; failures to
+// reach it are internal errors, like on HTTP.
+func replyGRPCError(w http.ResponseWriter, r *http.Request, err error) {
+
+	log.Println("error making grpc request to service b:", err)
+
+	st := status.Convert(err)
+
+	var info *errdetails.ErrorInfo
+	for _, d := range st.Details() {
+		if i, ok := d.(*errdetails.ErrorInfo); ok {
+			info = i
+		}
+	}
+	if info == nil {
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	code := i18n.Code(info.Reason)
+	httpStatus, ok := grpcStatuses[code]
+	if !ok {
+		httpStatus, ok = httpStatuses[st.Code()]
+	}
+	if !ok {
+		httpStatus = http.StatusInternalServerError
+	}
+
+	p := problem.New(r.Context(), httpStatus, code)
+	p.Detail = st.Message()
+	p.Instance = r.URL.Path
+	problem.Reply(w, p)
+}
+
+func replyJSON(w http.ResponseWriter, r *http.Request, data any) {
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
+
+// grpcViewOptions reads the options of r the gRPC messages can render: the
+// units and the coordinates field, along with the query parameters in
+// others. It reports false when r has any other option, or an invalid one,
+// as those are only handled, and rejected, over HTTP.
+func grpcViewOptions(r *http.Request, others ...string) (domain.ViewOptions, bool) {
+
+	query := r.URL.Query()
+	for key := range query {
+		if key != "units" && key != "fields" && !slices.Contains(others, key) {
+			return domain.ViewOptions{}, false
+		}
+	}
+
+	units, err := domain.ParseUnits(query.Get("units"))
+	if err != nil {
+		return domain.ViewOptions{}, false
+	}
+
+	fields, err := domain.ParseFields(query.Get("fields"))
+	if err != nil || fields != (domain.Fields{Coordinates: fields.Coordinates}) {
+		return domain.ViewOptions{}, false
+	}
+
+	return domain.ViewOptions{Units: units, Fields: fields}, true
+}
+
+// temperature returns v when the scale is selected, like the views of
+// service-b omit the others.
+func temperature(selected bool, v float64) *float64 {
+
+	if !selected {
+		return nil
+	}
+
+	return &v
+}
+
+// locationView renders a Weather of service-b like its HTTP response with
+// the units and fields of opts.
+func locationView(weather *weatherv1.Weather, opts domain.ViewOptions) *domain.LocationView {
+
+	u := opts.Units
+	view := &domain.LocationView{
+		CEP:   weather.GetCep(),
+		City:  weather.GetCity(),
+		TempC: temperature(u.C, weather.GetTempC()),
+		TempF: temperature(u.F, weather.GetTempF()),
+		TempK: temperature(u.K, weather.GetTempK()),
+		TempR: temperature(u.R, weather.GetTempR()),
+	}
+	if opts.Fields.Coordinates {
+		view.Lat, view.Lon = weather.Lat, weather.Lon
+	}
+
+	return view
+}
+
+// indexGRPC answers POST / with GetWeatherByCEP.
+func (s *Server) indexGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request, cep string) {
+
+	resp, err := s.weather.GetWeatherByCEP(outgoing(ctx), &weatherv1.GetWeatherByCEPRequest{Cep: cep})
+	if err != nil {
+		replyGRPCError(w, r, err)
+		return
+	}
+
+	view := locationView(resp.GetWeather(), domain.ViewOptions{Units: domain.AllUnits})
+	replyJSON(w, r, indexResponse{City: view.City, TempC: *view.TempC, TempF: *view.TempF, TempK: *view.TempK})
+}
+
+// forwardGRPC answers GET /v1/weather/{cep} with GetWeatherByCEP and
+// GET /v1/forecast/{cep} with StreamForecast, reporting false for the
+// routes and options only served over HTTP.
+func (s *Server) forwardGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+
+	cep := r.PathValue("cep")
+
+	switch r.URL.Path {
+	case "/v1/weather/" + cep:
+
+		opts, ok := grpcViewOptions(r)
+		if !ok {
+			return false
+		}
+
+		resp, err := s.weather.GetWeatherByCEP(outgoing(ctx), &weatherv1.GetWeatherByCEPRequest{Cep: cep})
+		if err != nil {
+			replyGRPCError(w, r, err)
+			return true
+		}
+
+		replyJSON(w, r, locationView(resp.GetWeather(), opts))
+		return true
+
+	case "/v1/forecast/" + cep:
+
+		opts, ok := grpcViewOptions(r, "days")
+		if !ok || opts.Fields != (domain.Fields{}) {
+			return false
+		}
+
+		s.forecastGRPC(ctx, w, r, cep, opts)
+		return true
+	}
+
+	return false
+}
+
+// forecastGRPC collects the days sent by StreamForecast into the response
+// of GET /v1/forecast/{cep}, with the units of opts.
+func (s *Server) forecastGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request, cep string, opts domain.ViewOptions) {
+
+	days, err := domain.ParseForecastDays(r.URL.Query().Get("days"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidDays)
+		return
+	}
+
+	stream, err := s.weather.StreamForecast(outgoing(ctx), &weatherv1.StreamForecastRequest{Cep: cep, Days: int32(days)})
+	if err != nil {
+		replyGRPCError(w, r, err)
+		return
+	}
+
+	u := opts.Units
+	view := domain.ForecastView{CEP: cep, Days: []domain.ForecastDayView{}}
+	for {
+		d, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			replyGRPCError(w, r, err)
+			return
+		}
+
+		view.CEP, view.City = d.GetCep(), d.GetCity()
+		view.Days = append(view.Days, domain.ForecastDayView{
+			Date:         d.GetDate(),
+			MinC:         temperature(u.C, d.GetMinC()),
+			MinF:         temperature(u.F, d.GetMinF()),
+			MinK:         temperature(u.K, d.GetMinK()),
+			MinR:         temperature(u.R, d.GetMinR()),
+			MaxC:         temperature(u.C, d.GetMaxC()),
+			MaxF:         temperature(u.F, d.GetMaxF()),
+			MaxK:         temperature(u.K, d.GetMaxK()),
+			MaxR:         temperature(u.R, d.GetMaxR()),
+			AvgC:         temperature(u.C, d.GetAvgC()),
+			AvgF:         temperature(u.F, d.GetAvgF()),
+			AvgK:         temperature(u.K, d.GetAvgK()),
+			AvgR:         temperature(u.R, d.GetAvgR()),
+			ChanceOfRain: int(d.GetChanceOfRain()),
+			Condition:    d.GetCondition(),
+		})
+	}
+
+	replyJSON(w, r, view)
+}
+
+// batchItem is a result of POST /v1/weather:batch, as answered by service-b.
+type batchItem struct {
+	CEP      string               `json:"cep"`
+	Status   int                  `json:"status"`
+	Location *domain.LocationView `json:"location,omitempty"`
+	Message  string               `json:"message,omitempty"`
+	Code     string               `json:"code,omitempty"`
+}
+
+// batchGRPC answers POST /v1/weather:batch with BatchGetWeather, rendering
+// the locations with opts.
+func (s *Server) batchGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request, ceps []string, opts domain.ViewOptions) {
+
+	resp, err := s.weather.BatchGetWeather(outgoing(ctx), &weatherv1.BatchGetWeatherRequest{Ceps: ceps})
+	if err != nil {
+		replyGRPCError(w, r, err)
+		return
+	}
+
+	results := make([]batchItem, len(resp.GetResults()))
+	for i, res := range resp.GetResults() {
+		results[i] = batchItem{CEP: res.GetCep(), Status: int(res.GetStatus()), Message: res.GetMessage(), Code: res.GetCode()}
+		if res.GetWeather() != nil {
+			results[i].Location = locationView(res.GetWeather(), opts)
+		}
+	}
+
+	replyJSON(w, r, struct {
+		Results []batchItem `json:"results"`
+	}{results})
+}
+
+// transportFromEnv reads SERVICE_B_TRANSPORT, falling back to HTTP.
+func transportFromEnv() string {
+
+	switch v := os.Getenv("SERVICE_B_TRANSPORT"); v {
+	case "", TransportHTTP:
+		return TransportHTTP
+	case TransportGRPC:
+		return TransportGRPC
+	default:
+		log.Println("invalid SERVICE_B_TRANSPORT, using http:", strconv.Quote(v))
+		return TransportHTTP
+	}
+}
@@ -0,0 +1,416 @@
+// Package servicea implements service-a, the public entry point that
+// validates the CEP input and forwards it to service-b.
+package servicea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	weatherv1 "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/api/weather/v1"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/httpclient"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/mtls"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/ratelimit"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultServiceBURL is where service-b is reached inside docker-compose.
+const DefaultServiceBURL = "http://service-b:8080"
+
+const name = "service-a"
+
+var (
+	tracer = otel.Tracer(name)
+	meter  = otel.Meter(name)
+	logger = otelslog.NewLogger(name)
+)
+
+// Server forwards validated CEPs to service-b.
+type Server struct {
+	serviceBURL string
+	client      *http.Client
+	keys        *apikey.Keyring
+	// weather calls the gRPC API of service-b instead of client, for the
+	// routes it serves. Nil with the HTTP transport.
+	weather weatherv1.WeatherServiceClient
+	// graphQLLimits caps the queries answered by /graphql.
+	graphQLLimits queryLimits
+	// streams counts the open streams of every client.
+	streams *streamLimiter
+}
+
+// NewServer returns a Server talking to service-b at serviceBURL. An empty
+// serviceBURL falls back to the SERVICE_B_URL environment variable and then
+// to DefaultServiceBURL. With SERVICE_B_TRANSPORT=grpc, the lookups the
+// gRPC API serves go through it instead. The API keys are read from the
+// environment; if they can not be read, every request is refused.
+func NewServer(serviceBURL string) *Server {
+
+	if serviceBURL == "" {
+		serviceBURL = os.Getenv("SERVICE_B_URL")
+	}
+	if serviceBURL == "" {
+		serviceBURL = DefaultServiceBURL
+	}
+
+	keys, err := apikey.FromEnv()
+	if err != nil {
+		log.Println("error to load api keys, refusing every request:", err)
+		keys, _ = apikey.NewKeyring(strings.NewReader(""))
+	}
+	if !keys.Enabled() {
+		log.Println("no API_KEYS_FILE or API_KEYS, service-a is open to any client")
+	}
+
+	s := &Server{
+		serviceBURL:   serviceBURL,
+		client:        httpclient.New(serviceBClient()),
+		keys:          keys,
+		graphQLLimits: queryLimitsFromEnv(),
+		streams:       streamLimiterFromEnv(),
+	}
+
+	if transportFromEnv() == TransportGRPC {
+		s.weather, err = serviceBGRPC()
+		if err != nil {
+			log.Println("error to create service-b grpc client, using http:", err)
+		}
+	}
+
+	return s
+}
+
+// serviceBTLS reads the certificates of the calls to service-b from
+// SERVICE_B_TLS_CERT_FILE, SERVICE_B_TLS_KEY_FILE and SERVICE_B_TLS_CA_FILE.
+func serviceBTLS() mtls.Files {
+	return mtls.Files{
+		Cert: os.Getenv("SERVICE_B_TLS_CERT_FILE"),
+		Key:  os.Getenv("SERVICE_B_TLS_KEY_FILE"),
+		CA:   os.Getenv("SERVICE_B_TLS_CA_FILE"),
+	}
+}
+
+// serviceBClient returns the configuration of the calls to service-b: over
+// mutual TLS with the certificates of SERVICE_B_TLS_CERT_FILE,
+// SERVICE_B_TLS_KEY_FILE and SERVICE_B_TLS_CA_FILE, and signed with
+// REQUEST_SIGNING_KEY, when set.
+func serviceBClient() httpclient.Config {
+
+	cfg := httpclient.Config{SigningKey: signature.KeyFromEnv()}
+
+	if files := serviceBTLS(); files != (mtls.Files{}) {
+
+		certs := mtls.NewReloader(files)
+		if err := certs.Load(); err != nil {
+			// Handshakes keep failing until the files are fixed.
+			log.Println("error to load service-b tls certificates:", err)
+		}
+
+		cfg.TLS = certs.ClientConfig()
+	}
+
+	return cfg
+}
+
+// Handler returns the routes served by service-a behind the middleware
+// chain configured from the environment.
+func (s *Server) Handler() http.Handler {
+	return s.routes(webserver.New(webserver.ConfigFromEnv(name))).Handler()
+}
+
+// routes registers the routes served by service-a on ws, each one requiring
+// the scope of its operation, behind the per-client limit configured by the
+// environment and the API key authentication. The limit comes first so that
+// the requests refused by the authentication are throttled too, by IP.
+func (s *Server) routes(ws *webserver.Server) *webserver.Server {
+
+	limits := ratelimit.ConfigFromEnv()
+	limits.Identify = s.keys.Identify
+
+	ws.Use(ratelimit.New(limits).Handler, s.keys.Authenticate)
+	ws.HandleFunc("POST /{$}", s.keys.Require(apikey.ScopeLookup, s.handlerIndex))
+	ws.HandleFunc("GET /v1/weather/{cep}", s.keys.Require(apikey.ScopeLookup, s.handlerForward))
+	ws.HandleFunc("GET /v1/weather/{cep}/history", s.keys.Require(apikey.ScopeLookup, s.handlerForward))
+	ws.HandleFunc("GET /v1/cep/{cep}", s.keys.Require(apikey.ScopeLookup, s.handlerForward))
+	ws.HandleFunc("GET /v1/forecast/{cep}", s.keys.Require(apikey.ScopeForecast, s.handlerForward))
+	ws.HandleFunc("POST /v1/weather:batch", s.keys.Require(apikey.ScopeBatch, s.handlerBatch))
+	ws.HandleFunc("GET /graphql", s.keys.Require(apikey.ScopeLookup, s.handlerGraphQL))
+	ws.HandleFunc("POST /graphql", s.keys.Require(apikey.ScopeLookup, s.handlerGraphQL))
+	ws.HandleStream("GET /v1/weather/{cep}/stream", s.keys.Require(apikey.ScopeLookup, s.handlerStream))
+	return ws
+}
+
+// indexResponse is the body answered by POST /.
+type indexResponse struct {
+	City    string                 `json:"city"`
+	TempC   float64                `json:"temp_c"`
+	TempF   float64                `json:"temp_f"`
+	TempK   float64                `json:"temp_k"`
+	Lat     *float64               `json:"lat,omitempty"`
+	Lon     *float64               `json:"lon,omitempty"`
+	Address *domain.Address        `json:"address,omitempty"`
+	Current *domain.ConditionsView `json:"current,omitempty"`
+
+	AirQuality *domain.AirQualityView `json:"air_quality,omitempty"`
+	Alerts     []domain.Alert         `json:"alerts,omitempty"`
+}
+
+func (s *Server) handlerIndex(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := tracer.Start(r.Context(), "check-cep")
+	defer span.End()
+	// Problem replies read the trace ID from the request context.
+	r = r.WithContext(ctx)
+
+	span.SetAttributes(attribute.String("service.name", "service-a"))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var data struct {
+		CEP string `json:"cep"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeNoZipcode)
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", data.CEP))
+
+	l, err := domain.NewLocation(data.CEP)
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	err = l.Validate()
+	if err != nil {
+
+		log.Println("invalid zipcode", l)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	log.Println("start request to service b passing cep:", l.GetCEP())
+
+	// Options such as ?fields=address are only handled over HTTP.
+	if s.weather != nil && r.URL.RawQuery == "" {
+		span.SetAttributes(attribute.String("service_b.transport", TransportGRPC))
+		s.indexGRPC(ctx, w, r, data.CEP)
+		return
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+
+		log.Println("error marshaling data:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	// Options such as ?fields=address are handled by service-b.
+	url := s.serviceBURL
+	if r.URL.RawQuery != "" {
+		url += "/?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Println("error creating request:", err)
+
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	req.Header.Set("Accept-Language", string(i18n.FromContext(ctx)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Println("error making request to service b:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+
+		log.Println("service-b returned non-OK status:", resp.Status)
+
+		if resp.StatusCode == 404 {
+			problem.Write(w, r, resp.StatusCode, i18n.CodeZipcodeNotFound)
+		} else if resp.StatusCode == 422 {
+			problem.Write(w, r, resp.StatusCode, i18n.CodeInvalidZipcode)
+		} else if resp.StatusCode == 503 {
+			problem.Write(w, r, resp.StatusCode, i18n.CodeUpstreamBudgetExhausted)
+		} else {
+			problem.Write(w, r, resp.StatusCode, i18n.CodeBadRequest)
+		}
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+
+		log.Println("error to io.ReadAll resp.Body")
+
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	var responseData indexResponse
+
+	err = json.Unmarshal(body, &responseData)
+	if err != nil {
+		log.Println("error to unMarshall resp.Body")
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	byteResponseData, err := json.Marshal(responseData)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(byteResponseData)
+}
+
+// handlerForward validates the CEP in the path and relays the request,
+// including its query string, to the same route on service-b.
+func (s *Server) handlerForward(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := tracer.Start(r.Context(), "check-cep")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	span.SetAttributes(
+		attribute.String("service.name", "service-a"),
+		attribute.String("http.target", r.URL.Path),
+	)
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	l, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	log.Println("start request to service b passing cep:", l.GetCEP())
+
+	if s.weather != nil && s.forwardGRPC(ctx, w, r) {
+		span.SetAttributes(attribute.String("service_b.transport", TransportGRPC))
+		return
+	}
+
+	s.relay(ctx, w, r, nil)
+}
+
+// handlerBatch checks that the batch body is well formed and relays it to
+// service-b, which validates and resolves every CEP.
+func (s *Server) handlerBatch(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := tracer.Start(r.Context(), "check-cep-batch")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	span.SetAttributes(attribute.String("service.name", "service-a"))
+
+	var data struct {
+		CEPs []string `json:"ceps"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil || len(data.CEPs) == 0 {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeNoZipcode)
+		return
+	}
+
+	span.SetAttributes(attribute.Int("batch.size", len(data.CEPs)))
+	webserver.AccessLogAttrs(ctx, slog.Int("batch.size", len(data.CEPs)))
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		log.Println("error marshaling data:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	log.Println("start batch request to service b passing ceps:", len(data.CEPs))
+
+	if opts, ok := grpcViewOptions(r); s.weather != nil && ok {
+		span.SetAttributes(attribute.String("service_b.transport", TransportGRPC))
+		s.batchGRPC(ctx, w, r, data.CEPs, opts)
+		return
+	}
+
+	s.relay(ctx, w, r, jsonData)
+}
+
+// relay sends r's method, path and query string to service-b and copies the
+// reply back to w.
+func (s *Server) relay(ctx context.Context, w http.ResponseWriter, r *http.Request, body []byte) {
+
+	url := s.serviceBURL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, bytes.NewReader(body))
+	if err != nil {
+		log.Println("error creating request:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept-Language", string(i18n.FromContext(ctx)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Println("error making request to service b:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Println("service-b returned non-OK status:", resp.Status)
+	}
+
+	// Errors come as problem details, so keep service-b's media type.
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// StartCepCollector serves the routes on the port configured by the
+// environment until the listener fails.
+func StartCepCollector() error {
+	return NewServer("").routes(webserver.New(webserver.ConfigFromEnv(name))).ListenAndServe()
+}
@@ -0,0 +1,206 @@
+package servicea
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultStreamMaxPerClient is how many streams a client may keep open at
+// once, overridable with the STREAM_MAX_PER_CLIENT environment variable.
+const DefaultStreamMaxPerClient = 5
+
+// streamRetryAfter is the Retry-After, in seconds, of the streams refused to
+// a client with too many of them open.
+const streamRetryAfter = "5"
+
+// streamLimiter counts the open streams of every client.
+type streamLimiter struct {
+	limit int
+
+	mu   sync.Mutex
+	open map[string]int
+}
+
+// streamLimiterFromEnv reads the limit from STREAM_MAX_PER_CLIENT, keeping
+// the default for invalid values.
+func streamLimiterFromEnv() *streamLimiter {
+
+	l := &streamLimiter{limit: DefaultStreamMaxPerClient, open: make(map[string]int)}
+
+	if v := os.Getenv("STREAM_MAX_PER_CLIENT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Println("invalid STREAM_MAX_PER_CLIENT, using default:", v)
+		} else {
+			l.limit = n
+		}
+	}
+
+	return l
+}
+
+// acquire counts a new stream of client, unless it already has the limit
+// open.
+func (l *streamLimiter) acquire(client string) bool {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.open[client] >= l.limit {
+		return false
+	}
+	l.open[client]++
+
+	return true
+}
+
+// release forgets a stream of client.
+func (l *streamLimiter) release(client string) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.open[client]--
+	if l.open[client] <= 0 {
+		delete(l.open, client)
+	}
+}
+
+// streamClient identifies the client of r like the rate limit does: by its
+// API key, otherwise by its IP.
+func streamClient(r *http.Request) string {
+
+	if key, ok := apikey.FromContext(r.Context()); ok {
+		return "key:" + key.ID
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return "ip:" + ip
+}
+
+// handlerStream validates the CEP in the path and relays the Server-Sent
+// Events of the same route on service-b for as long as the client listens,
+// within the limit of open streams per client.
+func (s *Server) handlerStream(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := tracer.Start(r.Context(), "check-cep-stream")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	span.SetAttributes(
+		attribute.String("service.name", "service-a"),
+		attribute.String("http.target", r.URL.Path),
+	)
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	_, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	client := streamClient(r)
+	if !s.streams.acquire(client) {
+		w.Header().Set("Retry-After", streamRetryAfter)
+		problem.Write(w, r, http.StatusTooManyRequests, i18n.CodeTooManyStreams, s.streams.limit)
+		return
+	}
+	defer s.streams.release(client)
+
+	s.relayStream(ctx, w, r)
+}
+
+// relayStream sends r's path and query string to service-b, along with the
+// Last-Event-ID of a reconnecting client, and copies every event of the
+// reply to w as soon as it arrives.
+func (s *Server) relayStream(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+
+	url := s.serviceBURL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		log.Println("error creating request:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Accept-Language", string(i18n.FromContext(ctx)))
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		req.Header.Set("Last-Event-ID", id)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Println("error making request to service b:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+
+		log.Println("service-b returned non-OK status:", resp.Status)
+
+		contentType := resp.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	_ = rc.Flush()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			_ = rc.Flush()
+		}
+		if err != nil {
+			// The client left, cancelling ctx, or service-b ended the
+			// stream; either way the client reconnects if still there.
+			return
+		}
+	}
+}
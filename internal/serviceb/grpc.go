@@ -0,0 +1,346 @@
+package serviceb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+
+	weatherv1 "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/api/weather/v1"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/mtls"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/recovery"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGRPCPort is the port of the gRPC API, overridable with the
+// GRPC_PORT environment variable.
+const DefaultGRPCPort = "9090"
+
+// GRPCServer returns the gRPC API of service-b: the WeatherService along
+// with the health and reflection services. Calls are traced, localized from
+// their accept-language metadata and recovered from panics and, with
+// REQUEST_SIGNING_KEY set, only those signed by service-a are served. A nil
+// creds serves plain HTTP/2.
+func (s *Server) GRPCServer(creds credentials.TransportCredentials) *grpc.Server {
+
+	unary := []grpc.UnaryServerInterceptor{localizeUnary, recoverUnary}
+	stream := []grpc.StreamServerInterceptor{localizeStream, recoverStream}
+	if key := signature.KeyFromEnv(); key != nil {
+		v := signature.NewVerifier(key)
+		unary = append(unary, v.UnaryServerInterceptor())
+		stream = append(stream, v.StreamServerInterceptor())
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	srv := grpc.NewServer(opts...)
+	weatherv1.RegisterWeatherServiceServer(srv, &grpcService{s: s})
+
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus(weatherv1.WeatherService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+
+	reflection.Register(srv)
+
+	return srv
+}
+
+// ServeGRPC serves GRPCServer on port until the listener fails, over TLS
+// with files when they have a certificate, like the HTTP listener.
+func (s *Server) ServeGRPC(port string, files mtls.Files) error {
+
+	var creds credentials.TransportCredentials
+	if files.Cert != "" {
+		certs := mtls.NewReloader(files)
+		if err := certs.Load(); err != nil {
+			return fmt.Errorf("error to load grpc tls certificates: %w", err)
+		}
+		creds = credentials.NewTLS(certs.ServerConfig())
+	}
+
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("error to listen grpc port: %w", err)
+	}
+
+	log.Println("Start", name, "grpc listen in port:", port, "tls:", creds != nil)
+	if err := s.GRPCServer(creds).Serve(lis); err != nil {
+		return fmt.Errorf("error to start grpc server: %w", err)
+	}
+
+	return nil
+}
+
+// grpcPort reads GRPC_PORT, falling back to DefaultGRPCPort.
+func grpcPort() string {
+
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		return port
+	}
+
+	return DefaultGRPCPort
+}
+
+// localize sets the language negotiated from the accept-language metadata
+// on ctx, like i18n.Handler does for HTTP requests.
+func localize(ctx context.Context) context.Context {
+
+	var acceptLanguage string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("accept-language"); len(values) > 0 {
+			acceptLanguage = values[0]
+		}
+	}
+
+	return i18n.WithLang(ctx, i18n.Negotiate(acceptLanguage))
+}
+
+func localizeUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(localize(ctx), req)
+}
+
+func localizeStream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: localize(ss.Context())})
+}
+
+// contextStream replaces the context of a ServerStream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (cs *contextStream) Context() context.Context {
+	return cs.ctx
+}
+
+// recoverUnary turns a panicking call into an Internal status, reporting
+// the panic like webserver.Recover does.
+func recoverUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+
+	defer func() {
+		if v := recover(); v != nil {
+			recovery.Record(ctx, v, debug.Stack())
+			resp, err = nil, statusError(ctx, http.StatusInternalServerError, i18n.CodeInternalError)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func recoverStream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+
+	defer func() {
+		if v := recover(); v != nil {
+			recovery.Record(ss.Context(), v, debug.Stack())
+			err = statusError(ss.Context(), http.StatusInternalServerError, i18n.CodeInternalError)
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+// grpcCodes maps the HTTP statuses answered by service-b to gRPC codes.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+}
+
+// statusError returns the gRPC status of the HTTP status and error code
+// answered to the client: the localized message with the code in an
+// ErrorInfo detail.
+func statusError(ctx context.Context, httpStatus int, code i18n.Code, args ...any) error {
+
+	c, ok := grpcCodes[httpStatus]
+	if !ok {
+		c = codes.Internal
+	}
+
+	st := status.New(c, i18n.Message(i18n.FromContext(ctx), code, args...))
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(code), Domain: name}); err == nil {
+		st = withInfo
+	}
+
+	return st.Err()
+}
+
+// grpcService implements the WeatherService on the LocationService of a
+// Server.
+type grpcService struct {
+	weatherv1.UnimplementedWeatherServiceServer
+	s *Server
+}
+
+// viewOptions are the options of the default HTTP responses, in every scale
+// so that clients can pick the units of their responses.
+func (g *grpcService) viewOptions() domain.ViewOptions {
+	return domain.ViewOptions{
+		Units:        domain.Units{C: true, F: true, K: true, R: true},
+		Round:        true,
+		Precision:    domain.DefaultPrecision,
+		LegacyKelvin: g.s.legacyKelvin,
+	}
+}
+
+// location validates cep, recording it on the span of the call.
+func (g *grpcService) location(ctx context.Context, cep string) (*domain.Location, error) {
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cep", cep))
+
+	location, err := domain.NewLocation(cep)
+	if err != nil {
+		log.Println(err)
+		return nil, statusError(ctx, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+	}
+
+	return location, nil
+}
+
+func (g *grpcService) GetWeatherByCEP(ctx context.Context, req *weatherv1.GetWeatherByCEPRequest) (*weatherv1.GetWeatherByCEPResponse, error) {
+
+	location, err := g.location(ctx, req.GetCep())
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.s.service.ExecuteWith(ctx, location, domain.Fields{})
+	if err != nil {
+		status, code := statusFor(err)
+		log.Println(code, location)
+		return nil, statusError(ctx, status, code)
+	}
+
+	return &weatherv1.GetWeatherByCEPResponse{Weather: g.weather(location)}, nil
+}
+
+func (g *grpcService) BatchGetWeather(ctx context.Context, req *weatherv1.BatchGetWeatherRequest) (*weatherv1.BatchGetWeatherResponse, error) {
+
+	if len(req.GetCeps()) == 0 {
+		return nil, statusError(ctx, http.StatusBadRequest, i18n.CodeNoZipcode)
+	}
+	if len(req.GetCeps()) > g.s.batchMaxSize {
+		return nil, statusError(ctx, http.StatusRequestEntityTooLarge, i18n.CodeTooManyZipcodes, g.s.batchMaxSize)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("batch.size", len(req.GetCeps())))
+
+	results := g.s.service.ExecuteBatch(ctx, req.GetCeps(), g.s.batchWorkers, domain.Fields{})
+
+	resp := &weatherv1.BatchGetWeatherResponse{Results: make([]*weatherv1.BatchResult, len(results))}
+	for i, res := range results {
+
+		item := &weatherv1.BatchResult{Cep: res.CEP, Status: http.StatusOK}
+		if res.Err != nil {
+			status, code := statusFor(res.Err)
+			item.Status, item.Code = int32(status), string(code)
+			item.Message = i18n.Message(i18n.FromContext(ctx), code)
+		} else {
+			item.Weather = g.weather(res.Location)
+		}
+
+		resp.Results[i] = item
+	}
+
+	return resp, nil
+}
+
+func (g *grpcService) StreamForecast(req *weatherv1.StreamForecastRequest, stream weatherv1.WeatherService_StreamForecastServer) error {
+
+	ctx := stream.Context()
+
+	days := int(req.GetDays())
+	if days == 0 {
+		days = domain.DefaultForecastDays
+	}
+	if _, err := domain.ParseForecastDays(strconv.Itoa(days)); err != nil {
+		return statusError(ctx, http.StatusBadRequest, i18n.CodeInvalidDays)
+	}
+
+	location, err := g.location(ctx, req.GetCep())
+	if err != nil {
+		return err
+	}
+
+	forecast, err := g.s.service.Forecast(ctx, location, days)
+	if err != nil {
+		status, code := statusFor(err)
+		log.Println(code, location)
+		return statusError(ctx, status, code)
+	}
+
+	view := forecast.View(g.viewOptions())
+	for _, d := range view.Days {
+
+		err := stream.Send(&weatherv1.ForecastDay{
+			Cep:          view.CEP,
+			City:         view.City,
+			Date:         d.Date,
+			MinC:         *d.MinC,
+			MinF:         *d.MinF,
+			MinK:         *d.MinK,
+			MaxC:         *d.MaxC,
+			MaxF:         *d.MaxF,
+			MaxK:         *d.MaxK,
+			AvgC:         *d.AvgC,
+			AvgF:         *d.AvgF,
+			AvgK:         *d.AvgK,
+			MinR:         *d.MinR,
+			MaxR:         *d.MaxR,
+			AvgR:         *d.AvgR,
+			ChanceOfRain: int32(d.ChanceOfRain),
+			Condition:    d.Condition,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// weather renders location like the default HTTP response, in every scale
+// and with its coordinates when known.
+func (g *grpcService) weather(location *domain.Location) *weatherv1.Weather {
+
+	opts := g.viewOptions()
+	opts.Fields.Coordinates = true
+	view := location.View(opts)
+
+	return &weatherv1.Weather{
+		Cep:   view.CEP,
+		City:  view.City,
+		TempC: *view.TempC,
+		TempF: *view.TempF,
+		TempK: *view.TempK,
+		TempR: *view.TempR,
+		Lat:   view.Lat,
+		Lon:   view.Lon,
+	}
+}
@@ -0,0 +1,248 @@
+package serviceb_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	weatherv1 "github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/api/weather/v1"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep/ceptest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather/weathertest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/serviceb"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves the gRPC API of service-b in memory, with fake
+// upstreams, and returns a connection to it made with opts.
+func newGRPCClient(t *testing.T, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	cepSrv := ceptest.NewServer()
+	t.Cleanup(cepSrv.Close)
+	weatherSrv := weathertest.NewServer()
+	t.Cleanup(weatherSrv.Close)
+	geocodeSrv := geocodetest.NewServer()
+	t.Cleanup(geocodeSrv.Close)
+
+	cepSrv.AddCity("29902555", "Linhares")
+	weatherSrv.AddLocation(weather.WeatherLocation{Name: "Linhares", Region: "Espirito Santo", Country: "Brazil", Lat: -19.39, Lon: -40.07}, weather.CurrentWeather{FeelsLikeC: 25})
+	geocodeSrv.AddCoordinates("29902555", -19.39, -40.07)
+
+	t.Setenv("VIACEP_BASE_URL", cepSrv.URL)
+	t.Setenv("WEATHER_API_BASE_URL", weatherSrv.URL)
+	t.Setenv("GEOCODE_BASE_URL", geocodeSrv.URL)
+	t.Setenv("BATCH_MAX_SIZE", "3")
+
+	service := domain.NewLocationService(domain.NewLocationRepository())
+	srv := serviceb.NewServer(service).GRPCServer(nil)
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// reason returns the error code in the ErrorInfo detail of err.
+func reason(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGRPCGetWeatherByCEP(t *testing.T) {
+
+	client := weatherv1.NewWeatherServiceClient(newGRPCClient(t))
+
+	tests := []struct {
+		name        string
+		cep         string
+		lang        string
+		wantCode    codes.Code
+		wantReason  string
+		wantMessage string
+	}{
+		{name: "success", cep: "29902555"},
+		{name: "invalid cep", cep: "123", wantCode: codes.InvalidArgument, wantReason: "invalid_zipcode", wantMessage: "invalid zipcode"},
+		{name: "not found", cep: "12345678", wantCode: codes.NotFound, wantReason: "zipcode_not_found", wantMessage: "can not find zipcode"},
+		{name: "localized", cep: "12345678", lang: "pt-BR", wantCode: codes.NotFound, wantReason: "zipcode_not_found", wantMessage: "não foi possível encontrar o CEP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx := context.Background()
+			if tt.lang != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "accept-language", tt.lang)
+			}
+
+			resp, err := client.GetWeatherByCEP(ctx, &weatherv1.GetWeatherByCEPRequest{Cep: tt.cep})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("expected code %v but got %v", tt.wantCode, err)
+			}
+			if err != nil {
+				if got := reason(err); got != tt.wantReason {
+					t.Errorf("expected reason %q but got %q", tt.wantReason, got)
+				}
+				if got := status.Convert(err).Message(); got != tt.wantMessage {
+					t.Errorf("expected message %q but got %q", tt.wantMessage, got)
+				}
+				return
+			}
+
+			w := resp.GetWeather()
+			if w.GetCity() != "Linhares" || w.GetTempC() != 25 || w.GetTempF() != 77 || w.GetTempK() != 298.15 {
+				t.Errorf("unexpected weather %+v", w)
+			}
+			if w.Lat == nil || w.GetLat() != -19.39 || w.GetLon() != -40.07 {
+				t.Errorf("expected the coordinates of the cep but got %+v", w)
+			}
+		})
+	}
+}
+
+func TestGRPCBatchGetWeather(t *testing.T) {
+
+	client := weatherv1.NewWeatherServiceClient(newGRPCClient(t))
+
+	resp, err := client.BatchGetWeather(context.Background(), &weatherv1.BatchGetWeatherRequest{Ceps: []string{"29902555", "123", "12345678"}})
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	want := []struct {
+		status int32
+		code   string
+	}{{status: 200}, {status: 422, code: "invalid_zipcode"}, {status: 404, code: "zipcode_not_found"}}
+
+	if len(resp.GetResults()) != len(want) {
+		t.Fatalf("expected %d results but got %d", len(want), len(resp.GetResults()))
+	}
+	for i, res := range resp.GetResults() {
+		if res.GetStatus() != want[i].status || res.GetCode() != want[i].code {
+			t.Errorf("result %d: expected %d %q but got %+v", i, want[i].status, want[i].code, res)
+		}
+	}
+	if resp.GetResults()[0].GetWeather().GetCity() != "Linhares" {
+		t.Errorf("expected the weather of the first cep but got %+v", resp.GetResults()[0])
+	}
+
+	for _, ceps := range [][]string{nil, {"1", "2", "3", "4"}} {
+		_, err := client.BatchGetWeather(context.Background(), &weatherv1.BatchGetWeatherRequest{Ceps: ceps})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected %d ceps to be refused but got %v", len(ceps), err)
+		}
+	}
+}
+
+func TestGRPCStreamForecast(t *testing.T) {
+
+	client := weatherv1.NewWeatherServiceClient(newGRPCClient(t))
+
+	tests := []struct {
+		name     string
+		cep      string
+		days     int32
+		wantDays int
+		wantCode codes.Code
+	}{
+		{name: "default days", cep: "29902555", wantDays: 3},
+		{name: "five days", cep: "29902555", days: 5, wantDays: 5},
+		{name: "too many days", cep: "29902555", days: 15, wantCode: codes.InvalidArgument},
+		{name: "not found", cep: "12345678", wantCode: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			stream, err := client.StreamForecast(context.Background(), &weatherv1.StreamForecastRequest{Cep: tt.cep, Days: tt.days})
+			if err != nil {
+				t.Fatalf("expected error to be nil and got %v", err)
+			}
+
+			var days []*weatherv1.ForecastDay
+			for {
+				d, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					if status.Code(err) != tt.wantCode {
+						t.Fatalf("expected code %v but got %v", tt.wantCode, err)
+					}
+					return
+				}
+				days = append(days, d)
+			}
+
+			if tt.wantCode != codes.OK {
+				t.Fatalf("expected code %v but the stream ended", tt.wantCode)
+			}
+			if len(days) != tt.wantDays || days[0].GetCity() != "Linhares" || days[0].GetDate() == "" {
+				t.Errorf("expected %d days of Linhares but got %v", tt.wantDays, days)
+			}
+		})
+	}
+}
+
+func TestGRPCSignedCalls(t *testing.T) {
+
+	t.Setenv("REQUEST_SIGNING_KEY", "shared-secret")
+
+	unsigned := newGRPCClient(t)
+	signed := newGRPCClient(t,
+		grpc.WithUnaryInterceptor(signature.UnaryClientInterceptor([]byte("shared-secret"))),
+		grpc.WithStreamInterceptor(signature.StreamClientInterceptor([]byte("shared-secret"))),
+	)
+
+	req := &weatherv1.GetWeatherByCEPRequest{Cep: "29902555"}
+
+	if _, err := weatherv1.NewWeatherServiceClient(signed).GetWeatherByCEP(context.Background(), req); err != nil {
+		t.Errorf("expected a signed call to be served but got %v", err)
+	}
+
+	_, err := weatherv1.NewWeatherServiceClient(unsigned).GetWeatherByCEP(context.Background(), req)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an unsigned call to be refused but got %v", err)
+	}
+
+	stream, err := weatherv1.NewWeatherServiceClient(unsigned).StreamForecast(context.Background(), &weatherv1.StreamForecastRequest{Cep: "29902555"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected an unsigned stream to be refused but got %v", err)
+	}
+
+	// Probes can not sign, so the health service stays open.
+	health, err := healthpb.NewHealthClient(unsigned).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "weather.v1.WeatherService"})
+	if err != nil || health.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected the weather service to be serving but got %v %v", health, err)
+	}
+}
@@ -0,0 +1,440 @@
+package serviceb
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/recovery"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultImportColumns are the location columns written by a CSV export when
+// the request does not pick them with ?columns=.
+var DefaultImportColumns = []string{"cep", "city", "temp_c", "temp_f", "temp_k"}
+
+// importColumns renders each supported CSV column from a resolved location,
+// rendered with every unit and optional field (see csvImportEncoder).
+var importColumns = map[string]func(v *domain.LocationView) string{
+	"cep":    func(v *domain.LocationView) string { return v.CEP },
+	"city":   func(v *domain.LocationView) string { return v.City },
+	"temp_c": func(v *domain.LocationView) string { return formatFloat(v.TempC) },
+	"temp_f": func(v *domain.LocationView) string { return formatFloat(v.TempF) },
+	"temp_k": func(v *domain.LocationView) string { return formatFloat(v.TempK) },
+	"temp_r": func(v *domain.LocationView) string { return formatFloat(v.TempR) },
+	"lat":    func(v *domain.LocationView) string { return formatFloat(v.Lat) },
+	"lon":    func(v *domain.LocationView) string { return formatFloat(v.Lon) },
+
+	"street":       addressColumn(func(a *domain.Address) string { return a.Street }),
+	"neighborhood": addressColumn(func(a *domain.Address) string { return a.Neighborhood }),
+	"state":        addressColumn(func(a *domain.Address) string { return a.State }),
+	"ibge":         addressColumn(func(a *domain.Address) string { return a.IBGE }),
+	"ddd":          addressColumn(func(a *domain.Address) string { return a.DDD }),
+}
+
+// addressColumn renders field of the location address, or an empty cell
+// when the provider returned no address.
+func addressColumn(field func(a *domain.Address) string) func(v *domain.LocationView) string {
+	return func(v *domain.LocationView) string {
+
+		if v.Address == nil {
+			return ""
+		}
+
+		return field(v.Address)
+	}
+}
+
+// ImportLine is one input line waiting to be resolved.
+type ImportLine struct {
+	Line int
+	CEP  string
+	Err  error
+}
+
+// ImportResult is one NDJSON output line. Location is rendered with the
+// ?units= and ?fields= options of the request.
+type ImportResult struct {
+	Line     int                  `json:"line"`
+	CEP      string               `json:"cep"`
+	Status   int                  `json:"status"`
+	Location *domain.LocationView `json:"location,omitempty"`
+	Message  string               `json:"message,omitempty"`
+	Code     i18n.Code            `json:"code,omitempty"`
+
+	location *domain.Location
+}
+
+// ImportSummary is the last NDJSON output line, also sent as trailers.
+type ImportSummary struct {
+	Total  int `json:"total"`
+	OK     int `json:"ok"`
+	Failed int `json:"failed"`
+}
+
+func (s *Server) handlerImport(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: import ceps")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var lines <-chan ImportLine
+	switch mediaType {
+	case "text/csv":
+		lines = readCSVLines(ctx, r.Body)
+	case "application/x-ndjson", "application/jsonl":
+		lines = readNDJSONLines(ctx, r.Body)
+	default:
+		problem.Write(w, r, http.StatusUnsupportedMediaType, i18n.CodeUnsupportedMediaType)
+		return
+	}
+
+	var enc importEncoder
+	if wantsCSV(r) {
+
+		columns := DefaultImportColumns
+		if c := r.URL.Query().Get("columns"); c != "" {
+			columns = strings.Split(c, ",")
+		}
+		for _, c := range columns {
+			if _, ok := importColumns[c]; !ok {
+				problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidColumn, c)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		enc = newCSVImportEncoder(w, columns, opts)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc = &ndjsonImportEncoder{enc: json.NewEncoder(w)}
+	}
+
+	// Results are flushed while the body is still being read. Over HTTP/1.x
+	// net/http discards the unread body at the first flush unless full
+	// duplex is enabled, which would silently cut the import short.
+	flusher := http.NewResponseController(w)
+	if err := flusher.EnableFullDuplex(); err != nil && r.ProtoMajor < 2 {
+		log.Println("error enabling full duplex for import:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	w.Header().Set("Trailer", "X-Import-Total, X-Import-Ok, X-Import-Failed")
+	w.WriteHeader(http.StatusOK)
+
+	var summary ImportSummary
+	var writeErr error
+	for res := range s.resolveLines(ctx, lines, opts) {
+
+		summary.Total++
+		if res.Status == http.StatusOK {
+			summary.OK++
+		} else {
+			summary.Failed++
+		}
+
+		// Keep draining after a write failure so no worker is left blocked.
+		if writeErr != nil {
+			continue
+		}
+
+		writeErr = enc.Encode(res)
+		if writeErr != nil {
+			log.Println("error writing import result:", writeErr)
+			continue
+		}
+		_ = flusher.Flush()
+	}
+
+	_ = enc.Close(summary)
+
+	w.Header().Set("X-Import-Total", strconv.Itoa(summary.Total))
+	w.Header().Set("X-Import-Ok", strconv.Itoa(summary.OK))
+	w.Header().Set("X-Import-Failed", strconv.Itoa(summary.Failed))
+
+	span.SetAttributes(
+		attribute.Int("import.total", summary.Total),
+		attribute.Int("import.failed", summary.Failed),
+	)
+}
+
+// resolveLines resolves lines with at most batchWorkers concurrent lookups
+// and yields results as soon as they are ready, so output order follows
+// resolution order rather than input order.
+func (s *Server) resolveLines(ctx context.Context, lines <-chan ImportLine, opts domain.ViewOptions) <-chan ImportResult {
+
+	results := make(chan ImportResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.batchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range lines {
+				results <- s.resolveLine(ctx, line, opts)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+func (s *Server) resolveLine(ctx context.Context, line ImportLine, opts domain.ViewOptions) (res ImportResult) {
+
+	res = ImportResult{Line: line.Line, CEP: line.CEP}
+
+	lang := i18n.FromContext(ctx)
+
+	defer recovery.Worker(ctx, func() {
+		res = ImportResult{Line: line.Line, CEP: line.CEP, Status: http.StatusInternalServerError, Code: i18n.CodeInternalError}
+		res.Message = i18n.Message(lang, res.Code)
+	})
+
+	if line.Err != nil {
+		log.Println("error to parse import line:", line.Line, line.Err)
+		res.Status, res.Code = http.StatusBadRequest, i18n.CodeInvalidLine
+		res.Message = i18n.Message(lang, res.Code)
+		return res
+	}
+
+	location, err := domain.NewLocation(line.CEP)
+	if err != nil {
+		res.Status, res.Code = http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode
+		res.Message = i18n.Message(lang, res.Code)
+		return res
+	}
+
+	err = s.service.Execute(ctx, location)
+	if err != nil {
+		res.Status, res.Code = statusFor(err)
+		res.Message = i18n.Message(lang, res.Code)
+		return res
+	}
+
+	view := location.View(opts)
+	res.Status, res.Location, res.location = http.StatusOK, &view, location
+	return res
+}
+
+// readCSVLines streams the first column of every record. A leading header
+// whose first column is "cep" is skipped.
+func readCSVLines(ctx context.Context, body io.Reader) <-chan ImportLine {
+
+	lines := make(chan ImportLine)
+
+	go func() {
+		defer close(lines)
+
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		reader.ReuseRecord = true
+
+		for first := true; ; first = false {
+
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			var item ImportLine
+			var parseErr *csv.ParseError
+			switch {
+			case errors.As(err, &parseErr):
+				item = ImportLine{Line: parseErr.Line, Err: fmt.Errorf("invalid csv line")}
+			case err != nil:
+				log.Println("error reading csv import:", err)
+				return
+			default:
+				cep := strings.TrimSpace(record[0])
+				if first && strings.EqualFold(cep, "cep") {
+					continue
+				}
+				line, _ := reader.FieldPos(0)
+				item = ImportLine{Line: line, CEP: cep}
+			}
+
+			select {
+			case lines <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return lines
+}
+
+// readNDJSONLines streams one CEP per line, given either as {"cep": "..."}
+// or as a bare JSON string. Blank lines are ignored.
+func readNDJSONLines(ctx context.Context, body io.Reader) <-chan ImportLine {
+
+	lines := make(chan ImportLine)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(body)
+		for n := 1; scanner.Scan(); n++ {
+
+			raw := strings.TrimSpace(scanner.Text())
+			if raw == "" {
+				continue
+			}
+
+			item := ImportLine{Line: n}
+
+			var data struct {
+				CEP string `json:"cep"`
+			}
+			if err := json.Unmarshal([]byte(raw), &data); err == nil {
+				item.CEP = data.CEP
+			} else if err := json.Unmarshal([]byte(raw), &item.CEP); err != nil {
+				item.Err = fmt.Errorf("invalid json line")
+			}
+
+			select {
+			case lines <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			log.Println("error reading ndjson import:", err)
+		}
+	}()
+
+	return lines
+}
+
+func wantsCSV(r *http.Request) bool {
+
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "csv"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+type importEncoder interface {
+	Encode(res ImportResult) error
+	Close(summary ImportSummary) error
+}
+
+type ndjsonImportEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonImportEncoder) Encode(res ImportResult) error {
+	return e.enc.Encode(res)
+}
+
+func (e *ndjsonImportEncoder) Close(summary ImportSummary) error {
+	return e.enc.Encode(struct {
+		Summary ImportSummary `json:"summary"`
+	}{summary})
+}
+
+// csvImportEncoder renders the columns of every location with all units and
+// optional fields, keeping the rounding and compatibility options of the
+// request, since the columns themselves select what is written.
+type csvImportEncoder struct {
+	w       *csv.Writer
+	columns []string
+	opts    domain.ViewOptions
+	header  bool
+}
+
+func newCSVImportEncoder(w io.Writer, columns []string, opts domain.ViewOptions) *csvImportEncoder {
+
+	opts.Units = domain.Units{C: true, F: true, K: true, R: true}
+	opts.Fields = domain.Fields{Address: true, Coordinates: true}
+
+	return &csvImportEncoder{w: csv.NewWriter(w), columns: columns, opts: opts}
+}
+
+func (e *csvImportEncoder) Encode(res ImportResult) error {
+
+	if !e.header {
+		header := append([]string{"line", "status"}, e.columns...)
+		if err := e.w.Write(append(header, "error")); err != nil {
+			return err
+		}
+		e.header = true
+	}
+
+	var view *domain.LocationView
+	if res.location != nil {
+		v := res.location.View(e.opts)
+		view = &v
+	}
+
+	record := []string{strconv.Itoa(res.Line), strconv.Itoa(res.Status)}
+	for _, c := range e.columns {
+		switch {
+		case view != nil:
+			record = append(record, importColumns[c](view))
+		case c == "cep":
+			record = append(record, res.CEP)
+		default:
+			record = append(record, "")
+		}
+	}
+	record = append(record, res.Message)
+
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Close writes the header when no line was imported; the summary itself
+// is only carried by the trailers.
+func (e *csvImportEncoder) Close(ImportSummary) error {
+
+	if !e.header {
+		header := append([]string{"line", "status"}, e.columns...)
+		_ = e.w.Write(append(header, "error"))
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// formatFloat renders f, or an empty cell when it is unknown.
+func formatFloat(f *float64) string {
+
+	if f == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
@@ -0,0 +1,227 @@
+package serviceb_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/cep/ceptest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/geocode/geocodetest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/infra/weather/weathertest"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/serviceb"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	cepSrv := ceptest.NewServer()
+	t.Cleanup(cepSrv.Close)
+	weatherSrv := weathertest.NewServer()
+	t.Cleanup(weatherSrv.Close)
+	geocodeSrv := geocodetest.NewServer()
+	t.Cleanup(geocodeSrv.Close)
+
+	cepSrv.AddCity("29902555", "Linhares")
+	cepSrv.AddCity("01308080", "São Paulo")
+	weatherSrv.AddCity("Linhares", 25)
+	weatherSrv.AddCity("São Paulo", 20)
+
+	t.Setenv("VIACEP_BASE_URL", cepSrv.URL)
+	t.Setenv("WEATHER_API_BASE_URL", weatherSrv.URL)
+	t.Setenv("GEOCODE_BASE_URL", geocodeSrv.URL)
+
+	service := domain.NewLocationService(domain.NewLocationRepository())
+	srv := httptest.NewServer(serviceb.NewServer(service).Handler())
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func postImport(t *testing.T, url, contentType, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error calling import: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestImportNDJSON(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "ndjson input",
+			contentType: "application/x-ndjson",
+			body:        "{\"cep\":\"29902555\"}\n\n\"01308080\"\n{\"cep\":\"123\"}\nnot json\n{\"cep\":\"12345678\"}\n",
+		},
+		{
+			name:        "csv input",
+			contentType: "text/csv",
+			body:        "cep\n29902555\n\n01308080\n123\n\"bad\"quote\n12345678\n",
+		},
+	}
+
+	want := map[int]int{200: 2, 400: 1, 404: 1, 422: 1}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			resp := postImport(t, srv.URL+"/v1/weather:import", tt.contentType, tt.body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("expected ndjson output but got %q", ct)
+			}
+
+			got := map[int]int{}
+			var summary *serviceb.ImportSummary
+
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+
+				var line struct {
+					serviceb.ImportResult
+					Summary *serviceb.ImportSummary `json:"summary"`
+				}
+				if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+					t.Fatalf("invalid ndjson line %q: %v", scanner.Text(), err)
+				}
+
+				if line.Summary != nil {
+					summary = line.Summary
+					continue
+				}
+
+				got[line.Status]++
+				if line.Status == http.StatusOK && (line.Location == nil || line.Location.City == "") {
+					t.Errorf("line %d: expected a location but got %+v", line.Line, line)
+				}
+			}
+
+			for status, n := range want {
+				if got[status] != n {
+					t.Errorf("expected %d lines with status %d but got %d", n, status, got[status])
+				}
+			}
+
+			if summary == nil || summary.Total != 5 || summary.OK != 2 || summary.Failed != 3 {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+			if resp.Trailer.Get("X-Import-Failed") != "3" {
+				t.Errorf("expected failed trailer 3 but got %q", resp.Trailer.Get("X-Import-Failed"))
+			}
+		})
+	}
+}
+
+func TestImportCSVOutput(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	body := "29902555\n123\n"
+
+	resp := postImport(t, srv.URL+"/v1/weather:import?format=csv&columns=cep,city,temp_f", "text/csv", body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", resp.StatusCode)
+	}
+
+	data, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	if lines[0] != "line,status,cep,city,temp_f,error" {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+
+	rows := lines[1:]
+	sort.Strings(rows)
+	want := []string{"1,200,29902555,Linhares,77,", "2,422,123,,,invalid zipcode"}
+	if strings.Join(rows, "|") != strings.Join(want, "|") {
+		t.Errorf("expected rows %v but got %v", want, rows)
+	}
+
+	resp = postImport(t, srv.URL+"/v1/weather:import?format=csv&columns=nope", "text/csv", body)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected unknown column to be rejected with 400 but got %d", resp.StatusCode)
+	}
+
+	resp = postImport(t, srv.URL+"/v1/weather:import", "application/json", body)
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected unsupported content type to be rejected with 415 but got %d", resp.StatusCode)
+	}
+}
+
+// TestImportLargeBody sends a body far larger than what net/http buffers, so
+// that an import cut short when the first results are flushed is caught.
+func TestImportLargeBody(t *testing.T) {
+
+	srv := newTestServer(t)
+
+	const lines = 8000
+	body := strings.Repeat("{\"cep\":\"123\"}\n", lines)
+
+	resp := postImport(t, srv.URL+"/v1/weather:import", "application/x-ndjson", body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", resp.StatusCode)
+	}
+
+	results := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		results++
+	}
+
+	// One line per input line plus the summary.
+	if results != lines+1 {
+		t.Errorf("expected %d output lines but got %d", lines+1, results)
+	}
+	if got := resp.Trailer.Get("X-Import-Total"); got != strconv.Itoa(lines) {
+		t.Errorf("expected total trailer %d but got %q", lines, got)
+	}
+}
+
+// TestImportSignedLargeBody sends a signed import larger than the bodies
+// buffered to verify a signature, which must be verified as it streams.
+func TestImportSignedLargeBody(t *testing.T) {
+
+	t.Setenv("REQUEST_SIGNING_KEY", "shared-secret")
+	srv := newTestServer(t)
+
+	// Padded lines keep the count of lookups low.
+	line := "{\"cep\":\"123\",\"note\":\"" + strings.Repeat("x", 1000) + "\"}\n"
+	lines := signature.MaxBodySize/len(line) + 1000
+	body := strings.Repeat(line, lines)
+
+	client := &http.Client{Transport: signature.Transport(nil, []byte("shared-secret"))}
+	resp, err := client.Post(srv.URL+"/v1/weather:import", "application/x-ndjson", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error calling import: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", resp.StatusCode)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if got := resp.Trailer.Get("X-Import-Total"); got != strconv.Itoa(lines) {
+		t.Errorf("expected total trailer %d but got %q", lines, got)
+	}
+}
@@ -0,0 +1,468 @@
+// Package serviceb implements service-b, which resolves a CEP to its city
+// and current temperatures.
+package serviceb
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "service-b"
+
+var (
+	tracer  = otel.Tracer(name)
+	meter   = otel.Meter(name)
+	logger  = otelslog.NewLogger(name)
+	rollCnt metric.Int64Counter
+)
+
+// Default limits for POST /v1/weather:batch, overridable with the
+// BATCH_WORKERS and BATCH_MAX_SIZE environment variables.
+const (
+	DefaultBatchWorkers = 8
+	DefaultBatchMaxSize = 1000
+)
+
+// Server answers lookups using a long-lived LocationService.
+type Server struct {
+	service      *domain.LocationService
+	batchWorkers int
+	batchMaxSize int
+	legacyKelvin bool
+
+	readings        *domain.ReadingHub
+	streamHeartbeat time.Duration
+}
+
+// NewServer returns a Server backed by service.
+func NewServer(service *domain.LocationService) *Server {
+	return &Server{
+		service:      service,
+		batchWorkers: envInt("BATCH_WORKERS", DefaultBatchWorkers),
+		batchMaxSize: envInt("BATCH_MAX_SIZE", DefaultBatchMaxSize),
+		legacyKelvin: os.Getenv("TEMPERATURE_COMPAT") == domain.CompatKelvin273,
+
+		readings:        domain.NewReadingHub(service, domain.StreamLimitsFromEnv()),
+		streamHeartbeat: envDuration("STREAM_HEARTBEAT_INTERVAL", DefaultStreamHeartbeat),
+	}
+}
+
+func envInt(key string, fallback int) int {
+
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n < 1 {
+		return fallback
+	}
+
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Println("invalid "+key+", using default:", v)
+		return fallback
+	}
+
+	return d
+}
+
+// Handler returns the routes served by service-b behind the middleware
+// chain configured from the environment.
+func (s *Server) Handler() http.Handler {
+	return s.routes(webserver.New(webserver.ConfigFromEnv(name))).Handler()
+}
+
+// routes registers the routes served by service-b on ws. With
+// REQUEST_SIGNING_KEY set, only requests signed by service-a are served.
+// Imports are verified as they stream, so that their size is not bounded
+// by the buffering of signature.MaxBodySize.
+func (s *Server) routes(ws *webserver.Server) *webserver.Server {
+	if key := signature.KeyFromEnv(); key != nil {
+		ws.Use(signature.NewVerifier(key).Streaming(isImport))
+	}
+	ws.HandleFunc("POST /{$}", s.handlerIndex)
+	ws.HandleFunc("GET /v1/weather/{cep}", s.handlerWeather)
+	ws.HandleFunc("GET /v1/weather/{cep}/history", s.handlerHistory)
+	ws.HandleFunc("GET /v1/cep/{cep}", s.handlerCEP)
+	ws.HandleFunc("GET /v1/forecast/{cep}", s.handlerForecast)
+	ws.HandleFunc("POST /v1/weather:batch", s.handlerBatch)
+	ws.HandleStream("POST /v1/weather:import", s.handlerImport)
+	ws.HandleStream("GET /v1/weather/{cep}/stream", s.handlerStream)
+	return ws
+}
+
+// isImport reports whether r is a streamed import.
+func isImport(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.URL.Path == "/v1/weather:import"
+}
+
+// startSpan starts the span of a handler in the trace continued by the
+// webserver middleware chain. Handlers attach the returned context to r so
+// that problem replies carry the trace ID.
+func startSpan(r *http.Request, spanName string) (context.Context, trace.Span) {
+
+	ctx, span := tracer.Start(r.Context(), spanName)
+
+	span.SetAttributes(attribute.String("service.name", "service-b"))
+
+	return ctx, span
+}
+
+func (s *Server) handlerIndex(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: check cep and weather")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var data struct {
+		CEP string `json:"cep"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeNoZipcode)
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", data.CEP))
+
+	location, err := domain.NewLocation(data.CEP)
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+	opts.Units = domain.AllUnits
+
+	err = s.service.ExecuteWith(ctx, location, opts.Fields)
+	if err != nil {
+		replyServiceError(w, r, err, location)
+		return
+	}
+
+	reply(w, r, location.View(opts))
+}
+
+func (s *Server) handlerWeather(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: check cep and weather")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	location, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	err = s.service.ExecuteWith(ctx, location, opts.Fields)
+	if err != nil {
+		replyServiceError(w, r, err, location)
+		return
+	}
+
+	reply(w, r, location.View(opts))
+}
+
+func (s *Server) handlerCEP(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: check cep")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+	opts.Units = domain.Units{}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	location, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	err = s.service.GetCEP(ctx, location)
+	if err != nil {
+		replyServiceError(w, r, err, location)
+		return
+	}
+
+	reply(w, r, location.View(opts))
+}
+
+func (s *Server) handlerForecast(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: check cep and forecast")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := domain.ParseForecastDays(r.URL.Query().Get("days"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidDays)
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	location, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	forecast, err := s.service.Forecast(ctx, location, days)
+	if err != nil {
+		replyServiceError(w, r, err, location)
+		return
+	}
+
+	reply(w, r, forecast.View(opts))
+}
+
+func (s *Server) handlerHistory(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: check cep and history")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+
+	date, err := domain.ParseHistoryDate(r.URL.Query().Get("date"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidDate)
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	location, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	history, err := s.service.History(ctx, location, date)
+	if err != nil {
+		replyServiceError(w, r, err, location)
+		return
+	}
+
+	reply(w, r, history.View(opts))
+}
+
+// viewOptions reads the ?units=, ?fields=, ?precision= and ?compat= query
+// options, replying 400 and returning false when they are invalid.
+func (s *Server) viewOptions(w http.ResponseWriter, r *http.Request) (domain.ViewOptions, bool) {
+
+	units, err := domain.ParseUnits(r.URL.Query().Get("units"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidUnits)
+		return domain.ViewOptions{}, false
+	}
+
+	fields, err := domain.ParseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidFields)
+		return domain.ViewOptions{}, false
+	}
+
+	precision, err := domain.ParsePrecision(r.URL.Query().Get("precision"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidPrecision)
+		return domain.ViewOptions{}, false
+	}
+
+	legacyKelvin, err := domain.ParseCompat(r.URL.Query().Get("compat"), s.legacyKelvin)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeInvalidCompat)
+		return domain.ViewOptions{}, false
+	}
+
+	return domain.ViewOptions{
+		Units:        units,
+		Fields:       fields,
+		Round:        true,
+		Precision:    precision,
+		LegacyKelvin: legacyKelvin,
+	}, true
+}
+
+// BatchRequest is the body of POST /v1/weather:batch.
+type BatchRequest struct {
+	CEPs []string `json:"ceps"`
+}
+
+// BatchItem is the outcome of one CEP of a batch, in request order.
+type BatchItem struct {
+	CEP      string               `json:"cep"`
+	Status   int                  `json:"status"`
+	Location *domain.LocationView `json:"location,omitempty"`
+	Message  string               `json:"message,omitempty"`
+	Code     i18n.Code            `json:"code,omitempty"`
+}
+
+// BatchResponse is the body answered by POST /v1/weather:batch.
+type BatchResponse struct {
+	Results []BatchItem `json:"results"`
+}
+
+func (s *Server) handlerBatch(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: batch check cep and weather")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+
+	var data BatchRequest
+
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil || len(data.CEPs) == 0 {
+		problem.Write(w, r, http.StatusBadRequest, i18n.CodeNoZipcode)
+		return
+	}
+
+	if len(data.CEPs) > s.batchMaxSize {
+		problem.Write(w, r, http.StatusRequestEntityTooLarge, i18n.CodeTooManyZipcodes, s.batchMaxSize)
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.Int("batch.size", len(data.CEPs)))
+
+	results := s.service.ExecuteBatch(ctx, data.CEPs, s.batchWorkers, opts.Fields)
+
+	response := BatchResponse{Results: make([]BatchItem, len(results))}
+	for i, res := range results {
+
+		item := BatchItem{CEP: res.CEP, Status: http.StatusOK}
+		if res.Err != nil {
+			item.Status, item.Code = statusFor(res.Err)
+			item.Message = i18n.Message(i18n.FromContext(ctx), item.Code)
+		} else {
+			view := res.Location.View(opts)
+			item.Location = &view
+		}
+
+		response.Results[i] = item
+	}
+
+	reply(w, r, response)
+}
+
+// statusFor maps the status code carried by a LocationService error to the
+// HTTP status and error code answered to the client.
+func statusFor(err error) (int, i18n.Code) {
+
+	switch err.Error() {
+	case "422":
+		return http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode
+	case "404":
+		return http.StatusNotFound, i18n.CodeZipcodeNotFound
+	case "503":
+		return http.StatusServiceUnavailable, i18n.CodeUpstreamBudgetExhausted
+	default:
+		return http.StatusInternalServerError, i18n.CodeInternalError
+	}
+}
+
+// replyServiceError maps the status code carried by a LocationService error
+// to the HTTP reply.
+func replyServiceError(w http.ResponseWriter, r *http.Request, err error, location *domain.Location) {
+
+	status, code := statusFor(err)
+
+	log.Println(code, location)
+
+	problem.Write(w, r, status, code)
+}
+
+func reply(w http.ResponseWriter, r *http.Request, data any) {
+
+	byteResponseData, err := json.Marshal(data)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(byteResponseData)
+}
+
+// StartCepCollector serves the routes on the port configured by the
+// environment and the gRPC API on GRPC_PORT, sharing one LocationService,
+// until either listener fails.
+func StartCepCollector() error {
+
+	repo := domain.NewLocationRepository()
+	s := NewServer(domain.NewLocationService(repo))
+	cfg := webserver.ConfigFromEnv(name)
+
+	errs := make(chan error, 2)
+	go func() { errs <- s.ServeGRPC(grpcPort(), cfg.TLS) }()
+	go func() { errs <- s.routes(webserver.New(cfg)).ListenAndServe() }()
+
+	return <-errs
+}
@@ -0,0 +1,155 @@
+package serviceb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultStreamHeartbeat is how often a stream gets a heartbeat event,
+// overridable with the STREAM_HEARTBEAT_INTERVAL environment variable.
+const DefaultStreamHeartbeat = 15 * time.Second
+
+// streamRetry is the reconnection delay announced to the clients of a stream,
+// and the Retry-After of the streams refused for lack of room.
+const streamRetry = 5 * time.Second
+
+// handlerStream streams the current conditions of the city of the CEP as
+// Server-Sent Events: a "weather" event, identified by the ID of its
+// reading, every time the city's reading is refreshed with new values, an
+// "error" event when a refresh fails and a "heartbeat" event every
+// heartbeat interval. Clients reconnecting with Last-Event-ID only get the
+// latest reading again if it is newer than the one they had.
+func (s *Server) handlerStream(w http.ResponseWriter, r *http.Request) {
+
+	ctx, span := startSpan(r, "service_b-handler: stream weather")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	opts, ok := s.viewOptions(w, r)
+	if !ok {
+		return
+	}
+
+	webserver.AccessLogAttrs(ctx, slog.String("cep", r.PathValue("cep")))
+
+	location, err := domain.NewLocation(r.PathValue("cep"))
+	if err != nil {
+
+		log.Println(err)
+		problem.Write(w, r, http.StatusUnprocessableEntity, i18n.CodeInvalidZipcode)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("response writer does not support streaming")
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+
+	err = s.service.GetCEP(ctx, location)
+	if err != nil {
+		replyServiceError(w, r, err, location)
+		return
+	}
+
+	sub, latest, err := s.readings.Subscribe(ctx, location)
+	if err != nil {
+
+		var limitErr *domain.SubscriberLimitError
+		if errors.As(err, &limitErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(streamRetry.Seconds())))
+			problem.Write(w, r, http.StatusServiceUnavailable, i18n.CodeTooManyStreams, limitErr.Limit)
+			return
+		}
+
+		log.Println("error to subscribe to readings:", err)
+		problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		return
+	}
+	defer sub.Close()
+
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	span.SetAttributes(attribute.Int64("stream.last_event_id", lastID))
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if err == nil && latest.ID > lastID {
+		err = writeReading(w, r, location, latest, opts)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for err == nil {
+
+		select {
+		case <-ctx.Done():
+			return
+		case reading := <-sub.C:
+			err = writeReading(w, r, location, reading, opts)
+		case t := <-heartbeat.C:
+			err = writeEvent(w, "heartbeat", 0, map[string]time.Time{"time": t.UTC()})
+		}
+
+		flusher.Flush()
+	}
+
+	log.Println("error to write stream:", err)
+}
+
+// writeReading writes reading as a "weather" event with the location l
+// rendered with opts, or as an "error" event with the problem of a failed
+// refresh.
+func writeReading(w io.Writer, r *http.Request, l *domain.Location, reading domain.Reading, opts domain.ViewOptions) error {
+
+	if reading.Err != nil {
+		status, code := statusFor(reading.Err)
+		return writeEvent(w, "error", 0, problem.New(r.Context(), status, code))
+	}
+
+	// Subscribers of the same city share the reading, not the location.
+	location := *l
+	if err := reading.Apply(&location); err != nil {
+		return writeEvent(w, "error", 0, problem.New(r.Context(), http.StatusInternalServerError, i18n.CodeInternalError))
+	}
+
+	return writeEvent(w, "weather", reading.ID, location.View(opts))
+}
+
+// writeEvent writes an event named event carrying data as JSON, with id as
+// its ID unless zero.
+func writeEvent(w io.Writer, event string, id int64, data any) error {
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if id != 0 {
+		_, err = fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", event, id, b)
+	} else {
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	}
+
+	return err
+}
@@ -0,0 +1,242 @@
+// Package apikey authenticates the clients of a service by API key. Keys are
+// only known by their SHA-256 hash, so that the files and variables holding
+// them never contain the secrets themselves.
+package apikey
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header carries the API key of a request, as an alternative to the
+// "Authorization: Bearer <key>" header.
+const Header = "X-API-Key"
+
+// Scope is an operation a key may be allowed to call.
+type Scope string
+
+const (
+	ScopeLookup   Scope = "lookup"
+	ScopeBatch    Scope = "batch"
+	ScopeForecast Scope = "forecast"
+	// ScopeAll allows every operation.
+	ScopeAll Scope = "*"
+)
+
+// Key is the identity of an authenticated client. ID is not secret and is
+// the only part of a key ever logged or recorded on spans.
+type Key struct {
+	ID     string
+	Scopes []Scope
+}
+
+// Allows reports whether k may call operations of scope.
+func (k Key) Allows(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAll)
+}
+
+// Hash returns the hex encoded SHA-256 of key, the form keys are stored in.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Keyring holds the keys allowed to call a service, indexed by hash. A
+// Keyring without sources lets every request through.
+type Keyring struct {
+	enabled bool
+	keys    map[string]Key
+}
+
+// NewKeyring returns a Keyring enforcing the keys in entries. Each entry is
+// a line "<id> <sha256 hex> <scope>,<scope>", e.g.
+// "partner-1 5e884898da28...42d8 lookup,forecast"; blank lines and lines
+// starting with # are skipped.
+func NewKeyring(entries io.Reader) (*Keyring, error) {
+
+	k := &Keyring{enabled: true, keys: make(map[string]Key)}
+
+	scanner := bufio.NewScanner(entries)
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid api key on line %d", line)
+		}
+
+		id, hash := fields[0], strings.ToLower(fields[1])
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid api key hash of %s on line %d", id, line)
+		}
+
+		key := Key{ID: id}
+		for _, scope := range strings.Split(fields[2], ",") {
+			switch s := Scope(scope); s {
+			case ScopeLookup, ScopeBatch, ScopeForecast, ScopeAll:
+				key.Scopes = append(key.Scopes, s)
+			default:
+				return nil, fmt.Errorf("invalid scope %q of %s on line %d", scope, id, line)
+			}
+		}
+
+		k.keys[hash] = key
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error to read api keys: %w", err)
+	}
+
+	return k, nil
+}
+
+// FromEnv returns the Keyring read from the file at API_KEYS_FILE and from
+// API_KEYS, whose entries are separated by ";". Without either, the Keyring
+// lets every request through.
+func FromEnv() (*Keyring, error) {
+
+	var sources []io.Reader
+
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error to read API_KEYS_FILE: %w", err)
+		}
+		sources = append(sources, strings.NewReader(string(b)+"\n"))
+	}
+
+	if v := os.Getenv("API_KEYS"); v != "" {
+		sources = append(sources, strings.NewReader(strings.ReplaceAll(v, ";", "\n")))
+	}
+
+	if len(sources) == 0 {
+		return &Keyring{}, nil
+	}
+
+	return NewKeyring(io.MultiReader(sources...))
+}
+
+// Enabled reports whether k authenticates requests.
+func (k *Keyring) Enabled() bool {
+	return k.enabled
+}
+
+// Lookup returns the key whose secret is key.
+func (k *Keyring) Lookup(key string) (Key, bool) {
+
+	if key == "" {
+		return Key{}, false
+	}
+
+	found, ok := k.keys[Hash(key)]
+	return found, ok
+}
+
+// Identify returns the known key sent with r, without authenticating r.
+func (k *Keyring) Identify(r *http.Request) (Key, bool) {
+	return k.Lookup(fromRequest(r))
+}
+
+type contextKey struct{}
+
+// WithKey returns a copy of ctx carrying the authenticated key.
+func WithKey(ctx context.Context, key Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the key authenticated by Authenticate, if any.
+func FromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(contextKey{}).(Key)
+	return key, ok
+}
+
+// fromRequest returns the key sent in the X-API-Key header or as a bearer
+// token.
+func fromRequest(r *http.Request) string {
+
+	if key := r.Header.Get(Header); key != "" {
+		return key
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+// Authenticate answers a 401 problem to requests without a known key and
+// stores the key of the others in the request context, recording its ID on
+// the span and in the access log.
+func (k *Keyring) Authenticate(next http.Handler) http.Handler {
+
+	if !k.enabled {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		key, ok := k.Identify(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			problem.Write(w, r, http.StatusUnauthorized, i18n.CodeUnauthorized)
+			return
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("apikey.id", key.ID))
+		webserver.AccessLogAttrs(r.Context(), slog.String("apikey.id", key.ID))
+
+		next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+	})
+}
+
+// Allowed reports whether the key authenticated in ctx may call operations
+// of scope. Any caller is allowed when k is disabled.
+func (k *Keyring) Allowed(ctx context.Context, scope Scope) bool {
+
+	if !k.enabled {
+		return true
+	}
+
+	key, ok := FromContext(ctx)
+	return ok && key.Allows(scope)
+}
+
+// Require answers a 403 problem to requests whose key does not allow scope,
+// and passes the others to h.
+func (k *Keyring) Require(scope Scope, h http.HandlerFunc) http.HandlerFunc {
+
+	if !k.enabled {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if !k.Allowed(r.Context(), scope) {
+			problem.Write(w, r, http.StatusForbidden, i18n.CodeForbidden, scope)
+			return
+		}
+
+		h(w, r)
+	}
+}
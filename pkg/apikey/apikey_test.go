@@ -0,0 +1,186 @@
+package apikey_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+func TestNewKeyring(t *testing.T) {
+
+	hash := apikey.Hash("secret")
+
+	tests := []struct {
+		name    string
+		entries string
+		wantErr bool
+	}{
+		{name: "valid", entries: "# partners\n\npartner-1 " + hash + " lookup,forecast\n"},
+		{name: "upper case hash", entries: "partner-1 " + strings.ToUpper(hash) + " *"},
+		{name: "missing scopes", entries: "partner-1 " + hash, wantErr: true},
+		{name: "plain key", entries: "partner-1 secret lookup", wantErr: true},
+		{name: "unknown scope", entries: "partner-1 " + hash + " lookup,admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			k, err := apikey.NewKeyring(strings.NewReader(tt.entries))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			key, ok := k.Lookup("secret")
+			if !ok || key.ID != "partner-1" {
+				t.Errorf("expected secret to be partner-1 but got %+v", key)
+			}
+			if _, ok := k.Lookup(hash); ok {
+				t.Errorf("expected the hash not to be accepted as a key")
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("from-file "+apikey.Hash("file-key")+" lookup"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("API_KEYS_FILE", "")
+	t.Setenv("API_KEYS", "")
+
+	k, err := apikey.FromEnv()
+	if err != nil || k.Enabled() {
+		t.Fatalf("expected a disabled keyring without sources but got %v %v", k.Enabled(), err)
+	}
+
+	t.Setenv("API_KEYS_FILE", path)
+	t.Setenv("API_KEYS", "a "+apikey.Hash("env-a")+" batch; b "+apikey.Hash("env-b")+" *")
+
+	k, err = apikey.FromEnv()
+	if err != nil || !k.Enabled() {
+		t.Fatalf("expected an enabled keyring but got %v %v", k.Enabled(), err)
+	}
+	for secret, id := range map[string]string{"file-key": "from-file", "env-a": "a", "env-b": "b"} {
+		if key, ok := k.Lookup(secret); !ok || key.ID != id {
+			t.Errorf("expected %s to be %s but got %+v", secret, id, key)
+		}
+	}
+
+	t.Setenv("API_KEYS_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := apikey.FromEnv(); err == nil {
+		t.Errorf("expected an error for a missing API_KEYS_FILE")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+
+	k, _ := apikey.NewKeyring(strings.NewReader("partner-1 " + apikey.Hash("secret") + " lookup"))
+
+	h := k.Authenticate(k.Require(apikey.ScopeLookup, func(w http.ResponseWriter, r *http.Request) {
+		key, _ := apikey.FromContext(r.Context())
+		_, _ = w.Write([]byte(key.ID))
+	}))
+	batch := k.Authenticate(k.Require(apikey.ScopeBatch, func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		header     string
+		value      string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "no key", handler: h, wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "wrong key", handler: h, header: apikey.Header, value: "guess", wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "basic auth", handler: h, header: "Authorization", value: "Basic c2VjcmV0", wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "header", handler: h, header: apikey.Header, value: "secret", wantStatus: http.StatusOK},
+		{name: "bearer", handler: h, header: "Authorization", value: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "missing scope", handler: batch, header: apikey.Header, value: "secret", wantStatus: http.StatusForbidden, wantCode: "forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/weather/29902555", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("expected status %d but got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantCode == "" {
+				if w.Body.String() != "partner-1" {
+					t.Errorf("expected the key in the context but got %q", w.Body.String())
+				}
+				return
+			}
+
+			var p problem.Problem
+			_ = json.NewDecoder(w.Body).Decode(&p)
+			if p.Code != tt.wantCode {
+				t.Errorf("expected code %s but got %+v", tt.wantCode, p)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+
+	k, _ := apikey.NewKeyring(strings.NewReader("partner-1 " + apikey.Hash("secret") + " lookup,forecast"))
+	ctx := apikey.WithKey(context.Background(), apikey.Key{ID: "partner-1", Scopes: []apikey.Scope{apikey.ScopeLookup, apikey.ScopeForecast}})
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		scope apikey.Scope
+		want  bool
+	}{
+		{name: "granted scope", ctx: ctx, scope: apikey.ScopeForecast, want: true},
+		{name: "missing scope", ctx: ctx, scope: apikey.ScopeBatch},
+		{name: "no key", ctx: context.Background(), scope: apikey.ScopeLookup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.Allowed(tt.ctx, tt.scope); got != tt.want {
+				t.Errorf("expected %v but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDisabledKeyring(t *testing.T) {
+
+	t.Setenv("API_KEYS_FILE", "")
+	t.Setenv("API_KEYS", "")
+	k, _ := apikey.FromEnv()
+
+	called := false
+	h := k.Authenticate(k.Require(apikey.ScopeBatch, func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || !called {
+		t.Errorf("expected a disabled keyring to let the request through but got %d", w.Code)
+	}
+	if !k.Allowed(context.Background(), apikey.ScopeBatch) {
+		t.Errorf("expected a disabled keyring to allow any scope")
+	}
+}
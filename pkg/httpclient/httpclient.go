@@ -0,0 +1,83 @@
+// Package httpclient builds the HTTP clients that call other services and
+// upstream providers on behalf of a request, so that every call is traced
+// and carries the request ID and trace context of the request it serves.
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/signature"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// RequestIDHeader carries the identifier of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDBaggageKey carries the request ID in the OpenTelemetry baggage,
+// so that it reaches every service the trace goes through.
+const RequestIDBaggageKey = "request.id"
+
+// Config configures a client built by New.
+type Config struct {
+	// TLS secures the connections, with the default configuration when nil.
+	TLS *tls.Config
+	// SigningKey signs every request with signature.Transport when set.
+	SigningKey []byte
+	// Timeout caps every request; zero means no timeout, for streams.
+	Timeout time.Duration
+}
+
+// New returns a client configured with cfg whose requests are traced with
+// otelhttp and go through Transport.
+func New(cfg Config) *http.Client {
+
+	var base http.RoundTripper = http.DefaultTransport
+
+	if cfg.TLS != nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.TLSClientConfig = cfg.TLS
+		base = tr
+	}
+
+	if cfg.SigningKey != nil {
+		base = signature.Transport(base, cfg.SigningKey)
+	}
+
+	return &http.Client{
+		Transport: otelhttp.NewTransport(Transport(base)),
+		Timeout:   cfg.Timeout,
+	}
+}
+
+// Transport wraps base so that every request carries the request ID and the
+// trace context, baggage included, of its context.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	ctx := req.Context()
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(ctx)
+
+	if id := baggage.FromContext(ctx).Member(RequestIDBaggageKey).Value(); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransport(t *testing.T) {
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+
+	m, _ := baggage.NewMember(RequestIDBaggageKey, "req-1")
+	b, _ := baggage.New(m)
+
+	ctx := baggage.ContextWithBaggage(trace.ContextWithSpanContext(context.Background(), sc), b)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	resp.Body.Close()
+
+	if got.Get(RequestIDHeader) != "req-1" {
+		t.Errorf("expected X-Request-ID req-1 but got %q", got.Get(RequestIDHeader))
+	}
+	if got.Get("Traceparent") != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("expected the trace context to be injected but got %q", got.Get("Traceparent"))
+	}
+	if got.Get("Baggage") != "request.id=req-1" {
+		t.Errorf("expected the baggage to be injected but got %q", got.Get("Baggage"))
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Errorf("expected the caller's request not to be modified")
+	}
+}
@@ -0,0 +1,119 @@
+package i18n
+
+// conditions holds the text of every WMO weather interpretation code, as
+// answered by Open-Meteo, in every supported language. The texts of
+// WeatherAPI come already localized.
+var conditions = map[Lang]map[int]string{
+	English: {
+		0:  "Clear sky",
+		1:  "Mainly clear",
+		2:  "Partly cloudy",
+		3:  "Overcast",
+		45: "Fog",
+		48: "Depositing rime fog",
+		51: "Light drizzle",
+		53: "Moderate drizzle",
+		55: "Dense drizzle",
+		56: "Light freezing drizzle",
+		57: "Dense freezing drizzle",
+		61: "Slight rain",
+		63: "Moderate rain",
+		65: "Heavy rain",
+		66: "Light freezing rain",
+		67: "Heavy freezing rain",
+		71: "Slight snow fall",
+		73: "Moderate snow fall",
+		75: "Heavy snow fall",
+		77: "Snow grains",
+		80: "Slight rain showers",
+		81: "Moderate rain showers",
+		82: "Violent rain showers",
+		85: "Slight snow showers",
+		86: "Heavy snow showers",
+		95: "Thunderstorm",
+		96: "Thunderstorm with slight hail",
+		99: "Thunderstorm with heavy hail",
+	},
+	Portuguese: {
+		0:  "Céu limpo",
+		1:  "Predominantemente limpo",
+		2:  "Parcialmente nublado",
+		3:  "Encoberto",
+		45: "Nevoeiro",
+		48: "Nevoeiro com geada",
+		51: "Garoa fraca",
+		53: "Garoa moderada",
+		55: "Garoa intensa",
+		56: "Garoa congelante fraca",
+		57: "Garoa congelante intensa",
+		61: "Chuva fraca",
+		63: "Chuva moderada",
+		65: "Chuva forte",
+		66: "Chuva congelante fraca",
+		67: "Chuva congelante forte",
+		71: "Neve fraca",
+		73: "Neve moderada",
+		75: "Neve forte",
+		77: "Grãos de neve",
+		80: "Pancadas de chuva fracas",
+		81: "Pancadas de chuva moderadas",
+		82: "Pancadas de chuva violentas",
+		85: "Pancadas de neve fracas",
+		86: "Pancadas de neve fortes",
+		95: "Trovoada",
+		96: "Trovoada com granizo fraco",
+		99: "Trovoada com granizo forte",
+	},
+	Spanish: {
+		0:  "Cielo despejado",
+		1:  "Mayormente despejado",
+		2:  "Parcialmente nublado",
+		3:  "Cubierto",
+		45: "Niebla",
+		48: "Niebla con escarcha",
+		51: "Llovizna ligera",
+		53: "Llovizna moderada",
+		55: "Llovizna densa",
+		56: "Llovizna helada ligera",
+		57: "Llovizna helada densa",
+		61: "Lluvia ligera",
+		63: "Lluvia moderada",
+		65: "Lluvia intensa",
+		66: "Lluvia helada ligera",
+		67: "Lluvia helada intensa",
+		71: "Nevada ligera",
+		73: "Nevada moderada",
+		75: "Nevada intensa",
+		77: "Granos de nieve",
+		80: "Chubascos ligeros",
+		81: "Chubascos moderados",
+		82: "Chubascos violentos",
+		85: "Chubascos de nieve ligeros",
+		86: "Chubascos de nieve intensos",
+		95: "Tormenta",
+		96: "Tormenta con granizo ligero",
+		99: "Tormenta con granizo intenso",
+	},
+}
+
+// unknownCondition is the text of the codes missing from conditions.
+var unknownCondition = map[Lang]string{
+	English:    "Unknown",
+	Portuguese: "Desconhecido",
+	Spanish:    "Desconocido",
+}
+
+// Condition returns the text of a WMO weather code in lang. Languages
+// without a catalog fall back to Default.
+func Condition(lang Lang, code int) string {
+
+	if _, ok := conditions[lang]; !ok {
+		lang = Default
+	}
+
+	if text, ok := conditions[lang][code]; ok {
+		return text
+	}
+
+	return unknownCondition[lang]
+}
@@ -0,0 +1,20 @@
+package i18n
+
+import "testing"
+
+func TestConditionCatalogsAreComplete(t *testing.T) {
+
+	for _, lang := range Supported {
+		for code := range conditions[Default] {
+			if _, ok := conditions[lang][code]; !ok {
+				t.Errorf("%s: missing condition for WMO code %d", lang, code)
+			}
+		}
+		if len(conditions[lang]) != len(conditions[Default]) {
+			t.Errorf("%s: expected %d conditions but got %d", lang, len(conditions[Default]), len(conditions[lang]))
+		}
+		if unknownCondition[lang] == "" {
+			t.Errorf("%s: missing text for unknown conditions", lang)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package i18n negotiates the language of a response from its
+// Accept-Language header and holds the catalogs of the messages answered by
+// the services.
+package i18n
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// Lang is a supported response language, as a BCP 47 tag.
+type Lang string
+
+const (
+	English    Lang = "en"
+	Portuguese Lang = "pt-BR"
+	Spanish    Lang = "es"
+)
+
+// Default is answered when the client accepts no supported language, so that
+// clients not sending Accept-Language keep getting the original messages.
+const Default = English
+
+// Supported lists the languages with a catalog, Default first.
+var Supported = []Lang{English, Portuguese, Spanish}
+
+var matcher = language.NewMatcher([]language.Tag{
+	language.English,
+	language.BrazilianPortuguese,
+	language.Spanish,
+})
+
+// Negotiate picks the supported language that best matches an
+// Accept-Language header value, e.g. "pt-PT;q=0.9, en;q=0.5" gives
+// Portuguese.
+func Negotiate(acceptLanguage string) Lang {
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+
+	return Supported[index]
+}
+
+type contextKey struct{}
+
+// WithLang returns a copy of ctx carrying lang.
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext returns the language carried by ctx, or Default.
+func FromContext(ctx context.Context) Lang {
+
+	lang, ok := ctx.Value(contextKey{}).(Lang)
+	if !ok {
+		return Default
+	}
+
+	return lang
+}
+
+// Handler negotiates the language of every request to h, storing it in the
+// request context and announcing it with the Content-Language header.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		lang := Negotiate(r.Header.Get("Accept-Language"))
+
+		w.Header().Set("Content-Language", string(lang))
+		w.Header().Add("Vary", "Accept-Language")
+
+		h.ServeHTTP(w, r.WithContext(WithLang(r.Context(), lang)))
+	})
+}
@@ -0,0 +1,77 @@
+package i18n_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+)
+
+func TestNegotiate(t *testing.T) {
+
+	tests := []struct {
+		header string
+		want   i18n.Lang
+	}{
+		{header: "", want: i18n.English},
+		{header: "pt-BR", want: i18n.Portuguese},
+		{header: "pt", want: i18n.Portuguese},
+		{header: "pt-PT;q=0.9, en;q=0.5", want: i18n.Portuguese},
+		{header: "es-AR,es;q=0.9", want: i18n.Spanish},
+		{header: "fr-FR, es;q=0.3", want: i18n.Spanish},
+		{header: "de", want: i18n.English},
+		{header: "en-US,en;q=0.9,pt-BR;q=0.8", want: i18n.English},
+		{header: "*;q=garbage", want: i18n.English},
+	}
+
+	for _, tt := range tests {
+		if got := i18n.Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q): expected %s but got %s", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+
+	tests := []struct {
+		lang i18n.Lang
+		code i18n.Code
+		args []any
+		want string
+	}{
+		{lang: i18n.English, code: i18n.CodeInvalidZipcode, want: "invalid zipcode"},
+		{lang: i18n.Portuguese, code: i18n.CodeInvalidZipcode, want: "CEP inválido"},
+		{lang: i18n.Spanish, code: i18n.CodeZipcodeNotFound, want: "no se pudo encontrar el código postal"},
+		{lang: i18n.Portuguese, code: i18n.CodeTooManyZipcodes, args: []any{10}, want: "CEPs demais, o limite é 10"},
+		{lang: "fr", code: i18n.CodeNotFound, want: "not found"},
+		{lang: i18n.English, code: "unknown_code", want: "unknown_code"},
+	}
+
+	for _, tt := range tests {
+		if got := i18n.Message(tt.lang, tt.code, tt.args...); got != tt.want {
+			t.Errorf("Message(%s, %s): expected %q but got %q", tt.lang, tt.code, tt.want, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	var got i18n.Lang
+	h := i18n.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = i18n.FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "es")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if got != i18n.Spanish {
+		t.Errorf("expected context language es but got %s", got)
+	}
+	if rec.Header().Get("Content-Language") != "es" || rec.Header().Get("Vary") != "Accept-Language" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+}
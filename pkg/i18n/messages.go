@@ -0,0 +1,141 @@
+package i18n
+
+import "fmt"
+
+// Code identifies an error answered by the services. Codes are stable and
+// meant for machines; the message that goes along is localized.
+type Code string
+
+const (
+	CodeNoZipcode               Code = "no_zipcode"
+	CodeInvalidZipcode          Code = "invalid_zipcode"
+	CodeZipcodeNotFound         Code = "zipcode_not_found"
+	CodeTooManyZipcodes         Code = "too_many_zipcodes"
+	CodeInvalidUnits            Code = "invalid_units"
+	CodeInvalidFields           Code = "invalid_fields"
+	CodeInvalidDays             Code = "invalid_days"
+	CodeInvalidDate             Code = "invalid_date"
+	CodeInvalidPrecision        Code = "invalid_precision"
+	CodeInvalidCompat           Code = "invalid_compat"
+	CodeInvalidColumn           Code = "invalid_column"
+	CodeInvalidLine             Code = "invalid_line"
+	CodeUnsupportedMediaType    Code = "unsupported_media_type"
+	CodeBadRequest              Code = "bad_request"
+	CodeNotFound                Code = "not_found"
+	CodeMethodNotAllowed        Code = "method_not_allowed"
+	CodeTooManyRequests         Code = "too_many_requests"
+	CodeUnauthorized            Code = "unauthorized"
+	CodeForbidden               Code = "forbidden"
+	CodeInvalidSignature        Code = "invalid_signature"
+	CodeUpstreamBudgetExhausted Code = "upstream_budget_exhausted"
+	CodeQueryTooDeep            Code = "query_too_deep"
+	CodeQueryTooComplex         Code = "query_too_complex"
+	CodeTooManyStreams          Code = "too_many_streams"
+	CodeInternalError           Code = "internal_error"
+)
+
+// catalogs holds the message of every code in every supported language. The
+// English messages are the ones the services always answered.
+var catalogs = map[Lang]map[Code]string{
+	English: {
+		CodeNoZipcode:               "no zipcode provided",
+		CodeInvalidZipcode:          "invalid zipcode",
+		CodeZipcodeNotFound:         "can not find zipcode",
+		CodeTooManyZipcodes:         "too many zipcodes, limit is %d",
+		CodeInvalidUnits:            "invalid units",
+		CodeInvalidFields:           "invalid fields",
+		CodeInvalidDays:             "invalid days",
+		CodeInvalidDate:             "invalid date",
+		CodeInvalidPrecision:        "invalid precision",
+		CodeInvalidCompat:           "invalid compat",
+		CodeInvalidColumn:           "invalid column %q",
+		CodeInvalidLine:             "invalid line",
+		CodeUnsupportedMediaType:    "content type must be text/csv or application/x-ndjson",
+		CodeBadRequest:              "bad request",
+		CodeNotFound:                "not found",
+		CodeMethodNotAllowed:        "method not allowed",
+		CodeTooManyRequests:         "too many requests",
+		CodeUnauthorized:            "missing or invalid API key",
+		CodeForbidden:               "API key not allowed to %s",
+		CodeInvalidSignature:        "missing or invalid request signature",
+		CodeUpstreamBudgetExhausted: "weather provider budget exhausted, only cached data is available",
+		CodeQueryTooDeep:            "query depth %d exceeds the limit of %d",
+		CodeQueryTooComplex:         "query complexity %d exceeds the limit of %d",
+		CodeTooManyStreams:          "too many open streams, limit is %d",
+		CodeInternalError:           "internal server error",
+	},
+	Portuguese: {
+		CodeNoZipcode:               "nenhum CEP informado",
+		CodeInvalidZipcode:          "CEP inválido",
+		CodeZipcodeNotFound:         "não foi possível encontrar o CEP",
+		CodeTooManyZipcodes:         "CEPs demais, o limite é %d",
+		CodeInvalidUnits:            "unidades inválidas",
+		CodeInvalidFields:           "campos inválidos",
+		CodeInvalidDays:             "número de dias inválido",
+		CodeInvalidDate:             "data inválida",
+		CodeInvalidPrecision:        "precisão inválida",
+		CodeInvalidCompat:           "modo de compatibilidade inválido",
+		CodeInvalidColumn:           "coluna inválida %q",
+		CodeInvalidLine:             "linha inválida",
+		CodeUnsupportedMediaType:    "o tipo de conteúdo deve ser text/csv ou application/x-ndjson",
+		CodeBadRequest:              "requisição inválida",
+		CodeNotFound:                "não encontrado",
+		CodeMethodNotAllowed:        "método não permitido",
+		CodeTooManyRequests:         "requisições demais",
+		CodeUnauthorized:            "chave de API ausente ou inválida",
+		CodeForbidden:               "chave de API sem permissão para %s",
+		CodeInvalidSignature:        "assinatura da requisição ausente ou inválida",
+		CodeUpstreamBudgetExhausted: "cota do provedor de clima esgotada, só há dados em cache",
+		CodeQueryTooDeep:            "a profundidade da consulta %d excede o limite de %d",
+		CodeQueryTooComplex:         "a complexidade da consulta %d excede o limite de %d",
+		CodeTooManyStreams:          "streams abertos demais, o limite é %d",
+		CodeInternalError:           "erro interno do servidor",
+	},
+	Spanish: {
+		CodeNoZipcode:               "no se informó ningún código postal",
+		CodeInvalidZipcode:          "código postal inválido",
+		CodeZipcodeNotFound:         "no se pudo encontrar el código postal",
+		CodeTooManyZipcodes:         "demasiados códigos postales, el límite es %d",
+		CodeInvalidUnits:            "unidades inválidas",
+		CodeInvalidFields:           "campos inválidos",
+		CodeInvalidDays:             "número de días inválido",
+		CodeInvalidDate:             "fecha inválida",
+		CodeInvalidPrecision:        "precisión inválida",
+		CodeInvalidCompat:           "modo de compatibilidad inválido",
+		CodeInvalidColumn:           "columna inválida %q",
+		CodeInvalidLine:             "línea inválida",
+		CodeUnsupportedMediaType:    "el tipo de contenido debe ser text/csv o application/x-ndjson",
+		CodeBadRequest:              "solicitud incorrecta",
+		CodeNotFound:                "no encontrado",
+		CodeMethodNotAllowed:        "método no permitido",
+		CodeTooManyRequests:         "demasiadas solicitudes",
+		CodeUnauthorized:            "clave de API ausente o inválida",
+		CodeForbidden:               "clave de API sin permiso para %s",
+		CodeInvalidSignature:        "firma de la solicitud ausente o inválida",
+		CodeUpstreamBudgetExhausted: "cuota del proveedor del clima agotada, solo hay datos en caché",
+		CodeQueryTooDeep:            "la profundidad de la consulta %d supera el límite de %d",
+		CodeQueryTooComplex:         "la complejidad de la consulta %d supera el límite de %d",
+		CodeTooManyStreams:          "demasiados streams abiertos, el límite es %d",
+		CodeInternalError:           "error interno del servidor",
+	},
+}
+
+// Message returns the message of code in lang, formatted with args. Codes
+// missing from the catalog of lang fall back to Default, then to the code
+// itself.
+func Message(lang Lang, code Code, args ...any) string {
+
+	msg, ok := catalogs[lang][code]
+	if !ok {
+		msg, ok = catalogs[Default][code]
+	}
+	if !ok {
+		return string(code)
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+
+	return msg
+}
@@ -0,0 +1,17 @@
+package i18n
+
+import "testing"
+
+func TestCatalogsAreComplete(t *testing.T) {
+
+	for _, lang := range Supported {
+		for code := range catalogs[Default] {
+			if _, ok := catalogs[lang][code]; !ok {
+				t.Errorf("%s: missing message for %s", lang, code)
+			}
+		}
+		if len(catalogs[lang]) != len(catalogs[Default]) {
+			t.Errorf("%s: expected %d messages but got %d", lang, len(catalogs[Default]), len(catalogs[lang]))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package mtls builds the TLS configurations of the hop between the
+// services, authenticating both ends with certificates that are reloaded
+// from disk whenever they change, so that rotating them needs no restart.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files locates the PEM files of one end of the hop: its own certificate
+// and key, and the CA that signs the certificates of the other end.
+type Files struct {
+	Cert string
+	Key  string
+	CA   string
+}
+
+// Reloader holds the certificate and CA pool read from Files, reading them
+// again when one of the files is modified. If a reload fails, the previous
+// certificate and pool are kept.
+type Reloader struct {
+	files Files
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+	versions [3]version
+}
+
+// version identifies the content of a file without reading it.
+type version struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader returns a Reloader of files. Nothing is read until Load or
+// the first handshake.
+func NewReloader(files Files) *Reloader {
+	return &Reloader{files: files}
+}
+
+// Load reads the files again if any of them changed since the last call.
+func (r *Reloader) Load() error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.load()
+}
+
+func (r *Reloader) load() error {
+
+	var versions [3]version
+	for i, path := range []string{r.files.Cert, r.files.Key, r.files.CA} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("error to stat %s: %w", path, err)
+		}
+		versions[i] = version{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	if r.cert != nil && versions == r.versions {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if r.files.Cert != "" {
+		c, err := tls.LoadX509KeyPair(r.files.Cert, r.files.Key)
+		if err != nil {
+			return fmt.Errorf("error to load certificate: %w", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.files.CA != "" {
+		pem, err := os.ReadFile(r.files.CA)
+		if err != nil {
+			return fmt.Errorf("error to read CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in CA %s", r.files.CA)
+		}
+	}
+
+	if cert == nil {
+		cert = &tls.Certificate{}
+	}
+
+	if r.cert != nil {
+		log.Println("tls certificates reloaded:", r.files.Cert)
+	}
+	r.cert, r.pool, r.versions = cert, pool, versions
+
+	return nil
+}
+
+// current reloads the files if needed and returns the certificate and pool
+// to use in a handshake.
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		if r.cert == nil {
+			return nil, nil, err
+		}
+		log.Println("error to reload tls certificates, keeping the previous ones:", err)
+	}
+
+	return r.cert, r.pool, nil
+}
+
+// ServerConfig returns the configuration of a server presenting the
+// certificate. With a CA, clients must present a certificate signed by it.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+
+			cert, pool, err := r.current()
+			if err != nil {
+				return nil, err
+			}
+
+			// The config returned here replaces the whole server
+			// config, so it advertises HTTP/2, required by gRPC, too.
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns the configuration of a client presenting the
+// certificate. With a CA, the server must present a certificate signed by
+// it instead of one trusted by the system.
+func (r *Reloader) ClientConfig() *tls.Config {
+
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, err := r.current()
+			return cert, err
+		},
+	}
+
+	if r.files.CA == "" {
+		return cfg
+	}
+
+	// RootCAs can not change once the config is in use, so the server is
+	// verified by hand against the current pool.
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+
+		_, pool, err := r.current()
+		if err != nil {
+			return err
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("server presented no certificate")
+		}
+
+		opts := x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         pool,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, c := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+
+		_, err = cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+
+	return cfg
+}
@@ -0,0 +1,171 @@
+package mtls_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/mtls"
+)
+
+// authority issues the certificates of a test.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for name, signed by ca, to dir and returns the
+// paths of the certificate and key.
+func (ca *authority) issue(t *testing.T, dir, name string, serial int64) (string, string) {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	write(t, certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	write(t, keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	return certPath, keyPath
+}
+
+func write(t *testing.T, path string, b []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	caPath := filepath.Join(dir, "ca.crt")
+	write(t, caPath, ca.pem)
+
+	serverCert, serverKey := ca.issue(t, dir, "service-b", 2)
+	clientCert, clientKey := ca.issue(t, dir, "service-a", 3)
+
+	server := mtls.NewReloader(mtls.Files{Cert: serverCert, Key: serverKey, CA: caPath})
+	if err := server.Load(); err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = server.ServerConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := func(files mtls.Files) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: mtls.NewReloader(files).ClientConfig()}}
+	}
+	serial := func(c *http.Client) (int64, error) {
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		c.CloseIdleConnections()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+	}
+
+	withCert := client(mtls.Files{Cert: clientCert, Key: clientKey, CA: caPath})
+	if got, err := serial(withCert); err != nil || got != 2 {
+		t.Fatalf("expected the server certificate 2 but got %d %v", got, err)
+	}
+
+	if _, err := serial(client(mtls.Files{CA: caPath})); err == nil {
+		t.Errorf("expected a client without certificate to be refused")
+	}
+
+	other := newAuthority(t)
+	otherCA := filepath.Join(dir, "other-ca.crt")
+	write(t, otherCA, other.pem)
+	if _, err := serial(client(mtls.Files{Cert: clientCert, Key: clientKey, CA: otherCA})); err == nil {
+		t.Errorf("expected a server signed by another CA to be refused")
+	}
+
+	// Rotating the files on disk is picked up by the next handshake. The
+	// modification time is moved so that fast rewrites are noticed.
+	ca.issue(t, dir, "service-b", 4)
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(serverCert, later, later)
+	_ = os.Chtimes(serverKey, later, later)
+
+	if got, err := serial(withCert); err != nil || got != 4 {
+		t.Errorf("expected the rotated server certificate 4 but got %d %v", got, err)
+	}
+
+	// A broken rotation keeps the previous certificate.
+	write(t, serverCert, []byte("garbage"))
+	if got, err := serial(withCert); err != nil || got != 4 {
+		t.Errorf("expected the previous certificate 4 to be kept but got %d %v", got, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	dir := t.TempDir()
+
+	if err := mtls.NewReloader(mtls.Files{Cert: filepath.Join(dir, "missing.crt"), Key: filepath.Join(dir, "missing.key")}).Load(); err == nil {
+		t.Errorf("expected an error for missing files")
+	}
+
+	empty := filepath.Join(dir, "empty.crt")
+	write(t, empty, []byte("no certificates here"))
+	if err := mtls.NewReloader(mtls.Files{CA: empty}).Load(); err == nil {
+		t.Errorf("expected an error for a CA without certificates")
+	}
+}
@@ -0,0 +1,74 @@
+// Package problem writes the error responses of the services as RFC 7807
+// problem details (application/problem+json).
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContentType is the media type of a Problem.
+const ContentType = "application/problem+json"
+
+// TypePrefix prefixes the error code to form the type URI of a Problem, so
+// that every code has its own problem type.
+const TypePrefix = "urn:cep-weather:problem:"
+
+// Problem is an RFC 7807 problem details object. Code is stable and meant for
+// machines; Detail is localized. TraceID links the error to its trace.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// New returns the Problem of code answered with status, its detail
+// formatted with args in the language and trace carried by ctx.
+func New(ctx context.Context, status int, code i18n.Code, args ...any) Problem {
+
+	p := Problem{
+		Type:   TypePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: i18n.Message(i18n.FromContext(ctx), code, args...),
+		Code:   string(code),
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		p.TraceID = sc.TraceID().String()
+	}
+
+	return p
+}
+
+// Write replies the Problem of code to r. Handlers should pass r with the
+// context of their span so that the reply carries its trace ID.
+func Write(w http.ResponseWriter, r *http.Request, status int, code i18n.Code, args ...any) {
+
+	p := New(r.Context(), status, code, args...)
+	p.Instance = r.URL.Path
+
+	Reply(w, p)
+}
+
+// Reply writes p with its status, for problems built with New and then
+// adjusted, such as those relayed from another service.
+func Reply(w http.ResponseWriter, p Problem) {
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+
+	err := json.NewEncoder(w).Encode(p)
+	if err != nil {
+		log.Println("error to try reply request:", err)
+	}
+}
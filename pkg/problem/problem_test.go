@@ -0,0 +1,74 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWrite(t *testing.T) {
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+
+	tests := []struct {
+		name        string
+		lang        i18n.Lang
+		traced      bool
+		status      int
+		code        i18n.Code
+		args        []any
+		wantTitle   string
+		wantDetail  string
+		wantTraceID string
+	}{
+		{name: "untraced", lang: i18n.English, status: http.StatusUnprocessableEntity, code: i18n.CodeInvalidZipcode, wantTitle: "Unprocessable Entity", wantDetail: "invalid zipcode"},
+		{name: "traced", lang: i18n.English, traced: true, status: http.StatusNotFound, code: i18n.CodeZipcodeNotFound, wantTitle: "Not Found", wantDetail: "can not find zipcode", wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "localized with args", lang: i18n.Portuguese, status: http.StatusRequestEntityTooLarge, code: i18n.CodeTooManyZipcodes, args: []any{10}, wantTitle: "Request Entity Too Large", wantDetail: "CEPs demais, o limite é 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/weather/123?units=c", nil)
+			ctx := i18n.WithLang(r.Context(), tt.lang)
+			if tt.traced {
+				ctx = trace.ContextWithSpanContext(ctx, sc)
+			}
+			w := httptest.NewRecorder()
+
+			problem.Write(w, r.WithContext(ctx), tt.status, tt.code, tt.args...)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d but got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != problem.ContentType {
+				t.Errorf("expected Content-Type %q but got %q", problem.ContentType, got)
+			}
+
+			var got problem.Problem
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("error decoding problem: %v", err)
+			}
+
+			want := problem.Problem{
+				Type:     problem.TypePrefix + string(tt.code),
+				Title:    tt.wantTitle,
+				Status:   tt.status,
+				Detail:   tt.wantDetail,
+				Instance: "/v1/weather/123",
+				TraceID:  tt.wantTraceID,
+				Code:     string(tt.code),
+			}
+			if got != want {
+				t.Errorf("expected %+v but got %+v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Package ratelimit throttles the clients of a service with one token
+// bucket per client, so that a single client can not burn the quota of the
+// upstream providers.
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/webserver"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "ratelimit"
+
+// DefaultTier names the limit of the clients whose API key has no tier, or
+// that were not authenticated.
+const DefaultTier = "default"
+
+// DefaultLimit is the limit of DefaultTier, overridable with the RATE_LIMIT
+// environment variable.
+var DefaultLimit = Limit{Rate: 1, Burst: 60}
+
+// sweepInterval is how often buckets left idle until full are dropped.
+const sweepInterval = time.Minute
+
+var throttled metric.Int64Counter
+
+func init() {
+
+	var err error
+
+	throttled, err = otel.Meter(name).Int64Counter("ratelimit.throttled",
+		metric.WithDescription("Requests refused because their client exceeded its rate limit"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		log.Println("error to create throttled counter:", err)
+	}
+}
+
+// Limit lets a client make Burst requests at once, refilled at Rate
+// requests per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit written as "<requests>/<s|m|h>", optionally
+// followed by ":<burst>", e.g. "60/m" or "600/m:20". Without a burst the
+// client may spend the requests of a whole window at once.
+func ParseLimit(v string) (Limit, error) {
+
+	spec, burst, hasBurst := strings.Cut(strings.TrimSpace(v), ":")
+
+	n, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", v)
+	}
+
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", v)
+	}
+
+	var window time.Duration
+	switch unit {
+	case "s":
+		window = time.Second
+	case "m":
+		window = time.Minute
+	case "h":
+		window = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit unit %q", v)
+	}
+
+	l := Limit{Rate: float64(requests) / window.Seconds(), Burst: requests}
+
+	if hasBurst {
+		l.Burst, err = strconv.Atoi(burst)
+		if err != nil || l.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid rate limit burst %q", v)
+		}
+	}
+
+	return l, nil
+}
+
+// Config holds the limits enforced by a Limiter.
+type Config struct {
+	// Default limits the clients without a tier: by API key if
+	// authenticated, otherwise by IP. A zero Default disables the Limiter.
+	Default Limit
+	// Tiers maps a tier name to its limit.
+	Tiers map[string]Limit
+	// Keys maps the ID of an API key to the tier of its client.
+	Keys map[string]string
+	// Identify returns the API key of the requests not authenticated yet,
+	// such as apikey.Keyring.Identify, so that the Limiter can run ahead of
+	// the authentication and throttle the requests it would refuse too.
+	Identify func(*http.Request) (apikey.Key, bool)
+}
+
+// ConfigFromEnv reads the Config from the environment:
+//
+//	RATE_LIMIT=60/m                     limit of DefaultTier, "0" disables
+//	RATE_LIMIT_TIERS=free=60/m,pro=600/m:50
+//	RATE_LIMIT_KEYS=partner-1=free,partner-2=pro  (API key IDs)
+//
+// Invalid entries are logged and skipped.
+func ConfigFromEnv() Config {
+
+	cfg := Config{
+		Default: DefaultLimit,
+		Tiers:   make(map[string]Limit),
+		Keys:    make(map[string]string),
+	}
+
+	if v := os.Getenv("RATE_LIMIT"); v == "0" {
+		cfg.Default = Limit{}
+	} else if v != "" {
+		l, err := ParseLimit(v)
+		if err != nil {
+			log.Println("invalid RATE_LIMIT, using default:", v)
+		} else {
+			cfg.Default = l
+		}
+	}
+
+	for _, entry := range split(os.Getenv("RATE_LIMIT_TIERS")) {
+		tier, v, _ := strings.Cut(entry, "=")
+		l, err := ParseLimit(v)
+		if err != nil || tier == "" {
+			log.Println("invalid RATE_LIMIT_TIERS entry, ignoring:", entry)
+			continue
+		}
+		cfg.Tiers[tier] = l
+	}
+
+	for _, entry := range split(os.Getenv("RATE_LIMIT_KEYS")) {
+		id, tier, _ := strings.Cut(entry, "=")
+		if _, ok := cfg.Tiers[tier]; !ok || id == "" {
+			log.Println("invalid RATE_LIMIT_KEYS entry, ignoring:", entry)
+			continue
+		}
+		cfg.Keys[id] = tier
+	}
+
+	return cfg
+}
+
+func split(v string) []string {
+
+	var entries []string
+	for _, entry := range strings.Split(v, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Decision is the outcome of taking a token from the bucket of a client.
+type Decision struct {
+	Allowed bool
+	// Limit is the size of the bucket and Remaining the tokens left in it.
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, if not Allowed.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps one token bucket per client.
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New returns a Limiter enforcing cfg.
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of client, created with limit the
+// first time client is seen.
+func (l *Limiter) Allow(client string, limit Limit) Decision {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok || b.limit != limit {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	d := Decision{Limit: limit.Burst}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+
+	return d
+}
+
+// sweep drops the buckets that refilled completely, which behave as new
+// ones, so that clients seen once do not pile up.
+func (l *Limiter) sweep(now time.Time) {
+
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// client returns the bucket key, tier and limit of the client of r: the API
+// key authenticated by apikey.Keyring, or identified by Config.Identify,
+// otherwise its IP in DefaultTier.
+func (l *Limiter) client(r *http.Request) (string, string, Limit) {
+
+	key, ok := apikey.FromContext(r.Context())
+	if !ok && l.cfg.Identify != nil {
+		key, ok = l.cfg.Identify(r)
+	}
+
+	if ok {
+		if tier, ok := l.cfg.Keys[key.ID]; ok {
+			return "key:" + key.ID, tier, l.cfg.Tiers[tier]
+		}
+		return "key:" + key.ID, DefaultTier, l.cfg.Default
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return "ip:" + ip, DefaultTier, l.cfg.Default
+}
+
+// Handler limits the requests of every client to h, announcing the state of
+// its bucket in the RateLimit-* headers. Throttled requests get a 429
+// problem with Retry-After and are counted in ratelimit.throttled.
+func (l *Limiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		client, tier, limit := l.client(r)
+		if limit.Rate <= 0 || limit.Burst < 1 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		d := l.Allow(client, limit)
+
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceil(d.Reset)))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceil(seconds(float64(limit.Burst)/limit.Rate))))
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(attribute.String("ratelimit.tier", tier))
+
+		if d.Allowed {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		span.SetAttributes(attribute.Bool("ratelimit.throttled", true))
+		webserver.AccessLogAttrs(r.Context(), slog.String("ratelimit.tier", tier), slog.Bool("ratelimit.throttled", true))
+
+		if throttled != nil {
+			throttled.Add(r.Context(), 1, metric.WithAttributes(attribute.String("tier", tier)))
+		}
+
+		header.Set("Retry-After", strconv.Itoa(max(1, ceil(d.RetryAfter))))
+		problem.Write(w, r, http.StatusTooManyRequests, i18n.CodeTooManyRequests)
+	})
+}
+
+// ceil rounds d up to whole seconds.
+func ceil(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/apikey"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+func TestParseLimit(t *testing.T) {
+
+	tests := []struct {
+		v       string
+		want    Limit
+		wantErr bool
+	}{
+		{v: "60/m", want: Limit{Rate: 1, Burst: 60}},
+		{v: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{v: " 3600/h:100 ", want: Limit{Rate: 1, Burst: 100}},
+		{v: "60", wantErr: true},
+		{v: "60/d", wantErr: true},
+		{v: "0/m", wantErr: true},
+		{v: "60/m:0", wantErr: true},
+		{v: "many/m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.v)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q): expected error %v but got %v", tt.v, tt.wantErr, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q): expected %+v but got %+v", tt.v, tt.want, got)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+
+	t.Setenv("RATE_LIMIT", "30/m")
+	t.Setenv("RATE_LIMIT_TIERS", "free=60/m, pro=600/m:50,broken=fast")
+	t.Setenv("RATE_LIMIT_KEYS", "k1=free,k2=pro,k3=broken,k4=gold,=pro")
+
+	cfg := ConfigFromEnv()
+
+	if cfg.Default != (Limit{Rate: 0.5, Burst: 30}) {
+		t.Errorf("unexpected default %+v", cfg.Default)
+	}
+	if len(cfg.Tiers) != 2 || cfg.Tiers["pro"] != (Limit{Rate: 10, Burst: 50}) {
+		t.Errorf("unexpected tiers %+v", cfg.Tiers)
+	}
+	if len(cfg.Keys) != 2 || cfg.Keys["k1"] != "free" || cfg.Keys["k2"] != "pro" {
+		t.Errorf("unexpected keys %+v", cfg.Keys)
+	}
+
+	t.Setenv("RATE_LIMIT", "0")
+	if cfg := ConfigFromEnv(); cfg.Default != (Limit{}) {
+		t.Errorf("expected RATE_LIMIT=0 to disable the default limit but got %+v", cfg.Default)
+	}
+}
+
+func TestAllow(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	l := New(Config{})
+	l.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 1, Burst: 2}
+
+	tests := []struct {
+		advance       time.Duration
+		client        string
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{client: "a", wantAllowed: true, wantRemaining: 1},
+		{client: "a", wantAllowed: true, wantRemaining: 0},
+		{client: "a", wantAllowed: false, wantRemaining: 0, wantRetry: time.Second},
+		{client: "b", wantAllowed: true, wantRemaining: 1},
+		{advance: 500 * time.Millisecond, client: "a", wantAllowed: false, wantRetry: 500 * time.Millisecond},
+		{advance: 500 * time.Millisecond, client: "a", wantAllowed: true, wantRemaining: 0},
+		{advance: time.Hour, client: "a", wantAllowed: true, wantRemaining: 1},
+	}
+
+	for i, tt := range tests {
+
+		now = now.Add(tt.advance)
+		d := l.Allow(tt.client, limit)
+
+		if d.Allowed != tt.wantAllowed || d.Remaining != tt.wantRemaining || d.RetryAfter != tt.wantRetry || d.Limit != 2 {
+			t.Errorf("%d: unexpected decision %+v", i, d)
+		}
+	}
+
+	if len(l.buckets) != 1 {
+		t.Errorf("expected the full bucket of b to be swept but got %d buckets", len(l.buckets))
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	l := New(Config{
+		Default: Limit{Rate: 1, Burst: 1},
+		Tiers:   map[string]Limit{"pro": {Rate: 10, Burst: 2}},
+		Keys:    map[string]string{"partner-1": "pro"},
+	})
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		remoteAddr    string
+		keyID         string
+		wantStatus    int
+		wantLimit     string
+		wantRemaining string
+	}{
+		{remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK, wantLimit: "1", wantRemaining: "0"},
+		{remoteAddr: "10.0.0.1:5678", wantStatus: http.StatusTooManyRequests, wantLimit: "1", wantRemaining: "0"},
+		{remoteAddr: "10.0.0.2:1234", wantStatus: http.StatusOK, wantLimit: "1", wantRemaining: "0"},
+		{remoteAddr: "10.0.0.1:1234", keyID: "partner-1", wantStatus: http.StatusOK, wantLimit: "2", wantRemaining: "1"},
+		{remoteAddr: "10.0.0.3:1234", keyID: "partner-1", wantStatus: http.StatusOK, wantLimit: "2", wantRemaining: "0"},
+		{remoteAddr: "10.0.0.3:1234", keyID: "partner-1", wantStatus: http.StatusTooManyRequests, wantLimit: "2", wantRemaining: "0"},
+		{remoteAddr: "10.0.0.1:1234", keyID: "partner-2", wantStatus: http.StatusOK, wantLimit: "1", wantRemaining: "0"},
+	}
+
+	for i, tt := range tests {
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/weather/29902555", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.keyID != "" {
+			r = r.WithContext(apikey.WithKey(r.Context(), apikey.Key{ID: tt.keyID}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%d: expected status %d but got %d", i, tt.wantStatus, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != tt.wantLimit {
+			t.Errorf("%d: expected RateLimit-Limit %s but got %q", i, tt.wantLimit, got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%d: expected RateLimit-Remaining %s but got %q", i, tt.wantRemaining, got)
+		}
+		if w.Header().Get("RateLimit-Reset") == "" || w.Header().Get("RateLimit-Policy") == "" {
+			t.Errorf("%d: expected RateLimit-Reset and RateLimit-Policy but got %v", i, w.Header())
+		}
+
+		if tt.wantStatus != http.StatusTooManyRequests {
+			continue
+		}
+
+		var p problem.Problem
+		_ = json.NewDecoder(w.Body).Decode(&p)
+		if p.Code != "too_many_requests" || w.Header().Get("Content-Type") != problem.ContentType {
+			t.Errorf("%d: expected a too_many_requests problem but got %+v", i, p)
+		}
+		if got := w.Header().Get("Retry-After"); got != "1" {
+			t.Errorf("%d: expected Retry-After 1 but got %q", i, got)
+		}
+	}
+}
+
+func TestHandlerDisabled(t *testing.T) {
+
+	h := New(Config{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("expected no limit but got %d %v", w.Code, w.Header())
+		}
+	}
+}
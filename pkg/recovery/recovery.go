@@ -0,0 +1,68 @@
+// Package recovery reports the panics recovered by the services, so that a
+// failing request can be followed from its log line to its trace.
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "recovery"
+
+var panics metric.Int64Counter
+
+func init() {
+
+	var err error
+
+	panics, err = otel.Meter(name).Int64Counter("service.panics",
+		metric.WithDescription("Panics recovered while serving requests"),
+		metric.WithUnit("{panic}"),
+	)
+	if err != nil {
+		log.Println("error to create panic counter:", err)
+	}
+}
+
+// Record reports the panic v, recovered with stack, while serving ctx: it
+// adds an exception event to the span of ctx and marks it as failed,
+// increments the service.panics counter and logs it with the trace ID.
+func Record(ctx context.Context, v any, stack []byte) {
+
+	span := trace.SpanFromContext(ctx)
+
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+		semconv.ExceptionTypeKey.String(fmt.Sprintf("%T", v)),
+		semconv.ExceptionMessageKey.String(fmt.Sprint(v)),
+		semconv.ExceptionStacktraceKey.String(string(stack)),
+		semconv.ExceptionEscapedKey.Bool(false),
+	))
+	span.SetStatus(codes.Error, "panic")
+
+	if panics != nil {
+		panics.Add(ctx, 1)
+	}
+
+	log.Printf("panic recovered trace_id=%s: %v\n%s", span.SpanContext().TraceID(), v, stack)
+}
+
+// Worker recovers the panic of a worker goroutine, which would otherwise take
+// the whole process down, reporting it with Record and calling fail so that
+// only the item the worker was handling fails. It must be deferred directly:
+//
+//	defer recovery.Worker(ctx, func() { result.Err = errFailed })
+func Worker(ctx context.Context, fail func()) {
+
+	if v := recover(); v != nil {
+		Record(ctx, v, debug.Stack())
+		fail()
+	}
+}
@@ -0,0 +1,110 @@
+package recovery_test
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/recovery"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecord(t *testing.T) {
+
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	spans := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer("test").Start(context.Background(), "handler")
+
+	func() {
+		defer func() {
+			recovery.Record(ctx, recover(), debug.Stack())
+		}()
+		panic("boom")
+	}()
+	span.End()
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected one span but got %d", len(ended))
+	}
+	if ended[0].Status().Code != codes.Error {
+		t.Errorf("expected the span to be failed but got %v", ended[0].Status())
+	}
+
+	events := ended[0].Events()
+	if len(events) != 1 || events[0].Name != "exception" {
+		t.Fatalf("expected one exception event but got %+v", events)
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range events[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["exception.message"] != "boom" || attrs["exception.type"] != "string" || attrs["exception.stacktrace"] == "" {
+		t.Errorf("unexpected exception attributes %v", attrs)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("error collecting metrics: %v", err)
+	}
+
+	var total int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "service.panics" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	if total != 1 {
+		t.Errorf("expected service.panics=1 but got %d", total)
+	}
+}
+
+func TestWorker(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		panics     bool
+		wantFailed bool
+		wantEvents int
+	}{
+		{name: "panic", panics: true, wantFailed: true, wantEvents: 1},
+		{name: "no panic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			spans := tracetest.NewSpanRecorder()
+			ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)).Tracer("test").Start(context.Background(), "worker")
+
+			failed := false
+			func() {
+				defer recovery.Worker(ctx, func() { failed = true })
+				if tt.panics {
+					panic("boom")
+				}
+			}()
+			span.End()
+
+			if failed != tt.wantFailed {
+				t.Errorf("expected failed to be %v but got %v", tt.wantFailed, failed)
+			}
+			if events := spans.Ended()[0].Events(); len(events) != tt.wantEvents {
+				t.Errorf("expected %d exception events but got %+v", tt.wantEvents, events)
+			}
+		})
+	}
+}
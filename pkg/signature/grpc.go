@@ -0,0 +1,119 @@
+package signature
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC calls carry the signature in the metadata keys of the headers. It
+// covers the timestamp, the nonce and the full method, signed as the POST
+// of an empty body to it: the messages go through the stream after the
+// metadata, so their integrity is left to TLS.
+var (
+	metadataSignature = strings.ToLower(HeaderSignature)
+	metadataTimestamp = strings.ToLower(HeaderTimestamp)
+	metadataNonce     = strings.ToLower(HeaderNonce)
+)
+
+// signContext returns ctx with the signature of a call to method in its
+// outgoing metadata.
+func signContext(ctx context.Context, key []byte, method string, now time.Time) context.Context {
+
+	timestamp, nonce := stamp(now)
+
+	return metadata.AppendToOutgoingContext(ctx,
+		metadataTimestamp, timestamp,
+		metadataNonce, nonce,
+		metadataSignature, sign(key, timestamp, nonce, http.MethodPost, method, nil),
+	)
+}
+
+// UnaryClientInterceptor signs every unary call with key.
+func UnaryClientInterceptor(key []byte) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(signContext(ctx, key, method, time.Now()), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor signs every streaming call with key.
+func StreamClientInterceptor(key []byte) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(signContext(ctx, key, method, time.Now()), desc, cc, method, opts...)
+	}
+}
+
+// VerifyContext checks the signature in the incoming metadata of a call to
+// method.
+func (v *Verifier) VerifyContext(ctx context.Context, method string) error {
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	signature, timestamp, nonce := first(metadataSignature), first(metadataTimestamp), first(metadataNonce)
+
+	now, err := v.checkStamp(signature, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+
+	return v.checkSignature(signature, timestamp, nonce, http.MethodPost, method, nil, now)
+}
+
+// healthPrefix is the method prefix of the standard health service, left
+// open to the probes that can not sign their calls.
+const healthPrefix = "/grpc.health.v1.Health/"
+
+// unauthenticated is the status answered to the calls whose signature does
+// not verify.
+func unauthenticated(ctx context.Context, method string, err error) error {
+	log.Println("call signature rejected:", method, err)
+	return status.Error(codes.Unauthenticated, i18n.Message(i18n.FromContext(ctx), i18n.CodeInvalidSignature))
+}
+
+// UnaryServerInterceptor refuses the unary calls whose signature does not
+// verify, except those to the health service.
+func (v *Verifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+
+		if strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(ctx, req)
+		}
+
+		if err := v.VerifyContext(ctx, info.FullMethod); err != nil {
+			return nil, unauthenticated(ctx, info.FullMethod, err)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses the streaming calls whose signature does
+// not verify, except those to the health service.
+func (v *Verifier) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		if strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(srv, ss)
+		}
+
+		if err := v.VerifyContext(ss.Context(), info.FullMethod); err != nil {
+			return unauthenticated(ss.Context(), info.FullMethod, err)
+		}
+
+		return handler(srv, ss)
+	}
+}
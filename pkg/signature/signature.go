@@ -0,0 +1,366 @@
+// Package signature signs the requests between the services with a shared
+// HMAC key, so that a service can refuse calls that did not come from its
+// peer, without the certificates mutual TLS needs.
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+// Headers carrying the signature of a request.
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+
+	// HeaderDigest carries the SHA-256 of the body (RFC 9530), which lets
+	// a streamed body be verified as it is read instead of buffered.
+	HeaderDigest = "Content-Digest"
+)
+
+// DefaultMaxSkew is how far the timestamp of a signed request may be from
+// the clock of the service verifying it.
+const DefaultMaxSkew = 5 * time.Minute
+
+// MaxBodySize bounds the bodies read to verify their signature.
+const MaxBodySize = 10 << 20
+
+// KeyFromEnv returns the key in REQUEST_SIGNING_KEY, nil if unset.
+func KeyFromEnv() []byte {
+
+	if v := os.Getenv("REQUEST_SIGNING_KEY"); v != "" {
+		return []byte(v)
+	}
+
+	return nil
+}
+
+// sign returns the HMAC-SHA256 of the request parts, in hex.
+func sign(key []byte, timestamp, nonce, method, uri string, body []byte) string {
+
+	sum := sha256.Sum256(body)
+
+	return signDigest(key, timestamp, nonce, method, uri, sum[:])
+}
+
+// signDigest is sign given the SHA-256 of the body instead of the body.
+func signDigest(key []byte, timestamp, nonce, method, uri string, digest []byte) string {
+
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%x", timestamp, nonce, method, uri, digest)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// formatDigest returns the Content-Digest value of a SHA-256 sum.
+func formatDigest(digest []byte) string {
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(digest) + ":"
+}
+
+// parseDigest returns the SHA-256 sum in a Content-Digest value, ignoring
+// the other algorithms it may list.
+func parseDigest(value string) ([]byte, error) {
+
+	for _, member := range strings.Split(value, ",") {
+
+		alg, encoded, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || !strings.EqualFold(alg, "sha-256") {
+			continue
+		}
+
+		encoded, ok = strings.CutPrefix(encoded, ":")
+		if !ok {
+			return nil, ErrMalformed
+		}
+		encoded, ok = strings.CutSuffix(encoded, ":")
+		if !ok {
+			return nil, ErrMalformed
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, ErrMalformed
+		}
+
+		return digest, nil
+	}
+
+	return nil, ErrMissing
+}
+
+// stamp returns the timestamp of now and a random nonce for a signature.
+func stamp(now time.Time) (timestamp, nonce string) {
+
+	n := make([]byte, 16)
+	_, _ = rand.Read(n)
+
+	return strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(n)
+}
+
+// Sign adds to req the signature of its method, path, query string and body
+// at now, with a random nonce, and the Content-Digest of the body.
+func Sign(req *http.Request, key []byte, now time.Time) error {
+
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("error to read body to sign: %w", err)
+		}
+		req.Body.Close()
+		body = b
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	timestamp, nonce := stamp(now)
+	sum := sha256.Sum256(body)
+
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderDigest, formatDigest(sum[:]))
+	req.Header.Set(HeaderSignature, signDigest(key, timestamp, nonce, req.Method, req.URL.RequestURI(), sum[:]))
+
+	return nil
+}
+
+// Transport wraps base so that every request is signed with key.
+func Transport(base http.RoundTripper, key []byte) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, key: key}
+}
+
+type transport struct {
+	base http.RoundTripper
+	key  []byte
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+
+	if err := Sign(req, t.key, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// Errors returned by Verify.
+var (
+	ErrMissing   = errors.New("request is not signed")
+	ErrExpired   = errors.New("signature timestamp out of range")
+	ErrInvalid   = errors.New("invalid signature")
+	ErrReplayed  = errors.New("signature nonce already used")
+	ErrTooLarge  = errors.New("signed body too large")
+	ErrMalformed = errors.New("malformed signature")
+)
+
+// Verifier checks the signatures made with a key, refusing those older than
+// MaxSkew and the nonces already seen within it.
+type Verifier struct {
+	key     []byte
+	maxSkew time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewVerifier returns a Verifier of the signatures made with key.
+func NewVerifier(key []byte) *Verifier {
+	return &Verifier{
+		key:     key,
+		maxSkew: DefaultMaxSkew,
+		now:     time.Now,
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+// Verify checks the signature of r, leaving its body readable again.
+func (v *Verifier) Verify(r *http.Request) error {
+
+	signature := r.Header.Get(HeaderSignature)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+
+	now, err := v.checkStamp(signature, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+	if err != nil {
+		return ErrTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return v.checkSignature(signature, timestamp, nonce, r.Method, r.URL.RequestURI(), body, now)
+}
+
+// VerifyStream checks the signature of r against the Content-Digest it
+// carries, without reading the body. The body is then checked against that
+// digest as it is read: the read that reaches its end fails with ErrInvalid
+// when they differ, so only the handlers reading the body to its end get it
+// checked.
+func (v *Verifier) VerifyStream(r *http.Request) error {
+
+	signature := r.Header.Get(HeaderSignature)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+
+	now, err := v.checkStamp(signature, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+
+	digest, err := parseDigest(r.Header.Get(HeaderDigest))
+	if err != nil {
+		return err
+	}
+
+	want := signDigest(v.key, timestamp, nonce, r.Method, r.URL.RequestURI(), digest)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return ErrInvalid
+	}
+
+	if err := v.use(nonce, now); err != nil {
+		return err
+	}
+
+	r.Body = &digestReader{body: r.Body, hash: sha256.New(), want: digest}
+
+	return nil
+}
+
+// digestReader hashes a body as it is read, failing at its end if the sum
+// is not the one expected.
+type digestReader struct {
+	body io.ReadCloser
+	hash hash.Hash
+	want []byte
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+
+	n, err := d.body.Read(p)
+	d.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) && !hmac.Equal(d.hash.Sum(nil), d.want) {
+		return n, ErrInvalid
+	}
+
+	return n, err
+}
+
+func (d *digestReader) Close() error {
+	return d.body.Close()
+}
+
+// checkStamp checks that a signature is present and that its timestamp is
+// within MaxSkew, returning the time of the check.
+func (v *Verifier) checkStamp(signature, timestamp, nonce string) (time.Time, error) {
+
+	if signature == "" || timestamp == "" || nonce == "" {
+		return time.Time{}, ErrMissing
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(nonce) > 64 {
+		return time.Time{}, ErrMalformed
+	}
+
+	now := v.now()
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > v.maxSkew || skew < -v.maxSkew {
+		return time.Time{}, ErrExpired
+	}
+
+	return now, nil
+}
+
+// checkSignature compares signature with the one of the request parts and
+// records its nonce.
+func (v *Verifier) checkSignature(signature, timestamp, nonce, method, uri string, body []byte, now time.Time) error {
+
+	want := sign(v.key, timestamp, nonce, method, uri, body)
+	if !hmac.Equal([]byte(signature), []byte(want)) {
+		return ErrInvalid
+	}
+
+	return v.use(nonce, now)
+}
+
+// use records nonce as seen, failing if it already was. Nonces are kept for
+// twice MaxSkew, after which their timestamp is refused anyway.
+func (v *Verifier) use(nonce string, now time.Time) error {
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if now.Sub(v.lastPrune) > v.maxSkew {
+		for n, expires := range v.nonces {
+			if now.After(expires) {
+				delete(v.nonces, n)
+			}
+		}
+		v.lastPrune = now
+	}
+
+	if _, ok := v.nonces[nonce]; ok {
+		return ErrReplayed
+	}
+	v.nonces[nonce] = now.Add(2 * v.maxSkew)
+
+	return nil
+}
+
+// Handler answers a 401 problem to the requests whose signature does not
+// verify and passes the others to h.
+func (v *Verifier) Handler(h http.Handler) http.Handler {
+	return v.Streaming(nil)(h)
+}
+
+// Streaming is Handler, except that the requests matched by stream which
+// carry a Content-Digest are checked with VerifyStream, so that their body
+// is neither buffered nor bounded by MaxBodySize.
+func (v *Verifier) Streaming(stream func(*http.Request) bool) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			verify := v.Verify
+			if stream != nil && stream(r) && r.Header.Get(HeaderDigest) != "" {
+				verify = v.VerifyStream
+			}
+
+			if err := verify(r); err != nil {
+				log.Println("request signature rejected:", err)
+				problem.Write(w, r, http.StatusUnauthorized, i18n.CodeInvalidSignature)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package signature
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+func TestVerify(t *testing.T) {
+
+	key := []byte("shared-secret")
+	now := time.Unix(1_700_000_000, 0)
+
+	signed := func(method, target, body string, at time.Time) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if err := Sign(r, key, at); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	replayed := signed(http.MethodPost, "/v1/weather:batch", `{"ceps":["29902555"]}`, now)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		wantErr error
+	}{
+		{name: "valid", request: func() *http.Request {
+			return signed(http.MethodPost, "/v1/weather:batch", `{"ceps":["29902555"]}`, now)
+		}},
+		{name: "valid with query", request: func() *http.Request {
+			return signed(http.MethodGet, "/v1/weather/29902555?units=c,f", "", now.Add(-time.Minute))
+		}},
+		{name: "unsigned", request: func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/v1/weather/29902555", nil)
+		}, wantErr: ErrMissing},
+		{name: "wrong key", request: func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/v1/weather/29902555", nil)
+			_ = Sign(r, []byte("other"), now)
+			return r
+		}, wantErr: ErrInvalid},
+		{name: "tampered body", request: func() *http.Request {
+			r := signed(http.MethodPost, "/v1/weather:batch", `{"ceps":["29902555"]}`, now)
+			r.Body = io.NopCloser(strings.NewReader(`{"ceps":["01001000"]}`))
+			return r
+		}, wantErr: ErrInvalid},
+		{name: "tampered query", request: func() *http.Request {
+			r := signed(http.MethodGet, "/v1/weather/29902555?units=c", "", now)
+			r.URL.RawQuery = "units=k"
+			return r
+		}, wantErr: ErrInvalid},
+		{name: "expired", request: func() *http.Request {
+			return signed(http.MethodGet, "/v1/weather/29902555", "", now.Add(-10*time.Minute))
+		}, wantErr: ErrExpired},
+		{name: "from the future", request: func() *http.Request {
+			return signed(http.MethodGet, "/v1/weather/29902555", "", now.Add(10*time.Minute))
+		}, wantErr: ErrExpired},
+		{name: "first use", request: func() *http.Request { return replayed.Clone(replayed.Context()) }},
+		{name: "replayed", request: func() *http.Request {
+			r := replayed.Clone(replayed.Context())
+			r.Body = io.NopCloser(strings.NewReader(`{"ceps":["29902555"]}`))
+			return r
+		}, wantErr: ErrReplayed},
+	}
+
+	v := NewVerifier(key)
+	v.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := tt.request()
+			if err := v.Verify(r); err != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr == nil && r.Method == http.MethodPost {
+				if b, _ := io.ReadAll(r.Body); string(b) != `{"ceps":["29902555"]}` {
+					t.Errorf("expected the body to be readable after verification but got %q", b)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyStream(t *testing.T) {
+
+	key := []byte("shared-secret")
+	now := time.Unix(1_700_000_000, 0)
+	body := strings.Repeat("{\"cep\":\"29902555\"}\n", 100)
+
+	signed := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1/weather:import", strings.NewReader(body))
+		if err := Sign(r, key, now); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name        string
+		request     func() *http.Request
+		wantErr     error
+		wantReadErr error
+	}{
+		{name: "valid", request: signed},
+		{name: "tampered body", request: func() *http.Request {
+			r := signed()
+			r.Body = io.NopCloser(strings.NewReader(strings.Replace(body, "29902555", "01001000", 1)))
+			return r
+		}, wantReadErr: ErrInvalid},
+		{name: "truncated body", request: func() *http.Request {
+			r := signed()
+			r.Body = io.NopCloser(strings.NewReader(body[:len(body)/2]))
+			return r
+		}, wantReadErr: ErrInvalid},
+		{name: "tampered digest", request: func() *http.Request {
+			r := signed()
+			r.Header.Set(HeaderDigest, formatDigest(make([]byte, 32)))
+			return r
+		}, wantErr: ErrInvalid},
+		{name: "malformed digest", request: func() *http.Request {
+			r := signed()
+			r.Header.Set(HeaderDigest, "sha-256=:not base64:")
+			return r
+		}, wantErr: ErrMalformed},
+		{name: "missing digest", request: func() *http.Request {
+			r := signed()
+			r.Header.Del(HeaderDigest)
+			return r
+		}, wantErr: ErrMissing},
+	}
+
+	v := NewVerifier(key)
+	v.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := tt.request()
+			if err := v.VerifyStream(r); err != tt.wantErr {
+				t.Fatalf("expected error %v but got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			b, err := io.ReadAll(r.Body)
+			if err != tt.wantReadErr {
+				t.Fatalf("expected read error %v but got %v", tt.wantReadErr, err)
+			}
+			if err == nil && string(b) != body {
+				t.Errorf("expected the body to be read through but got %d bytes", len(b))
+			}
+		})
+	}
+}
+
+func TestTransportAndHandler(t *testing.T) {
+
+	key := []byte("shared-secret")
+
+	var body string
+	srv := httptest.NewServer(NewVerifier(key).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	})))
+	defer srv.Close()
+
+	signedClient := &http.Client{Transport: Transport(nil, key)}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/weather:batch?units=c", strings.NewReader(`{"ceps":["29902555"]}`))
+	resp, err := signedClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || body != `{"ceps":["29902555"]}` {
+		t.Errorf("expected the signed request to be served but got %d %q", resp.StatusCode, body)
+	}
+	if req.Header.Get(HeaderSignature) != "" {
+		t.Errorf("expected the caller's request not to be modified")
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/weather/29902555")
+	if err != nil {
+		t.Fatalf("expected error to be nil and got %v", err)
+	}
+	defer resp.Body.Close()
+
+	var p problem.Problem
+	_ = json.NewDecoder(resp.Body).Decode(&p)
+	if resp.StatusCode != http.StatusUnauthorized || p.Code != "invalid_signature" {
+		t.Errorf("expected an invalid_signature problem but got %d %+v", resp.StatusCode, p)
+	}
+}
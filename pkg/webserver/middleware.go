@@ -0,0 +1,316 @@
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/httpclient"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/recovery"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps a handler with behaviour shared by every route.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h with mws, the first one being the outermost.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Tracing continues the trace propagated by the caller, or starts one, in a
+// server span around the request.
+func Tracing(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, name,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
+
+// RequestIDHeader and RequestIDBaggageKey carry the request ID, like the
+// clients built by httpclient send it on.
+const (
+	RequestIDHeader     = httpclient.RequestIDHeader
+	RequestIDBaggageKey = httpclient.RequestIDBaggageKey
+)
+
+type requestIDKey struct{}
+
+// RequestID keeps the request ID sent by the caller in X-Request-ID, or in
+// the baggage propagated with the trace, or generates one. It stores the ID
+// in the request context and baggage and echoes it in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = baggage.FromContext(ctx).Member(RequestIDBaggageKey).Value()
+		}
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+		if m, err := baggage.NewMember(RequestIDBaggageKey, id); err == nil {
+			if b, err := baggage.FromContext(ctx).SetMember(m); err == nil {
+				ctx = baggage.ContextWithBaggage(ctx, b)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the request ID stored by RequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts up to 128 letters, digits, '-', '_' and '.', so
+// that client IDs are safe to log and forward.
+func validRequestID(id string) bool {
+
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessLog writes the access log, one JSON object per line.
+var accessLog = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+type accessLogKey struct{}
+
+// accessEntry holds the attributes handlers add to the access log line of
+// their request.
+type accessEntry struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// AccessLogAttrs adds attrs, such as the CEP looked up, to the access log
+// line of the request carried by ctx. It does nothing outside Logging.
+func AccessLogAttrs(ctx context.Context, attrs ...slog.Attr) {
+
+	entry, ok := ctx.Value(accessLogKey{}).(*accessEntry)
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.attrs = append(entry.attrs, attrs...)
+}
+
+// Logging writes one structured access log line per request once it is
+// answered, with its method, path, status, size, duration, trace and
+// request IDs and the attributes added by the handler.
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		entry := &accessEntry{}
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.Status()),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("request_id", RequestIDFromContext(r.Context())),
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		entry.mu.Lock()
+		attrs = append(attrs, entry.attrs...)
+		entry.mu.Unlock()
+
+		accessLog.LogAttrs(r.Context(), slog.LevelInfo, "access", attrs...)
+	})
+}
+
+// Recover turns a panicking handler into a 500 problem and reports the
+// panic on the request's span, metrics and log. If the handler had already
+// started its reply, the connection is aborted instead.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rec := &responseRecorder{ResponseWriter: w}
+
+		defer func() {
+
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			recovery.Record(r.Context(), v, debug.Stack())
+
+			if rec.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+
+			problem.Write(w, r, http.StatusInternalServerError, i18n.CodeInternalError)
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// Timeout bounds the context of every request by d, so that upstream calls
+// made on behalf of the request are cancelled. Zero disables it.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+
+		if d <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Headers browsers may send to and read from the services across origins.
+var (
+	CORSAllowedHeaders = []string{"Accept-Language", "Authorization", "Content-Type", "Last-Event-ID", "X-API-Key", RequestIDHeader}
+	CORSExposedHeaders = []string{
+		"Content-Language", RequestIDHeader, "Retry-After",
+		"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+	}
+)
+
+// CORS allows browsers on origins to call the services, answering preflight
+// requests itself. "*" allows any origin. No origins disables it.
+func CORS(origins []string) Middleware {
+	return func(next http.Handler) http.Handler {
+
+		if len(origins) == 0 {
+			return next
+		}
+
+		anyOrigin := slices.Contains(origins, "*")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			origin := r.Header.Get("Origin")
+			if origin == "" || !anyOrigin && !slices.Contains(origins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			h.Set("Access-Control-Expose-Headers", strings.Join(CORSExposedHeaders, ", "))
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				h.Set("Access-Control-Allow-Headers", strings.Join(CORSAllowedHeaders, ", "))
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseRecorder records the status and size of a reply. It unwraps to
+// the underlying writer so that http.ResponseController can still flush it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Status returns the status answered, 200 if the handler wrote nothing.
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
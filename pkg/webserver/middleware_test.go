@@ -0,0 +1,239 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestChain(t *testing.T) {
+
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mw("first"), mw("second"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "first,second,handler" {
+		t.Errorf("expected first,second,handler but got %s", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{name: "generated", header: ""},
+		{name: "kept", header: "abc-123_x.y", wantKept: true},
+		{name: "unsafe replaced", header: "abc\n123"},
+		{name: "too long replaced", header: strings.Repeat("a", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var seen string
+			h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(RequestIDHeader, tt.header)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			got := w.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("expected the echoed ID %q to be the one in the context %q", got, seen)
+			}
+			if (got == tt.header) != tt.wantKept {
+				t.Errorf("expected kept=%v but got %q for %q", tt.wantKept, got, tt.header)
+			}
+		})
+	}
+}
+
+func TestRequestIDFromBaggage(t *testing.T) {
+
+	m, _ := baggage.NewMember(RequestIDBaggageKey, "from-baggage")
+	b, _ := baggage.New(m)
+
+	var seen, seenBaggage string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+		seenBaggage = baggage.FromContext(r.Context()).Member(RequestIDBaggageKey).Value()
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(baggage.ContextWithBaggage(r.Context(), b)))
+
+	if seen != "from-baggage" || seenBaggage != "from-baggage" {
+		t.Errorf("expected the baggage request ID to be kept but got %q (baggage %q)", seen, seenBaggage)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if seenBaggage == "" || seenBaggage != seen {
+		t.Errorf("expected a generated request ID %q to be put in the baggage but got %q", seen, seenBaggage)
+	}
+}
+
+func TestLogging(t *testing.T) {
+
+	var buf bytes.Buffer
+	defer func(l *slog.Logger) { accessLog = l }(accessLog)
+	accessLog = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AccessLogAttrs(r.Context(), slog.String("cep", "29902555"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}), RequestID, Logging)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/weather/29902555?units=c", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(trace.ContextWithSpanContext(r.Context(), sc)))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON line but got %q: %v", buf.String(), err)
+	}
+
+	want := map[string]any{
+		"msg":        "access",
+		"method":     "POST",
+		"path":       "/v1/weather/29902555",
+		"status":     201.0,
+		"bytes":      5.0,
+		"request_id": "req-1",
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"cep":        "29902555",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("expected %s=%v but got %v", k, v, line[k])
+		}
+	}
+	if _, ok := line["duration_ms"].(float64); !ok {
+		t.Errorf("expected duration_ms but got %v", line)
+	}
+}
+
+func TestRecover(t *testing.T) {
+
+	t.Run("before reply", func(t *testing.T) {
+
+		h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/cep/12345678", nil))
+
+		var p problem.Problem
+		_ = json.NewDecoder(w.Body).Decode(&p)
+		if w.Code != http.StatusInternalServerError || p.Code != "internal_error" {
+			t.Errorf("expected a 500 problem but got %d %+v", w.Code, p)
+		}
+	})
+
+	t.Run("after reply", func(t *testing.T) {
+
+		h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic("boom")
+		}))
+
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("expected the connection to be aborted but got %v", v)
+			}
+		}()
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestCORS(t *testing.T) {
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		origins    []string
+		method     string
+		origin     string
+		preflight  bool
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "disabled", method: http.MethodGet, origin: "https://a.example", wantStatus: http.StatusOK},
+		{name: "allowed", origins: []string{"https://a.example"}, method: http.MethodGet, origin: "https://a.example", wantStatus: http.StatusOK, wantAllow: "https://a.example"},
+		{name: "other origin", origins: []string{"https://a.example"}, method: http.MethodGet, origin: "https://b.example", wantStatus: http.StatusOK},
+		{name: "any origin", origins: []string{"*"}, method: http.MethodGet, origin: "https://b.example", wantStatus: http.StatusOK, wantAllow: "*"},
+		{name: "preflight", origins: []string{"https://a.example"}, method: http.MethodOptions, origin: "https://a.example", preflight: true, wantStatus: http.StatusNoContent, wantAllow: "https://a.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(tt.method, "/v1/cep/12345678", nil)
+			r.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			w := httptest.NewRecorder()
+			CORS(tt.origins)(next).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d but got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("expected allowed origin %q but got %q", tt.wantAllow, got)
+			}
+			if tt.preflight && w.Header().Get("Access-Control-Allow-Methods") == "" {
+				t.Errorf("expected preflight to answer the allowed methods")
+			}
+		})
+	}
+}
+
+func TestResponseRecorderFlush(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	rec := &responseRecorder{ResponseWriter: w}
+
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatalf("expected the recorder to flush but got %v", err)
+	}
+	if !w.Flushed || rec.Status() != http.StatusOK {
+		t.Errorf("expected a flushed 200 but got flushed=%v status=%d", w.Flushed, rec.Status())
+	}
+}
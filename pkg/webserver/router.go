@@ -0,0 +1,44 @@
+package webserver
+
+import (
+	"net/http"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+// HandleUnmatched wraps mux so that requests matching no route get a 404
+// problem and requests matching a route with another method get a 405
+// problem carrying the Allow header, instead of the mux's plain text replies.
+func HandleUnmatched(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		h, pattern := mux.Handler(r)
+		if pattern != "" {
+			// Serve through the mux so path values are populated.
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Let the mux decide between 404 and 405 without writing its body.
+		probe := &statusProbe{header: http.Header{}}
+		h.ServeHTTP(probe, r)
+
+		if probe.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", probe.header.Get("Allow"))
+			problem.Write(w, r, http.StatusMethodNotAllowed, i18n.CodeMethodNotAllowed)
+			return
+		}
+
+		problem.Write(w, r, http.StatusNotFound, i18n.CodeNotFound)
+	})
+}
+
+type statusProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *statusProbe) Header() http.Header         { return p.header }
+func (p *statusProbe) Write(b []byte) (int, error) { return len(b), nil }
+func (p *statusProbe) WriteHeader(status int)      { p.status = status }
@@ -0,0 +1,53 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+)
+
+func TestHandleUnmatched(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/cep/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("cep") != "12345678" {
+			t.Errorf("expected path value to be populated")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	h := HandleUnmatched(mux)
+
+	tests := []struct {
+		method    string
+		path      string
+		want      int
+		wantAllow string
+		wantMsg   string
+	}{
+		{method: http.MethodGet, path: "/v1/cep/12345678", want: http.StatusOK},
+		{method: http.MethodDelete, path: "/v1/cep/12345678", want: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD", wantMsg: "method not allowed"},
+		{method: http.MethodGet, path: "/nope", want: http.StatusNotFound, wantMsg: "not found"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("%s %s: expected Allow %q but got %q", tt.method, tt.path, tt.wantAllow, got)
+		}
+		if tt.wantMsg != "" {
+			var p problem.Problem
+			_ = json.NewDecoder(w.Body).Decode(&p)
+			if p.Detail != tt.wantMsg || p.Status != tt.want {
+				t.Errorf("%s %s: expected detail %q but got %+v", tt.method, tt.path, tt.wantMsg, p)
+			}
+		}
+	}
+}
@@ -1,107 +1,155 @@
+// Package webserver is the HTTP layer shared by the services: route
+// registration, the middleware chain every request goes through and the
+// listener.
 package webserver
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/internal/domain"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/i18n"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/mtls"
 )
 
-type ErrorMessage struct {
-	Message string `json:"message"`
+// DefaultRequestTimeout bounds the requests of routes registered with
+// HandleFunc, overridable with the REQUEST_TIMEOUT environment variable.
+const DefaultRequestTimeout = 30 * time.Second
+
+// Config holds the settings of a Server.
+type Config struct {
+	// Name identifies the service in logs and spans.
+	Name string
+	// Port is the TCP port ListenAndServe listens on.
+	Port string
+	// RequestTimeout bounds the context of every request to routes
+	// registered with HandleFunc. Zero disables it.
+	RequestTimeout time.Duration
+	// CORSOrigins lists the origins allowed to call the service from a
+	// browser; "*" allows any. Empty disables CORS.
+	CORSOrigins []string
+	// TLS serves HTTPS with its certificate, reloaded when the files
+	// change. With a CA, clients must present a certificate signed by it.
+	// A zero TLS serves plain HTTP.
+	TLS mtls.Files
 }
 
-func ReplyRequest(w http.ResponseWriter, statusCode int, msg string) error {
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-
-	replyMessage := ErrorMessage{Message: msg}
-
-	err := json.NewEncoder(w).Encode(replyMessage)
-	if err != nil {
-		w.WriteHeader(statusCode)
-		log.Println("")
-		return fmt.Errorf("error to try reply request")
+// ConfigFromEnv reads the Config of the service called name from the PORT,
+// REQUEST_TIMEOUT, CORS_ALLOWED_ORIGINS, TLS_CERT_FILE, TLS_KEY_FILE and
+// TLS_CLIENT_CA_FILE environment variables.
+func ConfigFromEnv(name string) Config {
+
+	cfg := Config{
+		Name:           name,
+		Port:           os.Getenv("PORT"),
+		RequestTimeout: DefaultRequestTimeout,
+		TLS: mtls.Files{
+			Cert: os.Getenv("TLS_CERT_FILE"),
+			Key:  os.Getenv("TLS_KEY_FILE"),
+			CA:   os.Getenv("TLS_CLIENT_CA_FILE"),
+		},
 	}
 
-	return nil
-}
-
-func handlerIndex(w http.ResponseWriter, r *http.Request) {
-
-	w.Header().Set("Content-Type", "application/json")
-
-	var data struct {
-		CEP string `json:"cep"`
+	if cfg.Port == "" {
+		cfg.Port = "8080"
 	}
 
-	err := json.NewDecoder(r.Body).Decode(&data)
-	if err != nil {
-		_ = ReplyRequest(w, http.StatusBadRequest, "no zipcode provided")
-		return
+	if v := os.Getenv("REQUEST_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Println("invalid REQUEST_TIMEOUT, using default:", v)
+		} else {
+			cfg.RequestTimeout = d
+		}
 	}
 
-	l, err := domain.NewLocation(data.CEP)
-	if err != nil {
-		log.Println(err)
-		_ = ReplyRequest(w, http.StatusUnprocessableEntity, "invalid zipcode")
-		return
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+		}
 	}
 
-	repo := domain.NewLocationRepository()
-	serv := domain.NewLocationService(repo)
-
-	var ctx context.Context
-
-	err = serv.Execute(ctx, l)
-	if err != nil {
+	return cfg
+}
 
-		errorCode := err.Error()
+// Server routes requests to the handlers registered on it through the
+// middleware chain.
+type Server struct {
+	cfg         Config
+	mux         *http.ServeMux
+	middlewares []Middleware
+}
 
-		if errorCode == "422" {
-			log.Println("invalid zipcode", l)
-			_ = ReplyRequest(w, http.StatusUnprocessableEntity, "invalid zipcode")
-		} else if errorCode == "404" {
-			log.Println("can not find zipcode")
-			_ = ReplyRequest(w, http.StatusNotFound, "can not find zipcode")
-		} else {
-			log.Println("internal server error")
-			_ = ReplyRequest(w, http.StatusInternalServerError, "internal server error")
-		}
-		return
+// New returns a Server whose chain traces, identifies, logs, localizes and
+// recovers every request, and answers CORS for cfg.CORSOrigins, in that
+// order.
+func New(cfg Config) *Server {
+	return &Server{
+		cfg: cfg,
+		mux: http.NewServeMux(),
+		middlewares: []Middleware{
+			Tracing(cfg.Name),
+			RequestID,
+			Logging,
+			i18n.Handler,
+			Recover,
+			CORS(cfg.CORSOrigins),
+		},
 	}
+}
 
-	var responseData = domain.Location{}
+// Use appends mws to the chain, after the default middlewares.
+func (s *Server) Use(mws ...Middleware) {
+	s.middlewares = append(s.middlewares, mws...)
+}
 
-	byteResponseData, err := json.Marshal(responseData)
-	if err != nil {
-		_ = ReplyRequest(w, http.StatusInternalServerError, "internal server error")
-		return
-	}
+// HandleFunc registers h for pattern, bounded by the request timeout.
+func (s *Server) HandleFunc(pattern string, h http.HandlerFunc) {
+	s.mux.Handle(pattern, Timeout(s.cfg.RequestTimeout)(h))
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(byteResponseData)
+// HandleStream registers h for pattern without the request timeout, for
+// responses streamed for as long as the client reads them.
+func (s *Server) HandleStream(pattern string, h http.HandlerFunc) {
+	s.mux.Handle(pattern, h)
+}
 
-	return
+// Handler returns the registered routes behind the middleware chain.
+func (s *Server) Handler() http.Handler {
+	return Chain(HandleUnmatched(s.mux), s.middlewares...)
 }
 
-func Start() {
+// ListenAndServe serves Handler on the configured port until the listener
+// fails, over TLS if configured.
+func (s *Server) ListenAndServe() error {
 
-	mux := http.NewServeMux()
+	srv := &http.Server{
+		Addr:              ":" + s.cfg.Port,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	mux.HandleFunc("/", handlerIndex)
+	if s.cfg.TLS.Cert == "" {
+		log.Println("Start", s.cfg.Name, "listen in port:", s.cfg.Port)
+		if err := srv.ListenAndServe(); err != nil {
+			return fmt.Errorf("error to start http server: %w", err)
+		}
+		return nil
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	certs := mtls.NewReloader(s.cfg.TLS)
+	if err := certs.Load(); err != nil {
+		return fmt.Errorf("error to load tls certificates: %w", err)
 	}
+	srv.TLSConfig = certs.ServerConfig()
 
-	log.Println("Start webserver listen in port:", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Panicf("error to start http server")
+	log.Println("Start", s.cfg.Name, "listen with tls in port:", s.cfg.Port, "client certificates required:", s.cfg.TLS.CA != "")
+	if err := srv.ListenAndServeTLS("", ""); err != nil {
+		return fmt.Errorf("error to start https server: %w", err)
 	}
+
+	return nil
 }
@@ -2,40 +2,167 @@ package webserver
 
 import (
 	"encoding/json"
-	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/mtls"
+	"github.com/tonnytg/desafio-fc-cep-and-climate-with-otel/pkg/problem"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-func TestWebserverGET(t *testing.T) {
+func TestConfigFromEnv(t *testing.T) {
 
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	w := httptest.NewRecorder()
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantPort    string
+		wantTimeout time.Duration
+		wantOrigins []string
+		wantTLS     mtls.Files
+	}{
+		{name: "defaults", wantPort: "8080", wantTimeout: DefaultRequestTimeout},
+		{
+			name:        "overrides",
+			env:         map[string]string{"PORT": "9090", "REQUEST_TIMEOUT": "5s", "CORS_ALLOWED_ORIGINS": "https://a.example, https://b.example,"},
+			wantPort:    "9090",
+			wantTimeout: 5 * time.Second,
+			wantOrigins: []string{"https://a.example", "https://b.example"},
+		},
+		{
+			name:     "tls",
+			env:      map[string]string{"TLS_CERT_FILE": "b.crt", "TLS_KEY_FILE": "b.key", "TLS_CLIENT_CA_FILE": "ca.crt"},
+			wantPort: "8080", wantTimeout: DefaultRequestTimeout,
+			wantTLS: mtls.Files{Cert: "b.crt", Key: "b.key", CA: "ca.crt"},
+		},
+		{name: "disabled timeout", env: map[string]string{"REQUEST_TIMEOUT": "0"}, wantPort: "8080"},
+		{name: "invalid timeout", env: map[string]string{"REQUEST_TIMEOUT": "soon"}, wantPort: "8080", wantTimeout: DefaultRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			for _, key := range []string{"PORT", "REQUEST_TIMEOUT", "CORS_ALLOWED_ORIGINS", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_CLIENT_CA_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			cfg := ConfigFromEnv("service-x")
+			if cfg.Name != "service-x" || cfg.Port != tt.wantPort || cfg.RequestTimeout != tt.wantTimeout {
+				t.Errorf("unexpected config %+v", cfg)
+			}
+			if !slices.Equal(cfg.CORSOrigins, tt.wantOrigins) {
+				t.Errorf("expected origins %v but got %v", tt.wantOrigins, cfg.CORSOrigins)
+			}
+			if cfg.TLS != tt.wantTLS {
+				t.Errorf("expected tls %+v but got %+v", tt.wantTLS, cfg.TLS)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+
+	s := New(Config{Name: "service-x", RequestTimeout: time.Minute})
+
+	deadline := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			w.Header().Set("X-Deadline", "yes")
+		}
+		w.Header().Set("X-Lang", r.Header.Get("Accept-Language"))
+		_, _ = w.Write([]byte(r.PathValue("cep")))
+	}
+	s.HandleFunc("GET /v1/cep/{cep}", deadline)
+	s.HandleStream("GET /v1/stream/{cep}", deadline)
+
+	var order []string
+	s.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "used")
+			next.ServeHTTP(w, r)
+		})
+	})
 
-	handlerIndex(w, req)
-	res := w.Result()
-	defer res.Body.Close()
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		t.Errorf("expected error to be nil and got %v", err)
+	h := s.Handler()
+
+	tests := []struct {
+		path         string
+		wantStatus   int
+		wantBody     string
+		wantDeadline string
+	}{
+		{path: "/v1/cep/12345678", wantStatus: http.StatusOK, wantBody: "12345678", wantDeadline: "yes"},
+		{path: "/v1/stream/12345678", wantStatus: http.StatusOK, wantBody: "12345678"},
+		{path: "/nope", wantStatus: http.StatusNotFound},
 	}
 
-	type Response struct {
-		Message string `json:"message"`
+	for _, tt := range tests {
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Accept-Language", "pt-BR")
+		h.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.path, tt.wantStatus, w.Code)
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("%s: expected body %q but got %q", tt.path, tt.wantBody, w.Body.String())
+		}
+		if got := w.Header().Get("X-Deadline"); got != tt.wantDeadline {
+			t.Errorf("%s: expected deadline %q but got %q", tt.path, tt.wantDeadline, got)
+		}
+		if w.Header().Get(RequestIDHeader) == "" {
+			t.Errorf("%s: expected a request ID", tt.path)
+		}
+		if got := w.Header().Get("Content-Language"); got != "pt-BR" {
+			t.Errorf("%s: expected Content-Language pt-BR but got %q", tt.path, got)
+		}
 	}
 
-	expected := Response{
-		Message: "no zipcode provided",
+	if len(order) != len(tests) {
+		t.Errorf("expected the used middleware to run on every request but ran %d times", len(order))
 	}
+}
+
+func TestServerRecoversPanics(t *testing.T) {
+
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+
+	s := New(Config{Name: "service-x"})
+	s.HandleFunc("GET /v1/cep/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]int
+		m[r.PathValue("cep")]++
+	})
 
-	var received Response
-	err = json.Unmarshal(data, &received)
-	if err != nil {
-		t.Errorf("error to decoding response")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/cep/12345678", nil))
+
+	var p problem.Problem
+	_ = json.NewDecoder(w.Body).Decode(&p)
+	if w.Code != http.StatusInternalServerError || p.Code != "internal_error" {
+		t.Fatalf("expected a 500 problem but got %d %+v", w.Code, p)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected the server span but got %d spans", len(ended))
+	}
+	if p.TraceID != ended[0].SpanContext().TraceID().String() {
+		t.Errorf("expected trace_id %s but got %q", ended[0].SpanContext().TraceID(), p.TraceID)
 	}
 
-	if expected != received {
-		t.Errorf("expected %v but got %v", received, received)
+	var exceptions int
+	for _, e := range ended[0].Events() {
+		if e.Name == "exception" {
+			exceptions++
+		}
+	}
+	if exceptions != 1 {
+		t.Errorf("expected one exception event on the server span but got %d", exceptions)
 	}
 }